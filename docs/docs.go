@@ -58,6 +58,12 @@ const docTemplate = `{
                         "description": "Baud rate (defaults to configured default baud rate)",
                         "name": "baud_rate",
                         "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for a busy port (default true); false fails fast with 409",
+                        "name": "wait",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -67,6 +73,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/model.DeviceInfo"
                         }
                     },
+                    "409": {
+                        "description": "Port is in use",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -78,7 +90,7 @@ const docTemplate = `{
         },
         "/api/v1/health": {
             "get": {
-                "description": "Check the health status of the SMS Gateway service",
+                "description": "Check the health status of the SMS Gateway service and its modem sessions",
                 "produces": [
                     "application/json"
                 ],
@@ -96,6 +108,26 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/v1/metrics": {
+            "get": {
+                "description": "Number of messages waiting to be sent on each modem, by priority (urgent, high, normal)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Send queue metrics",
+                "responses": {
+                    "200": {
+                        "description": "Queue depth per modem and priority",
+                        "schema": {
+                            "$ref": "#/definitions/model.MetricsResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/modem/info": {
             "get": {
                 "description": "Get detailed information about the modem",
@@ -112,6 +144,12 @@ const docTemplate = `{
                         "description": "Port name (defaults to configured default port)",
                         "name": "port",
                         "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for a busy port (default true); false fails fast with 409",
+                        "name": "wait",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -121,6 +159,142 @@ const docTemplate = `{
                             "$ref": "#/definitions/model.ModemInfo"
                         }
                     },
+                    "409": {
+                        "description": "Port is in use",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/numbers": {
+            "get": {
+                "description": "GET lists the own number (MSISDN) known for every SIM, keyed by ICCID and IMSI, with its source: cnum, ussd, phonebook or manual. POST assigns a number by hand to the SIM in a port, or to the SIM with the given ICCID or IMSI; assigned numbers take precedence over discovered ones and survive restarts.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Device"
+                ],
+                "summary": "List or assign SIM numbers",
+                "parameters": [
+                    {
+                        "description": "Number and SIM (POST only)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.AssignNumberRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for a busy port (default true); false fails fast with 409",
+                        "name": "wait",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Known numbers (GET)",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.SIMNumber"
+                            }
+                        }
+                    },
+                    "201": {
+                        "description": "Assigned number (POST)",
+                        "schema": {
+                            "$ref": "#/definitions/model.SIMNumber"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Port is in use or the SIM cannot be identified",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "GET lists the own number (MSISDN) known for every SIM, keyed by ICCID and IMSI, with its source: cnum, ussd, phonebook or manual. POST assigns a number by hand to the SIM in a port, or to the SIM with the given ICCID or IMSI; assigned numbers take precedence over discovered ones and survive restarts.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Device"
+                ],
+                "summary": "List or assign SIM numbers",
+                "parameters": [
+                    {
+                        "description": "Number and SIM (POST only)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.AssignNumberRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for a busy port (default true); false fails fast with 409",
+                        "name": "wait",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Known numbers (GET)",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.SIMNumber"
+                            }
+                        }
+                    },
+                    "201": {
+                        "description": "Assigned number (POST)",
+                        "schema": {
+                            "$ref": "#/definitions/model.SIMNumber"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Port is in use or the SIM cannot be identified",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -172,6 +346,12 @@ const docTemplate = `{
                         "description": "Port name (defaults to configured default port)",
                         "name": "port",
                         "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for a busy port (default true); false reports it as in use immediately",
+                        "name": "wait",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -190,35 +370,158 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/sms/send": {
-            "post": {
-                "description": "Send an SMS message through the configured modem",
-                "consumes": [
+        "/api/v1/sms": {
+            "get": {
+                "description": "List outbound messages in the order they were created, optionally filtered by status, recipient and sender",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "SMS"
+                ],
+                "summary": "List sent SMS",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated statuses: pending, sending, sent, delivered, failed or expired",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Recipient number, in any format",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Port the message was sent on, or sender number",
+                        "name": "from",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Messages with their status",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.SMS"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sms/inbox": {
+            "get": {
+                "description": "GET lists the messages stored on the SIM (AT+CMGL), DELETE removes all of them (AT+CMGD=1,4)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "SMS"
+                    "Inbox"
                 ],
-                "summary": "Send SMS message",
+                "summary": "List or clear received SMS",
                 "parameters": [
                     {
-                        "description": "SMS request details",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
+                        "type": "string",
+                        "description": "Port name (defaults to configured default port)",
+                        "name": "port",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GET only: all (default), unread or read",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for a busy port (default true); false fails fast with 409",
+                        "name": "wait",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Received messages",
                         "schema": {
-                            "$ref": "#/definitions/model.SendSMSRequest"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.SMS"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Port is in use",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
                         }
                     }
+                }
+            },
+            "delete": {
+                "description": "GET lists the messages stored on the SIM (AT+CMGL), DELETE removes all of them (AT+CMGD=1,4)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Inbox"
+                ],
+                "summary": "List or clear received SMS",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Port name (defaults to configured default port)",
+                        "name": "port",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "GET only: all (default), unread or read",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for a busy port (default true); false fails fast with 409",
+                        "name": "wait",
+                        "in": "query"
+                    }
                 ],
                 "responses": {
                     "200": {
-                        "description": "SMS sent successfully",
+                        "description": "Received messages",
                         "schema": {
-                            "$ref": "#/definitions/model.SendSMSResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.SMS"
+                            }
                         }
                     },
                     "400": {
@@ -227,144 +530,1500 @@ const docTemplate = `{
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Port is in use",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "$ref": "#/definitions/model.SendSMSResponse"
+                            "$ref": "#/definitions/model.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "model.DeviceInfo": {
+        },
+        "/api/v1/sms/inbox/{index}": {
+            "get": {
+                "description": "GET reads the message at a storage index (AT+CMGR), DELETE removes it (AT+CMGD)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Inbox"
+                ],
+                "summary": "Read or delete a received SMS",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Storage index",
+                        "name": "index",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Port name (defaults to configured default port)",
+                        "name": "port",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for a busy port (default true); false fails fast with 409",
+                        "name": "wait",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Received message",
+                        "schema": {
+                            "$ref": "#/definitions/model.SMS"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No message at this index",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Port is in use",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "GET reads the message at a storage index (AT+CMGR), DELETE removes it (AT+CMGD)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Inbox"
+                ],
+                "summary": "Read or delete a received SMS",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Storage index",
+                        "name": "index",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Port name (defaults to configured default port)",
+                        "name": "port",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for a busy port (default true); false fails fast with 409",
+                        "name": "wait",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Received message",
+                        "schema": {
+                            "$ref": "#/definitions/model.SMS"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No message at this index",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Port is in use",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sms/scheduled": {
+            "get": {
+                "description": "List scheduled and recurring sends, next run first. Only upcoming ones are listed unless status says otherwise.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "List scheduled SMS",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated statuses: scheduled (default), done, cancelled, or all",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Scheduled sends",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.ScheduledSMS"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sms/scheduled/{id}": {
+            "get": {
+                "description": "GET returns a scheduled send, PATCH moves its next run or changes its recurrence, DELETE cancels it. Messages of earlier runs are not affected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Read, reschedule or cancel a scheduled SMS",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID returned by /api/v1/sms/send",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New send time or recurrence (PATCH only)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.RescheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Scheduled send",
+                        "schema": {
+                            "$ref": "#/definitions/model.ScheduledSMS"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Scheduled send not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Scheduled send already done or cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "GET returns a scheduled send, PATCH moves its next run or changes its recurrence, DELETE cancels it. Messages of earlier runs are not affected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Read, reschedule or cancel a scheduled SMS",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID returned by /api/v1/sms/send",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New send time or recurrence (PATCH only)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.RescheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Scheduled send",
+                        "schema": {
+                            "$ref": "#/definitions/model.ScheduledSMS"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Scheduled send not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Scheduled send already done or cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "GET returns a scheduled send, PATCH moves its next run or changes its recurrence, DELETE cancels it. Messages of earlier runs are not affected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Read, reschedule or cancel a scheduled SMS",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID returned by /api/v1/sms/send",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New send time or recurrence (PATCH only)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.RescheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Scheduled send",
+                        "schema": {
+                            "$ref": "#/definitions/model.ScheduledSMS"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Scheduled send not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Scheduled send already done or cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sms/send": {
+            "post": {
+                "description": "Send an SMS message through the configured modem. With \"async\": true the message is only validated and queued, and its status is followed with /api/v1/sms/{id}. With send_at or recurrence it is scheduled instead, and followed with /api/v1/sms/scheduled/{id}.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SMS"
+                ],
+                "summary": "Send SMS message",
+                "parameters": [
+                    {
+                        "description": "SMS request details",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SendSMSRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SMS sent successfully",
+                        "schema": {
+                            "$ref": "#/definitions/model.SendSMSResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "SMS queued (async) or scheduled",
+                        "schema": {
+                            "$ref": "#/definitions/model.SendSMSResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Port stayed busy for the whole timeout",
+                        "schema": {
+                            "$ref": "#/definitions/model.SendSMSResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.SendSMSResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/sms/{id}": {
+            "get": {
+                "description": "Get an outbound message by its gateway ID: its status and history, timestamps, attempts, port, error and the delivery status of each part",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "SMS"
+                ],
+                "summary": "Get SMS status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID returned by /api/v1/sms/send",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Message with its delivery status",
+                        "schema": {
+                            "$ref": "#/definitions/model.SMS"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tools/pdu/decode": {
+            "post": {
+                "description": "Decode an SMS-DELIVER, SMS-SUBMIT or SMS-STATUS-REPORT PDU, e.g. one captured from AT+CMGL",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tools"
+                ],
+                "summary": "Decode a PDU",
+                "parameters": [
+                    {
+                        "description": "PDU as hex",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.PDUDecodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Decoded PDU",
+                        "schema": {
+                            "$ref": "#/definitions/model.PDUInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed PDU",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tools/pdu/encode": {
+            "post": {
+                "description": "Encode a message as SMS-SUBMIT PDUs, split into concatenated parts when the encoding is automatic and the text is long",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tools"
+                ],
+                "summary": "Encode a PDU",
+                "parameters": [
+                    {
+                        "description": "Message to encode",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.PDUEncodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Encoded PDUs",
+                        "schema": {
+                            "$ref": "#/definitions/model.PDUEncodeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ussd": {
+            "post": {
+                "description": "Send a USSD code (AT+CUSD=1). When the network answers with a menu (+CUSD: 1) the session stays open for replies and holds the port until it ends, is cancelled or stays idle for the configured session timeout.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "USSD"
+                ],
+                "summary": "Start a USSD session",
+                "parameters": [
+                    {
+                        "description": "USSD code and port",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.USSDRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for a busy port (default true); false fails fast with 409",
+                        "name": "wait",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Answer of the network",
+                        "schema": {
+                            "$ref": "#/definitions/model.USSDSession"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request or code not supported",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Port is in use, e.g. by another USSD session",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "No answer from the network",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ussd/{session}": {
+            "get": {
+                "description": "GET returns an open session with the latest answer, DELETE cancels it (AT+CUSD=2) and releases the port",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "USSD"
+                ],
+                "summary": "Get or cancel a USSD session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID returned by /api/v1/ussd",
+                        "name": "session",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Session",
+                        "schema": {
+                            "$ref": "#/definitions/model.USSDSession"
+                        }
+                    },
+                    "404": {
+                        "description": "No open session with this ID",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "GET returns an open session with the latest answer, DELETE cancels it (AT+CUSD=2) and releases the port",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "USSD"
+                ],
+                "summary": "Get or cancel a USSD session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID returned by /api/v1/ussd",
+                        "name": "session",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Session",
+                        "schema": {
+                            "$ref": "#/definitions/model.USSDSession"
+                        }
+                    },
+                    "404": {
+                        "description": "No open session with this ID",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ussd/{session}/reply": {
+            "post": {
+                "description": "Send a reply, e.g. a menu choice, to an open session. The session stays open while the network answers with another menu.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "USSD"
+                ],
+                "summary": "Reply to a USSD menu",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID returned by /api/v1/ussd",
+                        "name": "session",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reply",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.USSDReplyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Answer of the network",
+                        "schema": {
+                            "$ref": "#/definitions/model.USSDSession"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request or reply not supported",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No open session with this ID",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "No answer from the network",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "model.AssignNumberRequest": {
+            "type": "object",
+            "required": [
+                "phone_number"
+            ],
+            "properties": {
+                "baud_rate": {
+                    "type": "integer"
+                },
+                "iccid": {
+                    "type": "string"
+                },
+                "imsi": {
+                    "type": "string"
+                },
+                "phone_number": {
+                    "type": "string"
+                },
+                "port": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Balance": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "main balance",
+                    "type": "number"
+                },
+                "currency": {
+                    "description": "currency of the main balance, e.g. VND",
+                    "type": "string"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.DataAllowance"
+                    }
+                },
+                "encoding": {
+                    "description": "gsm7, ucs2 or 8bit, from the DCS of the answer",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "expiry of the main balance, YYYY-MM-DD",
+                    "type": "string"
+                },
+                "operator": {
+                    "description": "operator whose parser was used",
+                    "type": "string"
+                },
+                "promotions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BalanceAmount"
+                    }
+                },
+                "raw": {
+                    "description": "answer as presented by the modem, e.g. hex UCS2",
+                    "type": "string"
+                },
+                "text": {
+                    "description": "decoded answer",
+                    "type": "string"
+                }
+            }
+        },
+        "model.BalanceAmount": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "YYYY-MM-DD",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ConcatInfo": {
+            "type": "object",
+            "properties": {
+                "part": {
+                    "type": "integer"
+                },
+                "reference": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.DataAllowance": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "YYYY-MM-DD",
+                    "type": "string"
+                },
+                "mb": {
+                    "description": "remaining volume in megabytes",
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.DeviceInfo": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "$ref": "#/definitions/model.Balance"
+                },
+                "baud_rate": {
+                    "type": "integer"
+                },
+                "connected": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "iccid": {
+                    "type": "string"
+                },
+                "imei": {
+                    "type": "string"
+                },
+                "imsi": {
+                    "type": "string"
+                },
+                "manufacturer": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "network_type": {
+                    "type": "string"
+                },
+                "number_source": {
+                    "description": "where phone_number came from, see SIMNumber",
+                    "type": "string"
+                },
+                "operator": {
+                    "type": "string"
+                },
+                "packages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Package"
+                    }
+                },
+                "phone_number": {
+                    "type": "string"
+                },
+                "port": {
+                    "type": "string"
+                },
+                "signal_level": {
+                    "type": "integer"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "modems": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ModemHealth"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "uptime": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.MetricsResponse": {
+            "type": "object",
+            "properties": {
+                "queue_depth": {
+                    "description": "all modems together, by priority",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "queues": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.QueueMetrics"
+                    }
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ModemHealth": {
+            "type": "object",
+            "properties": {
+                "connected_at": {
+                    "type": "string"
+                },
+                "last_check": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "port": {
+                    "type": "string"
+                },
+                "reconnects": {
+                    "type": "integer"
+                },
+                "state": {
+                    "description": "\"ready\", \"failed\" or \"disconnected\"",
+                    "type": "string"
+                }
+            }
+        },
+        "model.ModemInfo": {
+            "type": "object",
+            "properties": {
+                "baud_rate": {
+                    "type": "integer"
+                },
+                "connected": {
+                    "type": "boolean"
+                },
+                "imei": {
+                    "type": "string"
+                },
+                "manufacturer": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "port": {
+                    "type": "string"
+                },
+                "signal": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.PDUDecodeRequest": {
+            "type": "object",
+            "required": [
+                "pdu"
+            ],
+            "properties": {
+                "pdu": {
+                    "description": "hex",
+                    "type": "string"
+                },
+                "tpdu_only": {
+                    "description": "the PDU has no leading SMSC field",
+                    "type": "boolean"
+                }
+            }
+        },
+        "model.PDUEncodeRequest": {
+            "type": "object",
+            "required": [
+                "to"
+            ],
+            "properties": {
+                "class": {
+                    "description": "\"0\" (flash) to \"3\"",
+                    "type": "string"
+                },
+                "encoding": {
+                    "description": "gsm7, ucs2 or 8bit (message as hex); default from the text",
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "smsc": {
+                    "type": "string"
+                },
+                "status_report": {
+                    "type": "boolean"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "valid_until": {
+                    "description": "RFC 3339, instead of validity_minutes",
+                    "type": "string"
+                },
+                "validity_minutes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.PDUEncodeResponse": {
+            "type": "object",
+            "properties": {
+                "encoding": {
+                    "type": "string"
+                },
+                "parts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.PDUPart"
+                    }
+                }
+            }
+        },
+        "model.PDUInfo": {
+            "type": "object",
+            "properties": {
+                "class": {
+                    "type": "string"
+                },
+                "concat": {
+                    "$ref": "#/definitions/model.ConcatInfo"
+                },
+                "destination": {
+                    "description": "SMS-SUBMIT",
+                    "type": "string"
+                },
+                "discharge_time": {
+                    "type": "string"
+                },
+                "encoding": {
+                    "type": "string"
+                },
+                "header": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UDHElement"
+                    }
+                },
+                "recipient": {
+                    "description": "SMS-STATUS-REPORT",
+                    "type": "string"
+                },
+                "reference": {
+                    "description": "TP-MR",
+                    "type": "integer"
+                },
+                "sender": {
+                    "description": "SMS-DELIVER",
+                    "type": "string"
+                },
+                "smsc": {
+                    "type": "string"
+                },
+                "state": {
+                    "description": "delivered, pending, failed or expired",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "TP-Status as hex",
+                    "type": "string"
+                },
+                "status_report": {
+                    "description": "TP-SRR",
+                    "type": "boolean"
+                },
+                "text": {
+                    "description": "8-bit data as hex",
+                    "type": "string"
+                },
+                "timestamp": {
+                    "description": "service centre time stamp",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "SMS-DELIVER, SMS-SUBMIT or SMS-STATUS-REPORT",
+                    "type": "string"
+                },
+                "valid_until": {
+                    "type": "string"
+                },
+                "validity_period": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.PDUPart": {
+            "type": "object",
+            "properties": {
+                "length": {
+                    "type": "integer"
+                },
+                "pdu": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Package": {
+            "type": "object",
+            "properties": {
+                "data_mb": {
+                    "description": "remaining data in megabytes",
+                    "type": "number"
+                },
+                "expires_at": {
+                    "description": "YYYY-MM-DD",
+                    "type": "string"
+                },
+                "minutes": {
+                    "description": "remaining call minutes",
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "sms": {
+                    "description": "remaining messages",
+                    "type": "integer"
+                }
+            }
+        },
+        "model.PortStatus": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "boolean"
+                },
+                "balance": {
+                    "$ref": "#/definitions/model.Balance"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "in_use": {
+                    "type": "boolean"
+                },
+                "in_use_by": {
+                    "type": "string"
+                },
+                "in_use_since": {
+                    "type": "string"
+                },
+                "port": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.QueueMetrics": {
+            "type": "object",
+            "properties": {
+                "depth": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "port": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.RescheduleRequest": {
             "type": "object",
             "properties": {
-                "balance": {
+                "recurrence": {
+                    "description": "\"\" makes the schedule one-off",
+                    "type": "string"
+                },
+                "send_at": {
+                    "type": "string"
+                },
+                "time_zone": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SIMNumber": {
+            "type": "object",
+            "properties": {
+                "iccid": {
+                    "type": "string"
+                },
+                "imsi": {
+                    "type": "string"
+                },
+                "phone_number": {
+                    "type": "string"
+                },
+                "source": {
+                    "description": "one of the NumberSource constants",
+                    "type": "string"
+                },
+                "updated_at": {
                     "type": "string"
+                }
+            }
+        },
+        "model.SMS": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "every try at sending the message",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SendAttempt"
+                    }
                 },
                 "baud_rate": {
+                    "description": "of Port",
                     "type": "integer"
                 },
-                "connected": {
-                    "type": "boolean"
+                "created_at": {
+                    "type": "string"
                 },
-                "error": {
+                "delivered_at": {
                     "type": "string"
                 },
-                "imei": {
+                "encoding": {
+                    "description": "gsm7, ucs2 or, inbound only, 8bit (message is then hex)",
                     "type": "string"
                 },
-                "imsi": {
+                "error_msg": {
                     "type": "string"
                 },
-                "manufacturer": {
+                "from": {
                     "type": "string"
                 },
-                "model": {
+                "history": {
+                    "description": "every status the message went through",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.StatusChange"
+                    }
+                },
+                "id": {
                     "type": "string"
                 },
-                "network_type": {
+                "index": {
+                    "description": "Inbound messages only",
+                    "type": "integer"
+                },
+                "message": {
                     "type": "string"
                 },
-                "operator": {
+                "mode": {
+                    "description": "Outbound messages only",
                     "type": "string"
                 },
-                "phone_number": {
+                "next_attempt_at": {
+                    "description": "when a failed send is retried",
                     "type": "string"
                 },
+                "partial": {
+                    "description": "parts were missing when reassembly timed out",
+                    "type": "boolean"
+                },
+                "parts": {
+                    "description": "part count of a concatenated message",
+                    "type": "integer"
+                },
                 "port": {
                     "type": "string"
                 },
-                "signal_level": {
-                    "type": "integer"
+                "priority": {
+                    "description": "one of the Priority constants",
+                    "type": "string"
                 },
-                "timestamp": {
+                "received_at": {
+                    "description": "SMSC time stamp",
                     "type": "string"
                 },
-                "version": {
+                "schedule_id": {
+                    "description": "of the scheduled send that created the message",
+                    "type": "string"
+                },
+                "segments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SMSSegment"
+                    }
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timeout": {
+                    "description": "seconds to wait for a busy modem",
+                    "type": "integer"
+                },
+                "to": {
                     "type": "string"
                 }
             }
         },
-        "model.ErrorResponse": {
+        "model.SMSSegment": {
             "type": "object",
             "properties": {
-                "code": {
+                "part": {
                     "type": "integer"
                 },
-                "error": {
-                    "type": "string"
+                "reference": {
+                    "type": "integer"
                 },
-                "timestamp": {
+                "status": {
+                    "description": "from its status report: \"delivered\", \"pending\", \"failed\" or \"expired\"; \"submitting\" while sent",
                     "type": "string"
                 }
             }
         },
-        "model.HealthResponse": {
+        "model.ScheduledSMS": {
             "type": "object",
             "properties": {
-                "status": {
-                    "type": "string"
+                "baud_rate": {
+                    "type": "integer"
                 },
-                "timestamp": {
+                "created_at": {
                     "type": "string"
                 },
-                "uptime": {
+                "id": {
                     "type": "string"
                 },
-                "version": {
+                "last_message_id": {
+                    "description": "message created by the last run",
                     "type": "string"
-                }
-            }
-        },
-        "model.ModemInfo": {
-            "type": "object",
-            "properties": {
-                "baud_rate": {
-                    "type": "integer"
                 },
-                "connected": {
-                    "type": "boolean"
+                "last_run_at": {
+                    "type": "string"
                 },
-                "imei": {
+                "message": {
                     "type": "string"
                 },
-                "manufacturer": {
+                "mode": {
                     "type": "string"
                 },
-                "model": {
+                "next_run_at": {
                     "type": "string"
                 },
                 "port": {
                     "type": "string"
                 },
-                "signal": {
+                "priority": {
+                    "type": "string"
+                },
+                "recurrence": {
+                    "description": "cron expression",
+                    "type": "string"
+                },
+                "runs": {
                     "type": "integer"
                 },
-                "version": {
+                "status": {
+                    "description": "one of the Schedule constants",
+                    "type": "string"
+                },
+                "time_zone": {
+                    "description": "of the recurrence",
+                    "type": "string"
+                },
+                "timeout": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "updated_at": {
                     "type": "string"
                 }
             }
         },
-        "model.PortStatus": {
+        "model.SendAttempt": {
             "type": "object",
             "properties": {
-                "available": {
-                    "type": "boolean"
-                },
-                "balance": {
+                "ended_at": {
                     "type": "string"
                 },
                 "error": {
                     "type": "string"
                 },
-                "in_use": {
-                    "type": "boolean"
+                "number": {
+                    "type": "integer"
                 },
                 "port": {
                     "type": "string"
+                },
+                "retryable": {
+                    "description": "the error was temporary",
+                    "type": "boolean"
+                },
+                "started_at": {
+                    "type": "string"
                 }
             }
         },
@@ -375,6 +2034,10 @@ const docTemplate = `{
                 "to"
             ],
             "properties": {
+                "async": {
+                    "description": "return 202 once queued instead of waiting for the modem",
+                    "type": "boolean"
+                },
                 "baud_rate": {
                     "type": "integer"
                 },
@@ -392,6 +2055,18 @@ const docTemplate = `{
                     "description": "\"normal\", \"high\", \"urgent\"",
                     "type": "string"
                 },
+                "recurrence": {
+                    "description": "cron expression, e.g. \"0 8 * * 1-5\"",
+                    "type": "string"
+                },
+                "send_at": {
+                    "description": "Scheduled sends, always answered with 202",
+                    "type": "string"
+                },
+                "time_zone": {
+                    "description": "IANA zone of the recurrence, default the server's",
+                    "type": "string"
+                },
                 "timeout": {
                     "type": "integer"
                 },
@@ -403,9 +2078,19 @@ const docTemplate = `{
         "model.SendSMSResponse": {
             "type": "object",
             "properties": {
+                "attempts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SendAttempt"
+                    }
+                },
                 "duration": {
                     "type": "string"
                 },
+                "encoding": {
+                    "description": "\"gsm7\" or \"ucs2\"",
+                    "type": "string"
+                },
                 "error": {
                     "type": "string"
                 },
@@ -419,6 +2104,28 @@ const docTemplate = `{
                     "description": "\"text\" or \"pdu\"",
                     "type": "string"
                 },
+                "port": {
+                    "description": "the message was last sent on",
+                    "type": "string"
+                },
+                "schedule_id": {
+                    "description": "of a scheduled send",
+                    "type": "string"
+                },
+                "segments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SMSSegment"
+                    }
+                },
+                "send_at": {
+                    "description": "first run of a scheduled send",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "of the message, \"pending\" when queued asynchronously, \"scheduled\" for a scheduled send",
+                    "type": "string"
+                },
                 "steps": {
                     "type": "array",
                     "items": {
@@ -436,6 +2143,20 @@ const docTemplate = `{
                 }
             }
         },
+        "model.StatusChange": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
         "model.SuccessResponse": {
             "type": "object",
             "properties": {
@@ -450,6 +2171,96 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "model.UDHElement": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "hex",
+                    "type": "string"
+                },
+                "iei": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.USSDReplyRequest": {
+            "type": "object",
+            "required": [
+                "input"
+            ],
+            "properties": {
+                "input": {
+                    "description": "e.g. a menu choice such as \"1\"",
+                    "type": "string"
+                }
+            }
+        },
+        "model.USSDRequest": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "baud_rate": {
+                    "type": "integer"
+                },
+                "code": {
+                    "description": "e.g. \"*098#\"",
+                    "type": "string"
+                },
+                "port": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.USSDSession": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "the session accepts replies",
+                    "type": "boolean"
+                },
+                "code": {
+                    "type": "string"
+                },
+                "encoding": {
+                    "description": "gsm7, ucs2 or 8bit, from the DCS of the answer",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "an active session is cancelled when idle until then",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "message": {
+                    "description": "decoded text of the latest answer",
+                    "type": "string"
+                },
+                "port": {
+                    "type": "string"
+                },
+                "raw": {
+                    "description": "the answer as presented by the modem, e.g. hex UCS2",
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "\u003cm\u003e of +CUSD, 1 when the network waits for a reply",
+                    "type": "integer"
+                },
+                "status_text": {
+                    "description": "description of status",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
@@ -3,9 +3,11 @@ module sms-gateway
 go 1.22
 
 require (
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/swaggo/http-swagger v1.3.4
 	github.com/swaggo/swag v1.16.3
 	go.bug.st/serial v1.6.4
+	go.etcd.io/bbolt v1.3.11
 )
 
 require (
@@ -22,11 +22,24 @@ func NewRouter(cfg *config.Config, smsService *service.SMSService) http.Handler
 	// API v1 routes
 	mux.HandleFunc("/", smsHandler.HandleRoot)
 	mux.HandleFunc("/api/v1/health", smsHandler.HandleHealth)
+	mux.HandleFunc("/api/v1/metrics", smsHandler.HandleMetrics)
+	mux.HandleFunc("/api/v1/sms", smsHandler.HandleListSMS)
 	mux.HandleFunc("/api/v1/sms/send", smsHandler.HandleSendSMS)
+	mux.HandleFunc("/api/v1/sms/{id}", smsHandler.HandleGetSMS)
+	mux.HandleFunc("/api/v1/sms/scheduled", smsHandler.HandleSchedules)
+	mux.HandleFunc("/api/v1/sms/scheduled/{id}", smsHandler.HandleSchedule)
+	mux.HandleFunc("/api/v1/sms/inbox", smsHandler.HandleInbox)
+	mux.HandleFunc("/api/v1/sms/inbox/{index}", smsHandler.HandleInboxMessage)
+	mux.HandleFunc("/api/v1/ussd", smsHandler.HandleStartUSSD)
+	mux.HandleFunc("/api/v1/ussd/{session}", smsHandler.HandleUSSDSession)
+	mux.HandleFunc("/api/v1/ussd/{session}/reply", smsHandler.HandleReplyUSSD)
+	mux.HandleFunc("/api/v1/tools/pdu/decode", smsHandler.HandleDecodePDU)
+	mux.HandleFunc("/api/v1/tools/pdu/encode", smsHandler.HandleEncodePDU)
 	mux.HandleFunc("/api/v1/ports", smsHandler.HandleListPorts)
 	mux.HandleFunc("/api/v1/ports/status", smsHandler.HandlePortStatus)
 	mux.HandleFunc("/api/v1/modem/info", smsHandler.HandleModemInfo)
 	mux.HandleFunc("/api/v1/device/info", smsHandler.HandleDeviceInfo)
+	mux.HandleFunc("/api/v1/numbers", smsHandler.HandleNumbers)
 
 	// Swagger documentation
 	mux.HandleFunc("/swagger/", httpSwagger.Handler(
@@ -0,0 +1,1501 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sms-gateway/src/internal/config"
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/service"
+	"sms-gateway/src/internal/store"
+	"sms-gateway/src/pkg/simulator"
+)
+
+// newTestServer starts the full API against a simulated modem
+func newTestServer(t *testing.T, portName string, configure ...func(cfg *config.Config)) (*httptest.Server, *simulator.Modem) {
+	t.Helper()
+
+	cfg := config.Load()
+	cfg.Version = "test"
+	cfg.Modem.DefaultPort = portName
+	cfg.Modem.BalanceUSSD = "*101#"
+	cfg.Modem.NumbersFile = filepath.Join(t.TempDir(), "sim_numbers.json")
+	cfg.SMS.StorePath = filepath.Join(t.TempDir(), "messages.db")
+	cfg.SMS.RetryCount = 3
+	cfg.SMS.RetryDelay = 20 * time.Millisecond
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	modem := simulator.Get(portName)
+	modem.SetUSSD("*101#", "TKC: 12.345d, HSD: 31/12/2026")
+
+	svc := service.NewSMSService(cfg)
+	t.Cleanup(svc.Close)
+	srv := httptest.NewServer(NewRouter(cfg, svc))
+	t.Cleanup(srv.Close)
+	return srv, modem
+}
+
+func postJSON(t *testing.T, url string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func getJSON(t *testing.T, url string, out interface{}) int {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestSendSMSThroughSimulator(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-send")
+
+	var responses []model.SendSMSResponse
+	for _, mode := range []string{"text", "pdu"} {
+		var resp model.SendSMSResponse
+		code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+			To:      "0912345678",
+			Message: "OK this is not a result code",
+			Mode:    mode,
+		}, &resp)
+		if code != http.StatusOK || !resp.Success {
+			t.Fatalf("%s mode: status %d, response %+v", mode, code, resp)
+		}
+		responses = append(responses, resp)
+	}
+
+	sent := modem.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 submitted messages, got %d", len(sent))
+	}
+	if responses[0].MessageID == "" || responses[0].MessageID == responses[1].MessageID {
+		t.Errorf("message IDs must be unique, got %q and %q", responses[0].MessageID, responses[1].MessageID)
+	}
+	for i, resp := range responses {
+		if len(resp.Segments) != 1 || resp.Segments[0].Reference != sent[i].Reference {
+			t.Errorf("message %d: segments %+v, modem reference %d", i, resp.Segments, sent[i].Reference)
+		}
+	}
+	if sent[0].Mode != "text" || sent[0].To != "+84912345678" {
+		t.Errorf("unexpected text submission %+v", sent[0])
+	}
+	// SMS-SUBMIT requesting a status report to +84912345678, GSM 7-bit, 24h validity, 28 septets
+	if sent[1].Mode != "pdu" || !strings.HasPrefix(sent[1].Payload, "0031000B914819325476F80000A71C") {
+		t.Errorf("unexpected pdu submission %+v", sent[1])
+	}
+}
+
+func TestSendSMSFormattedNumber(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-formatted")
+
+	for _, tt := range []struct {
+		mode string
+		to   string
+	}{
+		{"pdu", "0912 345 678"},
+		{"text", "(091) 234-5678"},
+	} {
+		var resp model.SendSMSResponse
+		code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{To: tt.to, Message: "hello", Mode: tt.mode, Async: true}, &resp)
+		if code != http.StatusAccepted || resp.To != "0912345678" {
+			t.Fatalf("%s: status %d, response %+v", tt.to, code, resp)
+		}
+		if msg := waitForStatus(t, srv.URL+"/api/v1/sms/"+resp.MessageID); msg.Status != model.StatusDelivered || msg.To != "0912345678" {
+			t.Errorf("%s: status %s, to %q, error %q", tt.to, msg.Status, msg.To, msg.ErrorMsg)
+		}
+	}
+	if sent := modem.Sent(); len(sent) != 2 || sent[1].To != "+84912345678" {
+		t.Errorf("unexpected submissions %+v", sent)
+	}
+}
+
+func TestSendSMSModemError(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-error")
+	// Unassigned number, which no retry can fix
+	modem.FailNext("AT+CMGS", "+CMS ERROR: 1")
+
+	var resp model.SendSMSResponse
+	code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+		To:      "0912345678",
+		Message: "hello",
+	}, &resp)
+	if code != http.StatusInternalServerError || resp.Success {
+		t.Fatalf("expected failure, got status %d, response %+v", code, resp)
+	}
+	if len(modem.Sent()) != 0 {
+		t.Fatalf("failed send must not be recorded")
+	}
+	if len(resp.Attempts) != 1 || resp.Attempts[0].Retryable {
+		t.Errorf("permanent error must not be retried: %+v", resp.Attempts)
+	}
+}
+
+func TestSendSMSLostWhileSubmitting(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-lost")
+	modem.SendDelay = time.Second
+	t.Cleanup(func() { modem.SendDelay = 50 * time.Millisecond })
+
+	var resp model.SendSMSResponse
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{To: "0912345678", Message: "maybe sent", Async: true}, &resp); code != http.StatusAccepted {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	// The modem took the part but the connection drops before it answers
+	deadline := time.Now().Add(2 * time.Second)
+	for len(modem.Sent()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	modem.Disconnect()
+
+	msg := waitForStatus(t, srv.URL+"/api/v1/sms/"+resp.MessageID)
+	if msg.Status != model.StatusFailed || len(msg.Attempts) != 1 ||
+		len(msg.Segments) != 1 || msg.Segments[0].Status != model.SegmentSubmitting {
+		t.Errorf("a part that may have been sent must fail the message: %s, %+v, %+v", msg.Status, msg.Attempts, msg.Segments)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := len(modem.Sent()); n != 1 {
+		t.Errorf("message sent %d times", n)
+	}
+}
+
+func TestSendSMSRetry(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-retry")
+
+	// Network timeout, then temporary failure, then accepted
+	modem.FailNext("AT+CMGS", "+CMS ERROR: 332")
+	modem.FailNext("AT+CMGS", "+CMS ERROR: 41")
+	var resp model.SendSMSResponse
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{To: "0912345678", Message: "retried"}, &resp); code != http.StatusOK {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	if len(resp.Attempts) != 3 || !resp.Attempts[0].Retryable || resp.Attempts[2].Error != "" {
+		t.Errorf("unexpected attempts %+v", resp.Attempts)
+	}
+	if len(modem.Sent()) != 1 {
+		t.Errorf("expected the message sent once, got %d", len(modem.Sent()))
+	}
+
+	msg := waitForStatus(t, srv.URL+"/api/v1/sms/"+resp.MessageID)
+	var statuses []string
+	for _, change := range msg.History {
+		statuses = append(statuses, change.Status)
+	}
+	want := "pending,sending,pending,sending,pending,sending,sent,delivered"
+	if got := strings.Join(statuses, ","); got != want {
+		t.Errorf("history = %s, want %s", got, want)
+	}
+	if !strings.Contains(msg.History[2].Error, "332") || msg.ErrorMsg != "" {
+		t.Errorf("retry must record the error in the history: %+v", msg.History[2])
+	}
+
+	// The retries run out
+	for i := 0; i < 4; i++ {
+		modem.FailNext("AT+CMGS", "+CMS ERROR: 500")
+	}
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{To: "0912345678", Message: "given up"}, &resp); code != http.StatusInternalServerError {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	if resp.Status != model.StatusFailed || len(resp.Attempts) != 4 {
+		t.Errorf("expected failure after 4 attempts, got %s with %d", resp.Status, len(resp.Attempts))
+	}
+}
+
+func TestSendSMSFailover(t *testing.T) {
+	const backup = "sim://router-failover-b"
+	srv, modem := newTestServer(t, "sim://router-failover-a", func(cfg *config.Config) {
+		cfg.SMS.RetryFailover = true
+	})
+	other := simulator.Get(backup)
+
+	// Open the second modem so that it is ready to take over
+	var info model.ModemInfo
+	if code := getJSON(t, srv.URL+"/api/v1/modem/info?port="+backup, &info); code != http.StatusOK {
+		t.Fatalf("modem info: status %d", code)
+	}
+
+	modem.FailNext("AT+CMGS", "+CMS ERROR: 38")
+	var resp model.SendSMSResponse
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{To: "0912345678", Message: "failover"}, &resp); code != http.StatusOK {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	if resp.Port != backup || len(resp.Attempts) != 2 ||
+		resp.Attempts[0].Port != "sim://router-failover-a" || resp.Attempts[1].Port != backup {
+		t.Errorf("expected a retry on %s, got port %s, attempts %+v", backup, resp.Port, resp.Attempts)
+	}
+	if len(modem.Sent()) != 0 || len(other.Sent()) != 1 {
+		t.Errorf("sent %d on the first modem and %d on the second", len(modem.Sent()), len(other.Sent()))
+	}
+}
+
+func TestDeviceInfoThroughSimulator(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-info")
+
+	var info model.DeviceInfo
+	if code := getJSON(t, srv.URL+"/api/v1/device/info?port=sim://router-info", &info); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if !info.Connected || info.IMEI != modem.IMEI || info.IMSI != modem.IMSI {
+		t.Errorf("unexpected device info %+v", info)
+	}
+	if info.Operator != "Viettel" {
+		t.Errorf("operator = %q", info.Operator)
+	}
+	if info.Balance == nil || info.Balance.Text != "TKC: 12.345d, HSD: 31/12/2026" || info.Balance.Raw != info.Balance.Text {
+		t.Fatalf("balance = %+v", info.Balance)
+	}
+	if b := info.Balance; b.Operator != "Viettel" || b.Amount == nil || *b.Amount != 12345 || b.Currency != "VND" || b.ExpiresAt != "2026-12-31" {
+		t.Errorf("parsed balance = %+v", b)
+	}
+
+	// Answers in UCS2 or packed GSM 7-bit are passed on as hex
+	for _, encoding := range []string{"ucs2", "gsm7"} {
+		modem.USSDEncoding = encoding
+		text := "TKC: 12.345d, HSD: 31/12/2026"
+		if encoding == "ucs2" {
+			text = "Tài khoản chính: 12.345đ"
+		}
+		modem.SetUSSD("*101#", text)
+		if code := getJSON(t, srv.URL+"/api/v1/device/info?port=sim://router-info", &info); code != http.StatusOK {
+			t.Fatalf("status %d", code)
+		}
+		if info.Balance == nil || info.Balance.Text != text || info.Balance.Encoding != encoding || info.Balance.Raw == text {
+			t.Errorf("%s balance = %+v", encoding, info.Balance)
+		} else if info.Balance.Amount == nil || *info.Balance.Amount != 12345 {
+			t.Errorf("%s amount = %v", encoding, info.Balance.Amount)
+		}
+	}
+
+	var status model.PortStatus
+	if code := getJSON(t, srv.URL+"/api/v1/ports/status?port=sim://router-info", &status); code != http.StatusOK {
+		t.Fatalf("port status %d", code)
+	}
+	if status.Balance == nil || status.Balance.Amount == nil || *status.Balance.Amount != 12345 || status.Balance.Operator != "Viettel" {
+		t.Errorf("port status balance = %+v", status.Balance)
+	}
+}
+
+func TestPortStatusStopsWithClient(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-cancel")
+	modem.USSDDelay = 5 * time.Second
+	// Port scans of later tests query every simulated modem
+	t.Cleanup(func() { modem.USSDDelay = 50 * time.Millisecond })
+
+	// The client gives up while the balance USSD is outstanding
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/ports/status?port=sim://router-cancel", nil)
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected the request to time out, got status %d", resp.StatusCode)
+	}
+
+	// The port is released soon after instead of at the end of the USSD
+	start := time.Now()
+	var resp model.SendSMSResponse
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+		To:      "0912345678",
+		Message: "after cancel",
+		Port:    "sim://router-cancel",
+	}, &resp); code != http.StatusOK {
+		t.Fatalf("send: status %d, response %+v", code, resp)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("send waited %v for the abandoned status query", elapsed)
+	}
+}
+
+func TestModemSessionIsReused(t *testing.T) {
+	srv, _ := newTestServer(t, "sim://router-health")
+
+	for i := 0; i < 2; i++ {
+		var resp model.SendSMSResponse
+		if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+			To:      "0912345678",
+			Message: "hello",
+		}, &resp); code != http.StatusOK {
+			t.Fatalf("send %d: status %d, response %+v", i, code, resp)
+		}
+	}
+
+	var health model.HealthResponse
+	getJSON(t, srv.URL+"/api/v1/health", &health)
+	if len(health.Modems) != 1 {
+		t.Fatalf("expected one managed modem, got %+v", health.Modems)
+	}
+	if m := health.Modems[0]; m.State != "ready" || m.Reconnects != 0 {
+		t.Errorf("unexpected modem health %+v", m)
+	}
+}
+
+func TestPortsSendInParallel(t *testing.T) {
+	srv, slow := newTestServer(t, "sim://router-slow")
+	fast := simulator.Get("sim://router-fast")
+	slow.SendDelay = 2 * time.Second
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var resp model.SendSMSResponse
+		postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+			To:      "0912345678",
+			Message: "slow",
+			Port:    "sim://router-slow",
+		}, &resp)
+	}()
+
+	// Wait until the slow send holds its port
+	deadline := time.Now().Add(time.Second)
+	for {
+		var status model.PortStatus
+		getJSON(t, srv.URL+"/api/v1/ports/status?port=sim://router-slow&wait=false", &status)
+		if status.InUse {
+			if status.InUseBy == "" {
+				t.Errorf("busy port should report its holder: %+v", status)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("slow port never reported as in use")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	start := time.Now()
+	var resp model.SendSMSResponse
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+		To:      "0912345678",
+		Message: "fast",
+		Port:    "sim://router-fast",
+	}, &resp); code != http.StatusOK {
+		t.Fatalf("fast send: status %d, response %+v", code, resp)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("send on an idle modem waited %v for a busy one", elapsed)
+	}
+	if len(fast.Sent()) != 1 {
+		t.Errorf("fast modem sent %d messages", len(fast.Sent()))
+	}
+
+	var errResp model.ErrorResponse
+	if code := getJSON(t, srv.URL+"/api/v1/device/info?port=sim://router-slow&wait=false", &errResp); code != http.StatusConflict {
+		t.Errorf("device info on busy port: status %d, want 409", code)
+	}
+	<-done
+}
+
+func TestSendVietnameseAsUCS2(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-ucs2")
+	message := "Mã OTP của bạn là 123456"
+
+	for _, mode := range []string{"text", "pdu"} {
+		var resp model.SendSMSResponse
+		code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+			To:      "0912345678",
+			Message: message,
+			Mode:    mode,
+		}, &resp)
+		if code != http.StatusOK || resp.Encoding != "ucs2" {
+			t.Fatalf("%s mode: status %d, response %+v", mode, code, resp)
+		}
+	}
+
+	sent := modem.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 submitted messages, got %d", len(sent))
+	}
+	// "Mã" in UCS2 hex
+	if sent[0].To != "+84912345678" || sent[0].DCS != 8 || !strings.HasPrefix(sent[0].Payload, "004D00E3") {
+		t.Errorf("unexpected text submission %+v", sent[0])
+	}
+	// SRR set; DCS 08, 24 characters = 48 octets
+	if !strings.HasPrefix(sent[1].Payload, "0031000B914819325476F80008A730004D00E3") {
+		t.Errorf("unexpected pdu submission %+v", sent[1])
+	}
+
+}
+
+func TestSendGSM7TextMode(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-gsm7")
+	// 100 characters in the GSM alphabet but not in ASCII: one GSM 7-bit
+	// message rather than two UCS2 ones
+	message := "Café à 5£ " + strings.Repeat("x", 90)
+
+	var resp model.SendSMSResponse
+	code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+		To:      "0912345678",
+		Message: message,
+		Mode:    "text",
+	}, &resp)
+	if code != http.StatusOK || resp.Mode != "text" || resp.Encoding != "gsm7" || len(resp.Segments) != 1 {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+
+	sent := modem.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 submitted message, got %d", len(sent))
+	}
+	// Written as UCS2 hex ("Caf" then "é"), sent with DCS 0
+	if sent[0].DCS != 0 || !strings.HasPrefix(sent[0].Payload, "00430061006600E9") {
+		t.Errorf("unexpected text submission %+v", sent[0])
+	}
+}
+
+func TestSendBacktickTextMode(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-backtick")
+	// ASCII, but "`" is not in the GSM alphabet
+	message := "Run `make`"
+
+	var resp model.SendSMSResponse
+	code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+		To:      "0912345678",
+		Message: message,
+		Mode:    "text",
+	}, &resp)
+	if code != http.StatusOK || resp.Mode != "text" || resp.Encoding != "ucs2" {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+
+	sent := modem.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 submitted message, got %d", len(sent))
+	}
+	// Written as UCS2 hex to match DCS 8: "Run " then "`"
+	if sent[0].To != "+84912345678" || sent[0].DCS != 8 || !strings.HasPrefix(sent[0].Payload, "00520075006E00200060") {
+		t.Errorf("unexpected text submission %+v", sent[0])
+	}
+}
+
+func TestSendConcatenatedSMS(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-multipart")
+
+	var resp model.SendSMSResponse
+	code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+		To:      "0912345678",
+		Message: strings.Repeat("0123456789", 20),
+	}, &resp)
+	if code != http.StatusOK || !resp.Success {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	if resp.Mode != "pdu" || len(resp.Segments) != 2 {
+		t.Fatalf("expected 2 segments sent in PDU mode, got %+v", resp)
+	}
+	if resp.Segments[0].Reference == resp.Segments[1].Reference {
+		t.Errorf("segments share reference %d", resp.Segments[0].Reference)
+	}
+
+	sent := modem.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 submitted parts, got %d", len(sent))
+	}
+	for i, part := range sent {
+		// UDHI and SRR set; UDL 160 then 54 septets; 8-bit reference header
+		udl := []string{"A0", "36"}[i]
+		if !strings.HasPrefix(part.Payload, "0071000B914819325476F80000A7"+udl+"050003") {
+			t.Errorf("part %d: unexpected PDU %s", i+1, part.Payload)
+		}
+	}
+
+	var errResp model.ErrorResponse
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+		To:      "0912345678",
+		Message: strings.Repeat("a", 1531),
+	}, &errResp); code != http.StatusBadRequest {
+		t.Errorf("message over SMS_MAX_LENGTH: status %d, want 400", code)
+	}
+}
+
+// waitForStatus polls the message until it leaves the sent state
+func waitForStatus(t *testing.T, url string) model.SMS {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		var msg model.SMS
+		if code := getJSON(t, url, &msg); code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", url, code)
+		}
+		settled := msg.Status != model.StatusPending && msg.Status != model.StatusSending && msg.Status != model.StatusSent
+		if settled || time.Now().After(deadline) {
+			return msg
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestDeliveryReports(t *testing.T) {
+	srv, _ := newTestServer(t, "sim://router-delivery")
+
+	for _, tt := range []struct {
+		mode    string
+		message string
+	}{
+		{"text", "delivered in text mode"},
+		{"pdu", "delivered in PDU mode"},
+		{"pdu", strings.Repeat("0123456789", 20)},
+	} {
+		var resp model.SendSMSResponse
+		if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+			To:      "0912345678",
+			Message: tt.message,
+			Mode:    tt.mode,
+		}, &resp); code != http.StatusOK {
+			t.Fatalf("%s mode: status %d, response %+v", tt.mode, code, resp)
+		}
+
+		msg := waitForStatus(t, srv.URL+"/api/v1/sms/"+resp.MessageID)
+		if msg.Status != model.StatusDelivered || msg.DeliveredAt == nil {
+			t.Errorf("%s mode: expected delivered, got %+v", tt.mode, msg)
+		}
+		for _, seg := range msg.Segments {
+			if seg.Status != "delivered" {
+				t.Errorf("%s mode: part %d is %q", tt.mode, seg.Part, seg.Status)
+			}
+		}
+	}
+
+	var errResp model.ErrorResponse
+	if code := getJSON(t, srv.URL+"/api/v1/sms/SMS_0_unknown", &errResp); code != http.StatusNotFound {
+		t.Errorf("unknown message: status %d, want 404", code)
+	}
+}
+
+func TestExpiredAndStoredDeliveryReports(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-expired")
+	// TP-Status 46: validity period expired; reports go to "SR" memory
+	modem.DeliveryStatus = 0x46
+	modem.StoreReports = true
+
+	var resp model.SendSMSResponse
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{
+		To:      "+84912345678",
+		Message: "expires",
+		Mode:    "pdu",
+	}, &resp); code != http.StatusOK {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+
+	msg := waitForStatus(t, srv.URL+"/api/v1/sms/"+resp.MessageID)
+	if msg.Status != model.StatusExpired || msg.ErrorMsg == "" {
+		t.Errorf("expected expired, got %+v", msg)
+	}
+	if n := modem.Reports(); n != 0 {
+		t.Errorf("%d status reports left in modem memory", n)
+	}
+}
+
+func deleteJSON(t *testing.T, url string, out interface{}) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestInbox(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-inbox")
+	first := modem.InjectSMS("+84987654321", "Xin chào, tôi cần hỗ trợ")
+	second := modem.InjectSMS("Viettel", "KM 50% the nap")
+
+	var messages []model.SMS
+	if code := getJSON(t, srv.URL+"/api/v1/sms/inbox?status=unread", &messages); code != http.StatusOK {
+		t.Fatalf("list: status %d", code)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %+v", messages)
+	}
+	if messages[0].Index != first || messages[0].From != "+84987654321" || messages[0].Message != "Xin chào, tôi cần hỗ trợ" ||
+		messages[0].Encoding != "ucs2" || messages[0].Status != model.StatusUnread || messages[0].ReceivedAt == nil {
+		t.Errorf("unexpected first message %+v", messages[0])
+	}
+	if messages[1].From != "Viettel" || messages[1].Message != "KM 50% the nap" || messages[1].Encoding != "gsm7" {
+		t.Errorf("unexpected second message %+v", messages[1])
+	}
+
+	// Listing marked them read
+	if code := getJSON(t, srv.URL+"/api/v1/sms/inbox?status=unread", &messages); code != http.StatusOK || len(messages) != 0 {
+		t.Errorf("unread after listing: status %d, %+v", code, messages)
+	}
+
+	var msg model.SMS
+	if code := getJSON(t, srv.URL+"/api/v1/sms/inbox/"+strconv.Itoa(second), &msg); code != http.StatusOK || msg.From != "Viettel" || msg.Status != model.StatusRead {
+		t.Errorf("read: status %d, %+v", code, msg)
+	}
+
+	var ok model.SuccessResponse
+	if code := deleteJSON(t, srv.URL+"/api/v1/sms/inbox/"+strconv.Itoa(second), &ok); code != http.StatusOK || !ok.Success {
+		t.Errorf("delete: status %d", code)
+	}
+	var errResp model.ErrorResponse
+	if code := getJSON(t, srv.URL+"/api/v1/sms/inbox/"+strconv.Itoa(second), &errResp); code != http.StatusNotFound {
+		t.Errorf("read deleted message: status %d, want 404", code)
+	}
+	if code := getJSON(t, srv.URL+"/api/v1/sms/inbox?status=new", &errResp); code != http.StatusBadRequest {
+		t.Errorf("invalid status filter: status %d, want 400", code)
+	}
+
+	if code := deleteJSON(t, srv.URL+"/api/v1/sms/inbox", &ok); code != http.StatusOK {
+		t.Errorf("clear: status %d", code)
+	}
+	if n := len(modem.Inbox()); n != 0 {
+		t.Errorf("%d messages left after clearing", n)
+	}
+}
+
+func TestIncomingSMSWebhook(t *testing.T) {
+	events := make(chan model.WebhookEvent, 4)
+	var attempts int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first delivery fails and must be retried
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event model.WebhookEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer hook.Close()
+	t.Setenv("WEBHOOK_URLS", hook.URL)
+	t.Setenv("WEBHOOK_RETRY_DELAY", "1")
+
+	srv, modem := newTestServer(t, "sim://router-webhook")
+	// Any request opens the modem session, which enables +CMTI
+	var messages []model.SMS
+	getJSON(t, srv.URL+"/api/v1/sms/inbox", &messages)
+
+	modem.InjectSMS("+84987654321", "Cho tôi hỏi giá gói cước")
+
+	select {
+	case event := <-events:
+		data, _ := json.Marshal(event.Data)
+		var msg model.SMS
+		json.Unmarshal(data, &msg)
+		if event.Event != model.EventSMSReceived || msg.ID == "" || msg.From != "+84987654321" ||
+			msg.Message != "Cho tôi hỏi giá gói cước" || msg.Port != "sim://router-webhook" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 2 {
+		t.Errorf("expected 2 webhook attempts, got %d", n)
+	}
+}
+
+func TestIncomingSMSFromMessageMemory(t *testing.T) {
+	events := make(chan model.WebhookEvent, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event model.WebhookEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer hook.Close()
+	t.Setenv("WEBHOOK_URLS", hook.URL)
+	t.Setenv("SMS_DELETE_RECEIVED", "true")
+
+	srv, modem := newTestServer(t, "sim://router-memory")
+	// Received messages go to phone memory while SIM storage stays selected
+	modem.MessageMemory = "ME"
+	var messages []model.SMS
+	getJSON(t, srv.URL+"/api/v1/sms/inbox", &messages)
+
+	modem.InjectSMS("+84987654321", "Tin nhắn trong bộ nhớ máy")
+
+	select {
+	case event := <-events:
+		data, _ := json.Marshal(event.Data)
+		var msg model.SMS
+		json.Unmarshal(data, &msg)
+		if msg.From != "+84987654321" || msg.Message != "Tin nhắn trong bộ nhớ máy" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+
+	// The message is deleted once delivered and SIM storage selected again
+	deadline := time.Now().Add(2 * time.Second)
+	for (len(modem.Inbox()) > 0 || modem.Memory() != "SM") && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := len(modem.Inbox()); n != 0 {
+		t.Errorf("expected the message to be deleted, %d left", n)
+	}
+	if memory := modem.Memory(); memory != "SM" {
+		t.Errorf("selected memory = %q, want SM", memory)
+	}
+}
+
+func TestIncomingSMSKeptUntilDelivered(t *testing.T) {
+	calls := make(chan struct{}, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		calls <- struct{}{}
+	}))
+	defer hook.Close()
+	t.Setenv("SMS_DELETE_RECEIVED", "true")
+
+	for _, tt := range []struct {
+		name     string
+		webhooks string
+	}{
+		{"webhook rejects", hook.URL},
+		{"no webhook", ""},
+	} {
+		t.Setenv("WEBHOOK_URLS", tt.webhooks)
+		srv, modem := newTestServer(t, "sim://router-kept-"+strings.ReplaceAll(tt.name, " ", "-"))
+		var messages []model.SMS
+		getJSON(t, srv.URL+"/api/v1/sms/inbox", &messages)
+
+		modem.InjectSMS("+84987654321", "Đừng xoá tin này")
+		if tt.webhooks != "" {
+			select {
+			case <-calls:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s: webhook was not called", tt.name)
+			}
+		}
+		time.Sleep(200 * time.Millisecond)
+		if n := len(modem.Inbox()); n != 1 {
+			t.Errorf("%s: %d messages left on the modem, want 1", tt.name, n)
+		}
+	}
+}
+
+func TestIncomingConcatenatedSMS(t *testing.T) {
+	events := make(chan model.WebhookEvent, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event model.WebhookEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer hook.Close()
+	t.Setenv("WEBHOOK_URLS", hook.URL)
+	t.Setenv("SMS_REASSEMBLY_TIMEOUT", "1")
+
+	srv, modem := newTestServer(t, "sim://router-concat-in")
+	var messages []model.SMS
+	getJSON(t, srv.URL+"/api/v1/sms/inbox", &messages)
+
+	next := func() model.SMS {
+		t.Helper()
+		select {
+		case event := <-events:
+			data, _ := json.Marshal(event.Data)
+			var msg model.SMS
+			json.Unmarshal(data, &msg)
+			if msg.Partial != (event.Event == model.EventSMSPartial) {
+				t.Errorf("event %s for %+v", event.Event, msg)
+			}
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("webhook was not called")
+			return model.SMS{}
+		}
+	}
+
+	long := strings.Repeat("Giao dịch thành công. ", 10)
+	if parts := modem.InjectConcatenatedSMS("Vietcombank", long); len(parts) != 4 {
+		t.Fatalf("expected 4 parts, got %d", len(parts))
+	}
+	msg := next()
+	if msg.Message != long || msg.Parts != 4 || len(msg.Segments) != 4 || msg.Partial {
+		t.Errorf("unexpected reassembled message %+v", msg)
+	}
+
+	modem.InjectConcatenatedSMS("Vietcombank", long, 2)
+	msg = next()
+	if !msg.Partial || msg.ErrorMsg != "missing part 2 of 4" || len(msg.Segments) != 3 {
+		t.Errorf("unexpected partial message %+v", msg)
+	}
+}
+
+func TestPDUTools(t *testing.T) {
+	srv, _ := newTestServer(t, "sim://router-tools")
+
+	var info model.PDUInfo
+	code := postJSON(t, srv.URL+"/api/v1/tools/pdu/decode", model.PDUDecodeRequest{
+		PDU: "07917283010010F5040BC87238880900F10000623092516195800AE8329BFD4697D9EC37",
+	}, &info)
+	if code != http.StatusOK || info.Type != "SMS-DELIVER" || info.Sender != "27838890001" || info.Text != "hellohello" ||
+		info.Timestamp != "2026-03-29T15:16:59+02:00" {
+		t.Errorf("decode deliver: status %d, %+v", code, info)
+	}
+
+	var encoded model.PDUEncodeResponse
+	code = postJSON(t, srv.URL+"/api/v1/tools/pdu/encode", model.PDUEncodeRequest{
+		To:              "+84912345678",
+		Message:         strings.Repeat("Xin chào bạn ", 10),
+		Class:           "0",
+		ValidityMinutes: 60,
+		StatusReport:    true,
+	}, &encoded)
+	if code != http.StatusOK || encoded.Encoding != "ucs2" || len(encoded.Parts) != 2 {
+		t.Fatalf("encode: status %d, %+v", code, encoded)
+	}
+
+	// Decoding an encoded part gives back its fields
+	code = postJSON(t, srv.URL+"/api/v1/tools/pdu/decode", model.PDUDecodeRequest{PDU: encoded.Parts[1].PDU}, &info)
+	if code != http.StatusOK || info.Type != "SMS-SUBMIT" || info.Destination != "+84912345678" || info.Class != "0" ||
+		info.ValidityPeriod != "1h0m0s" || !info.StatusReport || info.Concat == nil || info.Concat.Part != 2 || info.Concat.Total != 2 {
+		t.Errorf("decode submit: status %d, %+v", code, info)
+	}
+
+	var errResp model.ErrorResponse
+	for _, bad := range []string{"zz", "00", "0791448720003023062E0B91"} {
+		if code := postJSON(t, srv.URL+"/api/v1/tools/pdu/decode", model.PDUDecodeRequest{PDU: bad}, &errResp); code != http.StatusBadRequest {
+			t.Errorf("decode %q: status %d, want 400", bad, code)
+		}
+	}
+	if code := postJSON(t, srv.URL+"/api/v1/tools/pdu/encode", model.PDUEncodeRequest{To: "0912345678", Message: "x", Class: "5"}, &errResp); code != http.StatusBadRequest {
+		t.Errorf("encode with class 5: status %d, want 400", code)
+	}
+}
+
+func TestUSSDSession(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-ussd")
+	modem.SetUSSDMenu("*098#", "Chon goi:\n1. Data\n2. Thoai")
+	modem.SetUSSDMenu("*098#>1", "1. Goi 7 ngay\n2. Goi 30 ngay")
+	modem.SetUSSD("*098#>1>2", "Dang ky thanh cong goi 30 ngay")
+
+	var session model.USSDSession
+	code := postJSON(t, srv.URL+"/api/v1/ussd", model.USSDRequest{Code: "*098#"}, &session)
+	if code != http.StatusOK || !session.Active || session.Status != 1 || session.Message != "Chon goi:\n1. Data\n2. Thoai" || session.ExpiresAt == nil {
+		t.Fatalf("start: status %d, %+v", code, session)
+	}
+
+	// The session holds the port until it ends
+	var errResp model.ErrorResponse
+	if code := postJSON(t, srv.URL+"/api/v1/ussd?wait=false", model.USSDRequest{Code: "*101#"}, &errResp); code != http.StatusConflict {
+		t.Errorf("second session: status %d, want 409", code)
+	}
+
+	sessionURL := srv.URL + "/api/v1/ussd/" + session.ID
+	code = postJSON(t, sessionURL+"/reply", model.USSDReplyRequest{Input: "1"}, &session)
+	if code != http.StatusOK || !session.Active || session.Message != "1. Goi 7 ngay\n2. Goi 30 ngay" {
+		t.Fatalf("first reply: status %d, %+v", code, session)
+	}
+	if code := getJSON(t, sessionURL, &session); code != http.StatusOK || session.Code != "*098#" {
+		t.Errorf("get: status %d, %+v", code, session)
+	}
+
+	code = postJSON(t, sessionURL+"/reply", model.USSDReplyRequest{Input: "2"}, &session)
+	if code != http.StatusOK || session.Active || session.Status != 0 || session.Message != "Dang ky thanh cong goi 30 ngay" {
+		t.Fatalf("last reply: status %d, %+v", code, session)
+	}
+	if code := postJSON(t, sessionURL+"/reply", model.USSDReplyRequest{Input: "1"}, &errResp); code != http.StatusNotFound {
+		t.Errorf("reply to a closed session: status %d, want 404", code)
+	}
+
+	// Cancelling sends AT+CUSD=2 and frees the port for the next session
+	code = postJSON(t, srv.URL+"/api/v1/ussd?wait=false", model.USSDRequest{Code: "*098#"}, &session)
+	if code != http.StatusOK || !session.Active {
+		t.Fatalf("restart: status %d, %+v", code, session)
+	}
+	if code := deleteJSON(t, srv.URL+"/api/v1/ussd/"+session.ID, &session); code != http.StatusOK || session.Active {
+		t.Errorf("cancel: status %d, %+v", code, session)
+	}
+	if path := modem.USSDSession(); path != "" {
+		t.Errorf("modem still in menu %q", path)
+	}
+
+	if code := postJSON(t, srv.URL+"/api/v1/ussd?wait=false", model.USSDRequest{Code: "*999#"}, &errResp); code != http.StatusBadRequest {
+		t.Errorf("unsupported code: status %d, want 400", code)
+	}
+	if code := postJSON(t, srv.URL+"/api/v1/ussd", model.USSDRequest{Code: "098"}, &errResp); code != http.StatusBadRequest {
+		t.Errorf("malformed code: status %d, want 400", code)
+	}
+}
+
+func TestUSSDSessionTimeout(t *testing.T) {
+	t.Setenv("MODEM_USSD_SESSION_TIMEOUT", "1")
+	srv, modem := newTestServer(t, "sim://router-ussd-timeout")
+	modem.SetUSSDMenu("*098#", "1. Data\n2. Thoai")
+
+	var session model.USSDSession
+	if code := postJSON(t, srv.URL+"/api/v1/ussd", model.USSDRequest{Code: "*098#"}, &session); code != http.StatusOK || !session.Active {
+		t.Fatalf("start: status %d, %+v", code, session)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	var errResp model.ErrorResponse
+	if code := getJSON(t, srv.URL+"/api/v1/ussd/"+session.ID, &errResp); code != http.StatusNotFound {
+		t.Errorf("expired session: status %d, want 404", code)
+	}
+	if path := modem.USSDSession(); path != "" {
+		t.Errorf("modem still in menu %q", path)
+	}
+	if code := postJSON(t, srv.URL+"/api/v1/ussd?wait=false", model.USSDRequest{Code: "*098#"}, &session); code != http.StatusOK {
+		t.Errorf("port not released: status %d", code)
+	}
+}
+
+func TestUSSDSessionUCS2Charset(t *testing.T) {
+	t.Setenv("MODEM_USSD_CHARSET", "UCS2")
+	srv, modem := newTestServer(t, "sim://router-ussd-ucs2")
+	modem.SetUSSDMenu("*098#", "Chọn gói:\n1. Dữ liệu")
+	modem.SetUSSD("*098#>1", "Đăng ký thành công")
+
+	// In the UCS2 character set codes, replies and answers travel as hex
+	var session model.USSDSession
+	code := postJSON(t, srv.URL+"/api/v1/ussd", model.USSDRequest{Code: "*098#"}, &session)
+	if code != http.StatusOK || !session.Active || session.Message != "Chọn gói:\n1. Dữ liệu" || !strings.HasPrefix(session.Raw, "004300681ECD006E") {
+		t.Fatalf("start: status %d, %+v", code, session)
+	}
+	code = postJSON(t, srv.URL+"/api/v1/ussd/"+session.ID+"/reply", model.USSDReplyRequest{Input: "1"}, &session)
+	if code != http.StatusOK || session.Active || session.Message != "Đăng ký thành công" {
+		t.Errorf("reply: status %d, %+v", code, session)
+	}
+}
+
+func TestPackages(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-packages")
+	modem.SetUSSD("*102#", "Goi ST90N: con 3.5GB, HSD 31/12/2026. Goi SMS100: con 45 SMS, HSD 20/11/2026")
+
+	check := func(where string, packages []model.Package) {
+		t.Helper()
+		if len(packages) != 2 || packages[0].Name != "ST90N" || packages[0].DataMB == nil || *packages[0].DataMB != 3584 ||
+			packages[1].Name != "SMS100" || packages[1].SMS == nil || *packages[1].SMS != 45 || packages[1].ExpiresAt != "2026-11-20" {
+			t.Errorf("%s packages = %+v", where, packages)
+		}
+	}
+
+	var info model.DeviceInfo
+	if code := getJSON(t, srv.URL+"/api/v1/device/info?port=sim://router-packages", &info); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	check("device info", info.Packages)
+
+	var ports struct {
+		Data []model.PortInfo `json:"data"`
+	}
+	if code := getJSON(t, srv.URL+"/api/v1/ports", &ports); code != http.StatusOK {
+		t.Fatalf("ports status %d", code)
+	}
+	for _, p := range ports.Data {
+		if p.Port == "sim://router-packages" {
+			check("port list", p.Packages)
+			return
+		}
+	}
+	t.Error("port missing from /api/v1/ports")
+}
+
+func TestUSSDProfiles(t *testing.T) {
+	t.Setenv("MODEM_USSD_PROFILES", "45201:balance=*888#")
+	srv, modem := newTestServer(t, "sim://router-profile")
+	modem.IMSI = "452010000000042"
+	modem.Operator = "Mobifone"
+	modem.SetUSSD("*888#", "TK chinh: 25.000 d, het han 31-12-2026")
+
+	// The code comes from the profile of the SIM's network, not the global one
+	var info model.DeviceInfo
+	if code := getJSON(t, srv.URL+"/api/v1/device/info?port=sim://router-profile", &info); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if b := info.Balance; b == nil || b.Operator != "Mobifone" || b.Amount == nil || *b.Amount != 25000 || b.ExpiresAt != "2026-12-31" {
+		t.Errorf("device info balance = %+v", b)
+	}
+
+	var ports struct {
+		Data []model.PortInfo `json:"data"`
+	}
+	if code := getJSON(t, srv.URL+"/api/v1/ports", &ports); code != http.StatusOK {
+		t.Fatalf("ports status %d", code)
+	}
+	found := false
+	for _, p := range ports.Data {
+		if p.Port != "sim://router-profile" {
+			continue
+		}
+		found = true
+		if p.Operator != "Mobifone" || p.Balance == nil || p.Balance.Amount == nil || *p.Balance.Amount != 25000 {
+			t.Errorf("port info = %+v", p)
+		}
+	}
+	if !found {
+		t.Error("port missing from /api/v1/ports")
+	}
+}
+
+func TestSIMNumberDiscovery(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-number")
+	modem.ICCID = "8984040000000000042"
+	modem.IMSI = "452040000000042"
+	modem.SetUSSD("*098#", "So thue bao cua Quy khach la 84912345678")
+
+	// AT+CNUM is empty, so the number comes from the operator's USSD code
+	var info model.DeviceInfo
+	if code := getJSON(t, srv.URL+"/api/v1/device/info?port=sim://router-number", &info); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if info.PhoneNumber != "84912345678" || info.NumberSource != model.NumberSourceUSSD || info.ICCID != modem.ICCID {
+		t.Errorf("device info = %s from %q, ICCID %s", info.PhoneNumber, info.NumberSource, info.ICCID)
+	}
+
+	// A manual assignment overrides the discovered number
+	var assigned model.SIMNumber
+	req := model.AssignNumberRequest{Port: "sim://router-number", PhoneNumber: "0987654321"}
+	if code := postJSON(t, srv.URL+"/api/v1/numbers", req, &assigned); code != http.StatusCreated {
+		t.Fatalf("assign status %d", code)
+	}
+	if assigned.ICCID != modem.ICCID || assigned.IMSI != modem.IMSI || assigned.Source != model.NumberSourceManual {
+		t.Errorf("assigned = %+v", assigned)
+	}
+	if getJSON(t, srv.URL+"/api/v1/device/info?port=sim://router-number", &info); info.PhoneNumber != "0987654321" || info.NumberSource != model.NumberSourceManual {
+		t.Errorf("after assignment: %s from %q", info.PhoneNumber, info.NumberSource)
+	}
+
+	var numbers []model.SIMNumber
+	if code := getJSON(t, srv.URL+"/api/v1/numbers", &numbers); code != http.StatusOK || len(numbers) != 1 {
+		t.Errorf("numbers = %d %+v", code, numbers)
+	}
+}
+
+func TestSIMNumberFromPhonebook(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-phonebook")
+	modem.ICCID = "8984050000000000007"
+	modem.IMSI = "452050000000007"
+	modem.Operator = "Vietnamobile"
+	modem.OwnNumbers = []string{"+84921234567"}
+
+	// Vietnamobile has no own-number code, so the "ON" phonebook is read
+	var info model.DeviceInfo
+	if code := getJSON(t, srv.URL+"/api/v1/device/info?port=sim://router-phonebook", &info); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if info.PhoneNumber != "+84921234567" || info.NumberSource != model.NumberSourcePhonebook {
+		t.Errorf("device info = %s from %q", info.PhoneNumber, info.NumberSource)
+	}
+}
+
+func TestMessageHistory(t *testing.T) {
+	srv, _ := newTestServer(t, "sim://router-history")
+
+	var resp model.SendSMSResponse
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{To: "0912345678", Message: "history"}, &resp); code != http.StatusOK {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	msg := waitForStatus(t, srv.URL+"/api/v1/sms/"+resp.MessageID)
+
+	var statuses []string
+	for _, change := range msg.History {
+		statuses = append(statuses, change.Status)
+	}
+	want := []string{model.StatusPending, model.StatusSending, model.StatusSent, model.StatusDelivered}
+	if strings.Join(statuses, ",") != strings.Join(want, ",") {
+		t.Errorf("history = %v, want %v", statuses, want)
+	}
+}
+
+func TestQueueRecoveryAfterCrash(t *testing.T) {
+	const portName = "sim://router-recovery"
+	path := filepath.Join(t.TempDir(), "messages.db")
+
+	// State left by a run that stopped in the middle of its queue
+	db, err := store.NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, msg := range []*model.SMS{
+		{ID: "SMS_1_queued", Status: model.StatusPending},
+		{ID: "SMS_2_unsent", Status: model.StatusSending},
+		{ID: "SMS_3_sent", Status: model.StatusSending, Parts: 1,
+			Segments: []model.SMSSegment{{Part: 1, Reference: 42}}},
+		{ID: "SMS_4_inflight", Status: model.StatusSending, Parts: 2,
+			Segments: []model.SMSSegment{{Part: 1, Reference: 43}, {Part: 2, Reference: -1, Status: model.SegmentSubmitting}}},
+	} {
+		msg.To, msg.Message, msg.Port, msg.Mode = "0912345678", "recovered "+msg.ID, portName, "text"
+		msg.CreatedAt = now
+		if err := db.Save(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	cfg := config.Load()
+	cfg.Modem.DefaultPort = portName
+	cfg.Modem.NumbersFile = filepath.Join(t.TempDir(), "sim_numbers.json")
+	cfg.SMS.StorePath = path
+	modem := simulator.Get(portName)
+	before := len(modem.Sent())
+
+	svc := service.NewSMSService(cfg)
+	svc.Start()
+	t.Cleanup(svc.Close)
+	srv := httptest.NewServer(NewRouter(cfg, svc))
+	t.Cleanup(srv.Close)
+
+	// Only the messages that never reached the modem are sent
+	for _, id := range []string{"SMS_1_queued", "SMS_2_unsent"} {
+		if msg := waitForStatus(t, srv.URL+"/api/v1/sms/"+id); msg.Status != model.StatusDelivered {
+			t.Errorf("%s: status %s (%s)", id, msg.Status, msg.ErrorMsg)
+		}
+	}
+	if sent := len(modem.Sent()) - before; sent != 2 {
+		t.Errorf("expected 2 messages sent after the restart, got %d", sent)
+	}
+
+	var msg model.SMS
+	getJSON(t, srv.URL+"/api/v1/sms/SMS_3_sent", &msg)
+	if msg.Status != model.StatusSent || msg.SentAt == nil {
+		t.Errorf("fully accepted message: %s", msg.Status)
+	}
+	getJSON(t, srv.URL+"/api/v1/sms/SMS_4_inflight", &msg)
+	if msg.Status != model.StatusFailed || !strings.Contains(msg.ErrorMsg, "part 2 of 2") {
+		t.Errorf("message interrupted mid-part: %s (%s)", msg.Status, msg.ErrorMsg)
+	}
+}
+
+func TestAsyncSend(t *testing.T) {
+	const portName = "sim://router-async"
+	srv, _ := newTestServer(t, portName)
+
+	var resp model.SendSMSResponse
+	req := model.SendSMSRequest{To: "0912345678", Message: "queued", Async: true}
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", req, &resp); code != http.StatusAccepted {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	if !resp.Success || resp.MessageID == "" || resp.Status != model.StatusPending {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	msg := waitForStatus(t, srv.URL+"/api/v1/sms/"+resp.MessageID)
+	if msg.Status != model.StatusDelivered || len(msg.Attempts) != 1 || msg.Port != portName ||
+		msg.SentAt == nil || msg.DeliveredAt == nil {
+		t.Errorf("unexpected message %+v", msg)
+	}
+
+	// A second message to another recipient, sent synchronously
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{To: "0987654321", Message: "direct"}, &resp); code != http.StatusOK {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	if resp.Status != model.StatusSent {
+		t.Errorf("synchronous send reported status %q", resp.Status)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{"", 2},
+		{"?to=%2B84912345678", 1},
+		{"?from=" + portName, 2},
+		{"?from=sim://router-other", 0},
+		{"?status=delivered&to=0912345678", 1},
+		{"?status=failed,expired", 0},
+	} {
+		var list []model.SMS
+		if code := getJSON(t, srv.URL+"/api/v1/sms"+tt.query, &list); code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", tt.query, code)
+		}
+		if len(list) != tt.want {
+			t.Errorf("GET /api/v1/sms%s: %d messages, want %d", tt.query, len(list), tt.want)
+		}
+	}
+
+	var errResp model.ErrorResponse
+	if code := getJSON(t, srv.URL+"/api/v1/sms?status=lost", &errResp); code != http.StatusBadRequest {
+		t.Errorf("unknown status: %d", code)
+	}
+}
+
+func TestSendPriority(t *testing.T) {
+	const portName = "sim://router-priority"
+	srv, modem := newTestServer(t, portName, func(cfg *config.Config) {
+		cfg.SMS.StarvationLimit = 2
+	})
+	before := len(modem.Sent())
+
+	// An open USSD menu holds the modem while the queue fills up
+	modem.SetUSSDMenu("*098#", "1. Data\n2. Thoai")
+	var session model.USSDSession
+	if code := postJSON(t, srv.URL+"/api/v1/ussd", model.USSDRequest{Code: "*098#"}, &session); code != http.StatusOK {
+		t.Fatalf("ussd: status %d", code)
+	}
+
+	send := func(message, priority string) {
+		var resp model.SendSMSResponse
+		req := model.SendSMSRequest{To: "0912345678", Message: message, Priority: priority, Async: true}
+		if code := postJSON(t, srv.URL+"/api/v1/sms/send", req, &resp); code != http.StatusAccepted {
+			t.Fatalf("%s: status %d, response %+v", message, code, resp)
+		}
+	}
+	var metrics model.MetricsResponse
+	waitForDepth := func(total int) {
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			getJSON(t, srv.URL+"/api/v1/metrics", &metrics)
+			depth := 0
+			for _, n := range metrics.QueueDepth {
+				depth += n
+			}
+			if depth == total || time.Now().After(deadline) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The first message is taken by the worker, which waits for the modem
+	send("n1", "")
+	waitForDepth(0)
+	for _, message := range []string{"n2", "n3", "n4"} {
+		send(message, model.PriorityNormal)
+	}
+	for _, message := range []string{"u1", "u2", "u3", "u4"} {
+		send(message, model.PriorityUrgent)
+	}
+	send("h1", model.PriorityHigh)
+
+	waitForDepth(8)
+	if len(metrics.Queues) != 1 || metrics.Queues[0].Port != portName || metrics.Queues[0].Total != 8 ||
+		metrics.Queues[0].Depth[model.PriorityUrgent] != 4 || metrics.Queues[0].Depth[model.PriorityHigh] != 1 ||
+		metrics.Queues[0].Depth[model.PriorityNormal] != 3 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+
+	var errResp model.ErrorResponse
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", model.SendSMSRequest{To: "0912345678", Message: "x", Priority: "bulk"}, &errResp); code != http.StatusBadRequest {
+		t.Errorf("unknown priority: status %d, want 400", code)
+	}
+
+	if code := deleteJSON(t, srv.URL+"/api/v1/ussd/"+session.ID, &session); code != http.StatusOK {
+		t.Fatalf("cancel ussd: status %d", code)
+	}
+	waitForDepth(0)
+	deadline := time.Now().Add(3 * time.Second)
+	for len(modem.Sent())-before < 9 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Urgent first, but a lane passed over twice in a row goes next
+	var order []string
+	for _, sent := range modem.Sent()[before:] {
+		order = append(order, sent.Payload)
+	}
+	want := "n1,u1,u2,n2,h1,u3,n3,u4,n4"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("send order %s, want %s", got, want)
+	}
+}
+
+func patchJSON(t *testing.T, url string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+// waitForSchedule polls a scheduled send until done returns true for it
+func waitForSchedule(t *testing.T, url string, done func(sch model.ScheduledSMS) bool) model.ScheduledSMS {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var sch model.ScheduledSMS
+		if code := getJSON(t, url, &sch); code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", url, code)
+		}
+		if done(sch) || time.Now().After(deadline) {
+			return sch
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestScheduledSend(t *testing.T) {
+	srv, _ := newTestServer(t, "sim://router-schedule")
+
+	sendAt := time.Now().Add(300 * time.Millisecond)
+	var resp model.SendSMSResponse
+	req := model.SendSMSRequest{To: "0912345678", Message: "later", SendAt: &sendAt}
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", req, &resp); code != http.StatusAccepted {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	if !resp.Success || resp.ScheduleID == "" || resp.MessageID != "" || resp.Status != model.ScheduleActive ||
+		resp.SendAt == nil || !resp.SendAt.Equal(sendAt) {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	var upcoming []model.ScheduledSMS
+	if getJSON(t, srv.URL+"/api/v1/sms/scheduled", &upcoming); len(upcoming) != 1 || upcoming[0].ID != resp.ScheduleID {
+		t.Errorf("upcoming sends: %+v", upcoming)
+	}
+
+	sch := waitForSchedule(t, srv.URL+"/api/v1/sms/scheduled/"+resp.ScheduleID, func(sch model.ScheduledSMS) bool {
+		return sch.Status == model.ScheduleDone
+	})
+	if sch.Runs != 1 || sch.LastMessageID == "" || sch.NextRunAt != nil || sch.LastRunAt.Before(sendAt) {
+		t.Fatalf("unexpected schedule after its run %+v", sch)
+	}
+	if msg := waitForStatus(t, srv.URL+"/api/v1/sms/"+sch.LastMessageID); msg.Status != model.StatusDelivered || msg.ScheduleID != sch.ID {
+		t.Errorf("message of the run: %s, schedule %q", msg.Status, msg.ScheduleID)
+	}
+	if getJSON(t, srv.URL+"/api/v1/sms/scheduled", &upcoming); len(upcoming) != 0 {
+		t.Errorf("a sent one-off must no longer be upcoming: %+v", upcoming)
+	}
+	if getJSON(t, srv.URL+"/api/v1/sms/scheduled?status=done", &upcoming); len(upcoming) != 1 {
+		t.Errorf("done sends: %+v", upcoming)
+	}
+
+	var errResp model.ErrorResponse
+	if code := deleteJSON(t, srv.URL+"/api/v1/sms/scheduled/"+sch.ID, &errResp); code != http.StatusConflict {
+		t.Errorf("cancelling a done send: status %d, want 409", code)
+	}
+}
+
+func TestRecurringSend(t *testing.T) {
+	srv, modem := newTestServer(t, "sim://router-recurring")
+	before := len(modem.Sent())
+
+	// First run now, then every minute
+	var resp model.SendSMSResponse
+	sendAt := time.Now().Add(300 * time.Millisecond)
+	req := model.SendSMSRequest{To: "0912345678", Message: "every minute", SendAt: &sendAt, Recurrence: "* * * * *"}
+	if code := postJSON(t, srv.URL+"/api/v1/sms/send", req, &resp); code != http.StatusAccepted {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	url := srv.URL + "/api/v1/sms/scheduled/" + resp.ScheduleID
+
+	sch := waitForSchedule(t, url, func(sch model.ScheduledSMS) bool { return sch.Runs >= 1 })
+	if sch.Runs < 1 || sch.Status != model.ScheduleActive || sch.NextRunAt == nil ||
+		sch.NextRunAt.Second() != 0 || !sch.NextRunAt.After(sendAt) {
+		t.Fatalf("unexpected schedule %+v", sch)
+	}
+
+	// Weekdays at 8:00 in Hanoi, whatever the zone of the server
+	recurrence := "0 8 * * 1-5"
+	var updated model.ScheduledSMS
+	if code := patchJSON(t, url, model.RescheduleRequest{Recurrence: &recurrence, TimeZone: "Asia/Ho_Chi_Minh"}, &updated); code != http.StatusOK {
+		t.Fatalf("reschedule: status %d", code)
+	}
+	hanoi, _ := time.LoadLocation("Asia/Ho_Chi_Minh")
+	next := updated.NextRunAt.In(hanoi)
+	if updated.Recurrence != recurrence || next.Hour() != 8 || next.Minute() != 0 ||
+		next.Weekday() == time.Saturday || next.Weekday() == time.Sunday || !next.After(time.Now()) {
+		t.Errorf("unexpected next run %s of %+v", next, updated)
+	}
+	var errResp model.ErrorResponse
+	past := time.Now().Add(-time.Minute)
+	if code := patchJSON(t, url, model.RescheduleRequest{SendAt: &past}, &errResp); code != http.StatusBadRequest {
+		t.Errorf("rescheduling into the past: status %d, want 400", code)
+	}
+
+	var cancelled model.ScheduledSMS
+	if code := deleteJSON(t, url, &cancelled); code != http.StatusOK || cancelled.Status != model.ScheduleCancelled || cancelled.NextRunAt != nil {
+		t.Fatalf("cancel: status %d, %+v", code, cancelled)
+	}
+	sent := len(modem.Sent()) - before
+	if sent != sch.Runs && sent != sch.Runs+1 {
+		t.Errorf("%d messages sent for %d runs", sent, sch.Runs)
+	}
+
+	sendAt = time.Now().Add(time.Minute)
+	if code := patchJSON(t, url, model.RescheduleRequest{SendAt: &sendAt}, &errResp); code != http.StatusConflict {
+		t.Errorf("rescheduling a cancelled send: status %d, want 409", code)
+	}
+	if code := getJSON(t, srv.URL+"/api/v1/sms/scheduled/SCH_0_missing", &errResp); code != http.StatusNotFound {
+		t.Errorf("unknown schedule: status %d, want 404", code)
+	}
+	for _, req := range []model.SendSMSRequest{
+		{To: "0912345678", Message: "x", Recurrence: "every day"},
+		{To: "0912345678", Message: "x", Recurrence: "@every 1s"},
+		{To: "0912345678", Message: "x", Recurrence: "0 8 * * *", TimeZone: "Mars/Olympus"},
+		{To: "0912345678", Message: "x", SendAt: &past},
+	} {
+		if code := postJSON(t, srv.URL+"/api/v1/sms/send", req, &errResp); code != http.StatusBadRequest {
+			t.Errorf("%+v: status %d, want 400", req, code)
+		}
+	}
+}
+
+func TestScheduleSurvivesRestart(t *testing.T) {
+	const portName = "sim://router-schedule-restart"
+	cfg := config.Load()
+	cfg.Modem.DefaultPort = portName
+	cfg.Modem.NumbersFile = filepath.Join(t.TempDir(), "sim_numbers.json")
+	cfg.SMS.StorePath = filepath.Join(t.TempDir(), "messages.db")
+
+	// Scheduled, and the gateway stops before it is due
+	first := service.NewSMSService(cfg)
+	sendAt := time.Now().Add(500 * time.Millisecond)
+	resp, err := first.SendSMS(context.Background(), &model.SendSMSRequest{To: "0912345678", Message: "after restart", SendAt: &sendAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+
+	svc := service.NewSMSService(cfg)
+	svc.Start()
+	t.Cleanup(svc.Close)
+	srv := httptest.NewServer(NewRouter(cfg, svc))
+	t.Cleanup(srv.Close)
+
+	sch := waitForSchedule(t, srv.URL+"/api/v1/sms/scheduled/"+resp.ScheduleID, func(sch model.ScheduledSMS) bool {
+		return sch.Status == model.ScheduleDone
+	})
+	if sch.Runs != 1 {
+		t.Fatalf("schedule did not run after the restart: %+v", sch)
+	}
+	if msg := waitForStatus(t, srv.URL+"/api/v1/sms/"+sch.LastMessageID); msg.Status != model.StatusDelivered {
+		t.Errorf("message of the run: %s (%s)", msg.Status, msg.ErrorMsg)
+	}
+}
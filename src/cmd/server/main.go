@@ -13,6 +13,7 @@ import (
 	"sms-gateway/src/api/router"
 	"sms-gateway/src/internal/config"
 	"sms-gateway/src/internal/service"
+	"sms-gateway/src/pkg/simulator"
 )
 
 const version = "2.0.0"
@@ -24,8 +25,19 @@ func main() {
 
 	log.Printf("Starting SMS Gateway API v%s", version)
 
+	// Register simulated modems so they show up in port listings
+	simulated := cfg.Modem.SimulatedPorts
+	if simulator.IsSimulated(cfg.Modem.DefaultPort) {
+		simulated = append(simulated, cfg.Modem.DefaultPort)
+	}
+	for _, name := range simulated {
+		simulator.Get(name)
+		log.Printf("Simulated modem registered on %s", name)
+	}
+
 	// Initialize services
 	smsService := service.NewSMSService(cfg)
+	smsService.Start()
 
 	// Setup router
 	r := router.NewRouter(cfg, smsService)
@@ -60,6 +72,7 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	smsService.Close()
 
 	log.Println("Server exited")
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,6 +13,7 @@ type Config struct {
 	Server  ServerConfig
 	Modem   ModemConfig
 	SMS     SMSConfig
+	Webhook WebhookConfig
 }
 
 // ServerConfig holds server configuration
@@ -29,14 +31,49 @@ type ModemConfig struct {
 	Timeout         time.Duration
 	BalanceUSSD     string
 	PackagesUSSD    string
+	SimulatedPorts  []string // virtual modems such as "sim://modem1"
+	Ports           []string // modems kept open from startup
+	HealthInterval  time.Duration
+	LeaseWait       time.Duration // how long read-only queries wait for a busy port
+
+	USSDTimeout        time.Duration // how long each USSD answer is awaited
+	USSDSessionTimeout time.Duration // idle time before an open USSD menu is cancelled
+	USSDCharset        string        // AT+CSCS selected before USSD, "" keeps the current one
+	// USSDProfiles override the USSD codes of a network, e.g.
+	// "45204:balance=*101#;packages=*102#;msisdn=*098#"
+	USSDProfiles []string
+	// NumbersFile keeps the own numbers of the SIMs across restarts
+	NumbersFile string
 }
 
 // SMSConfig holds SMS configuration
 type SMSConfig struct {
-	MaxLength      int
-	DefaultTimeout int
-	RetryCount     int
-	RetryDelay     time.Duration
+	// MaxLength is the number of characters per message, concatenated parts
+	// included. It defaults to 1530 (ten parts) since long messages are
+	// split; set 160 to accept single messages only, as before.
+	MaxLength       int
+	DefaultTimeout  int
+	RetryCount      int           // retries after the first attempt of a send
+	RetryDelay      time.Duration // first backoff, doubled after every failure
+	RetryFailover   bool          // retry on another ready modem
+	StarvationLimit int           // more urgent sends in a row before a waiting less urgent one, 0 for strict priority
+	ConcatRef16     bool          // use 16-bit instead of 8-bit concatenation references
+	DeliveryReports bool          // request status reports for every message
+	DeleteReceived  bool          // delete messages announced by +CMTI from the modem once every webhook accepted them
+	// ReassemblyTimeout is how long the parts of an incoming concatenated
+	// message are awaited before it is passed on incomplete
+	ReassemblyTimeout time.Duration
+	// StorePath is the database of outbound messages, "" keeps them in
+	// memory only
+	StorePath string
+}
+
+// WebhookConfig holds the endpoints received messages are posted to
+type WebhookConfig struct {
+	URLs       []string
+	Timeout    time.Duration // per request
+	MaxRetries int           // retries after the first attempt
+	RetryDelay time.Duration // first backoff, doubled after every failure
 }
 
 // Load loads configuration from environment variables with defaults
@@ -54,12 +91,36 @@ func Load() *Config {
 			Timeout:         time.Duration(getEnvAsInt("MODEM_TIMEOUT", 30)) * time.Second,
 			BalanceUSSD:     getEnv("MODEM_BALANCE_USSD", ""),
 			PackagesUSSD:    getEnv("MODEM_PACKAGES_USSD", ""),
+			SimulatedPorts:  getEnvAsList("MODEM_SIMULATED_PORTS", nil),
+			Ports:           getEnvAsList("MODEM_PORTS", nil),
+			HealthInterval:  time.Duration(getEnvAsInt("MODEM_HEALTH_INTERVAL", 30)) * time.Second,
+			LeaseWait:       time.Duration(getEnvAsInt("MODEM_LEASE_WAIT", 5)) * time.Second,
+
+			USSDTimeout:        time.Duration(getEnvAsInt("MODEM_USSD_TIMEOUT", 30)) * time.Second,
+			USSDSessionTimeout: time.Duration(getEnvAsInt("MODEM_USSD_SESSION_TIMEOUT", 60)) * time.Second,
+			USSDCharset:        strings.ToUpper(getEnv("MODEM_USSD_CHARSET", "")),
+			USSDProfiles:       getEnvAsList("MODEM_USSD_PROFILES", nil),
+			NumbersFile:        getEnv("MODEM_NUMBERS_FILE", "data/sim_numbers.json"),
 		},
 		SMS: SMSConfig{
-			MaxLength:      getEnvAsInt("SMS_MAX_LENGTH", 160),
-			DefaultTimeout: getEnvAsInt("SMS_DEFAULT_TIMEOUT", 30),
-			RetryCount:     getEnvAsInt("SMS_RETRY_COUNT", 3),
-			RetryDelay:     time.Duration(getEnvAsInt("SMS_RETRY_DELAY", 2)) * time.Second,
+			MaxLength:       getEnvAsInt("SMS_MAX_LENGTH", 1530),
+			DefaultTimeout:  getEnvAsInt("SMS_DEFAULT_TIMEOUT", 30),
+			RetryCount:      getEnvAsInt("SMS_RETRY_COUNT", 3),
+			RetryDelay:      time.Duration(getEnvAsInt("SMS_RETRY_DELAY", 2)) * time.Second,
+			RetryFailover:   getEnvAsBool("SMS_RETRY_FAILOVER", false),
+			StarvationLimit: getEnvAsInt("SMS_STARVATION_LIMIT", 10),
+			ConcatRef16:     getEnvAsBool("SMS_CONCAT_16BIT_REF", false),
+			DeliveryReports: getEnvAsBool("SMS_DELIVERY_REPORTS", true),
+			DeleteReceived:  getEnvAsBool("SMS_DELETE_RECEIVED", false),
+
+			ReassemblyTimeout: time.Duration(getEnvAsInt("SMS_REASSEMBLY_TIMEOUT", 300)) * time.Second,
+			StorePath:         getEnv("SMS_STORE_PATH", "data/messages.db"),
+		},
+		Webhook: WebhookConfig{
+			URLs:       getEnvAsList("WEBHOOK_URLS", nil),
+			Timeout:    time.Duration(getEnvAsInt("WEBHOOK_TIMEOUT", 10)) * time.Second,
+			MaxRetries: getEnvAsInt("WEBHOOK_MAX_RETRIES", 5),
+			RetryDelay: time.Duration(getEnvAsInt("WEBHOOK_RETRY_DELAY", 1)) * time.Second,
 		},
 	}
 }
@@ -81,3 +142,28 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsBool gets environment variable as boolean with default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList gets a comma separated environment variable as a list
+func getEnvAsList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/utils"
+	"sms-gateway/src/pkg/sms"
+)
+
+// HandleInbox lists or clears the messages stored on a modem
+// @Summary List or clear received SMS
+// @Description GET lists the messages stored on the SIM (AT+CMGL), DELETE removes all of them (AT+CMGD=1,4)
+// @Tags Inbox
+// @Produce json
+// @Param port query string false "Port name (defaults to configured default port)"
+// @Param status query string false "GET only: all (default), unread or read"
+// @Param wait query bool false "Wait for a busy port (default true); false fails fast with 409"
+// @Success 200 {array} model.SMS "Received messages"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 409 {object} model.ErrorResponse "Port is in use"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /api/v1/sms/inbox [get]
+// @Router /api/v1/sms/inbox [delete]
+func (h *SMSHandler) HandleInbox(w http.ResponseWriter, r *http.Request) {
+	port := h.inboxPort(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		stat := sms.ListAll
+		switch r.URL.Query().Get("status") {
+		case "", "all":
+		case model.StatusUnread:
+			stat = sms.ListUnread
+		case model.StatusRead:
+			stat = sms.ListRead
+		default:
+			h.writeError(w, http.StatusBadRequest, "status must be all, unread or read")
+			return
+		}
+
+		messages, err := h.smsService.ListInbox(r.Context(), port, stat, waitForPort(r))
+		if err != nil {
+			h.writeError(w, portErrorStatus(err), err.Error())
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, messages)
+	case http.MethodDelete:
+		if err := h.smsService.ClearInbox(r.Context(), port, waitForPort(r)); err != nil {
+			h.writeError(w, portErrorStatus(err), err.Error())
+			return
+		}
+		h.writeDeleted(w, "All messages deleted")
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use GET or DELETE.")
+	}
+}
+
+// HandleInboxMessage reads or deletes one stored message
+// @Summary Read or delete a received SMS
+// @Description GET reads the message at a storage index (AT+CMGR), DELETE removes it (AT+CMGD)
+// @Tags Inbox
+// @Produce json
+// @Param index path int true "Storage index"
+// @Param port query string false "Port name (defaults to configured default port)"
+// @Param wait query bool false "Wait for a busy port (default true); false fails fast with 409"
+// @Success 200 {object} model.SMS "Received message"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 404 {object} model.ErrorResponse "No message at this index"
+// @Failure 409 {object} model.ErrorResponse "Port is in use"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /api/v1/sms/inbox/{index} [get]
+// @Router /api/v1/sms/inbox/{index} [delete]
+func (h *SMSHandler) HandleInboxMessage(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 {
+		h.writeError(w, http.StatusBadRequest, "index must be a non-negative number")
+		return
+	}
+	port := h.inboxPort(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		msg, err := h.smsService.ReadInbox(r.Context(), port, index, waitForPort(r))
+		if err != nil {
+			status := portErrorStatus(err)
+			if errors.Is(err, sms.ErrNoMessage) {
+				status = http.StatusNotFound
+			}
+			h.writeError(w, status, err.Error())
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, msg)
+	case http.MethodDelete:
+		if err := h.smsService.DeleteInbox(r.Context(), port, index, waitForPort(r)); err != nil {
+			h.writeError(w, portErrorStatus(err), err.Error())
+			return
+		}
+		h.writeDeleted(w, "Message "+strconv.Itoa(index)+" deleted")
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use GET or DELETE.")
+	}
+}
+
+// inboxPort returns the port query parameter or the default port
+func (h *SMSHandler) inboxPort(r *http.Request) string {
+	if port := r.URL.Query().Get("port"); port != "" {
+		return port
+	}
+	return h.config.Modem.DefaultPort
+}
+
+// writeDeleted confirms a deletion
+func (h *SMSHandler) writeDeleted(w http.ResponseWriter, message string) {
+	utils.WriteJSON(w, http.StatusOK, model.SuccessResponse{
+		Success:   true,
+		Message:   message,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
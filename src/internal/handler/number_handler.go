@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/utils"
+	"sms-gateway/src/pkg/modem"
+	"sms-gateway/src/pkg/validation"
+)
+
+// HandleNumbers lists or assigns the own numbers of SIMs
+// @Summary List or assign SIM numbers
+// @Description GET lists the own number (MSISDN) known for every SIM, keyed by ICCID and IMSI, with its source: cnum, ussd, phonebook or manual. POST assigns a number by hand to the SIM in a port, or to the SIM with the given ICCID or IMSI; assigned numbers take precedence over discovered ones and survive restarts.
+// @Tags Device
+// @Accept json
+// @Produce json
+// @Param request body model.AssignNumberRequest false "Number and SIM (POST only)"
+// @Param wait query bool false "Wait for a busy port (default true); false fails fast with 409"
+// @Success 200 {array} model.SIMNumber "Known numbers (GET)"
+// @Success 201 {object} model.SIMNumber "Assigned number (POST)"
+// @Failure 400 {object} model.ErrorResponse "Invalid request"
+// @Failure 409 {object} model.ErrorResponse "Port is in use or the SIM cannot be identified"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /api/v1/numbers [get]
+// @Router /api/v1/numbers [post]
+func (h *SMSHandler) HandleNumbers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		numbers, err := h.smsService.ListNumbers()
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if numbers == nil {
+			numbers = []model.SIMNumber{}
+		}
+		utils.WriteJSON(w, http.StatusOK, numbers)
+	case http.MethodPost:
+		var req model.AssignNumberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+			return
+		}
+		if err := validation.ValidatePhoneNumber(req.PhoneNumber); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		number, err := h.smsService.AssignNumber(r.Context(), &req, waitForPort(r))
+		if err != nil {
+			status := portErrorStatus(err)
+			if errors.Is(err, modem.ErrNoSIMIdentity) {
+				status = http.StatusConflict
+			}
+			h.writeError(w, status, err.Error())
+			return
+		}
+		utils.WriteJSON(w, http.StatusCreated, number)
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use GET or POST.")
+	}
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/service"
+	"sms-gateway/src/internal/store"
+	"sms-gateway/src/internal/utils"
+	"sms-gateway/src/pkg/validation"
+)
+
+// HandleSchedules lists scheduled sends
+// @Summary List scheduled SMS
+// @Description List scheduled and recurring sends, next run first. Only upcoming ones are listed unless status says otherwise.
+// @Tags Schedule
+// @Produce json
+// @Param status query string false "Comma-separated statuses: scheduled (default), done, cancelled, or all"
+// @Success 200 {array} model.ScheduledSMS "Scheduled sends"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /api/v1/sms/scheduled [get]
+func (h *SMSHandler) HandleSchedules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use GET.")
+		return
+	}
+
+	statuses := []string{model.ScheduleActive}
+	if query := r.URL.Query().Get("status"); query == "all" {
+		statuses = nil
+	} else if query != "" {
+		statuses = nil
+		for _, status := range strings.Split(query, ",") {
+			switch status = strings.TrimSpace(status); status {
+			case model.ScheduleActive, model.ScheduleDone, model.ScheduleCancelled:
+				statuses = append(statuses, status)
+			default:
+				h.writeError(w, http.StatusBadRequest, "unknown status "+status)
+				return
+			}
+		}
+	}
+
+	schedules, err := h.smsService.ListSchedules(statuses...)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if schedules == nil {
+		schedules = []*model.ScheduledSMS{}
+	}
+	utils.WriteJSON(w, http.StatusOK, schedules)
+}
+
+// HandleSchedule reads, reschedules or cancels a scheduled send
+// @Summary Read, reschedule or cancel a scheduled SMS
+// @Description GET returns a scheduled send, PATCH moves its next run or changes its recurrence, DELETE cancels it. Messages of earlier runs are not affected.
+// @Tags Schedule
+// @Accept json
+// @Produce json
+// @Param id path string true "Schedule ID returned by /api/v1/sms/send"
+// @Param request body model.RescheduleRequest false "New send time or recurrence (PATCH only)"
+// @Success 200 {object} model.ScheduledSMS "Scheduled send"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 404 {object} model.ErrorResponse "Scheduled send not found"
+// @Failure 409 {object} model.ErrorResponse "Scheduled send already done or cancelled"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /api/v1/sms/scheduled/{id} [get]
+// @Router /api/v1/sms/scheduled/{id} [patch]
+// @Router /api/v1/sms/scheduled/{id} [delete]
+func (h *SMSHandler) HandleSchedule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var (
+		sch *model.ScheduledSMS
+		err error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		sch, err = h.smsService.GetSchedule(id)
+	case http.MethodPatch:
+		var req model.RescheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+			return
+		}
+		recurrence := ""
+		if req.Recurrence != nil {
+			recurrence = *req.Recurrence
+		}
+		if err := validation.ValidateSchedule(req.SendAt, recurrence, req.TimeZone); err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sch, err = h.smsService.Reschedule(id, &req)
+	case http.MethodDelete:
+		sch, err = h.smsService.CancelSchedule(id)
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use GET, PATCH or DELETE.")
+		return
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrScheduleNotFound):
+			h.writeError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrScheduleClosed):
+			h.writeError(w, http.StatusConflict, err.Error())
+		default:
+			h.writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, sch)
+}
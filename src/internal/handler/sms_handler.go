@@ -2,15 +2,19 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"sms-gateway/src/internal/config"
 	"sms-gateway/src/internal/model"
 	"sms-gateway/src/internal/service"
+	"sms-gateway/src/internal/store"
 	"sms-gateway/src/internal/utils"
+	"sms-gateway/src/pkg/session"
 	"sms-gateway/src/pkg/validation"
 )
 
@@ -32,13 +36,15 @@ func NewSMSHandler(cfg *config.Config, smsService *service.SMSService) *SMSHandl
 
 // HandleSendSMS handles SMS sending requests
 // @Summary Send SMS message
-// @Description Send an SMS message through the configured modem
+// @Description Send an SMS message through the configured modem. With "async": true the message is only validated and queued, and its status is followed with /api/v1/sms/{id}. With send_at or recurrence it is scheduled instead, and followed with /api/v1/sms/scheduled/{id}.
 // @Tags SMS
 // @Accept json
 // @Produce json
 // @Param request body model.SendSMSRequest true "SMS request details"
 // @Success 200 {object} model.SendSMSResponse "SMS sent successfully"
+// @Success 202 {object} model.SendSMSResponse "SMS queued (async) or scheduled"
 // @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 409 {object} model.SendSMSResponse "Port stayed busy for the whole timeout"
 // @Failure 500 {object} model.SendSMSResponse "Internal server error"
 // @Router /api/v1/sms/send [post]
 func (h *SMSHandler) HandleSendSMS(w http.ResponseWriter, r *http.Request) {
@@ -59,18 +65,25 @@ func (h *SMSHandler) HandleSendSMS(w http.ResponseWriter, r *http.Request) {
 		req.To, req.Port, len(req.Message))
 
 	// Validate request
-	if err := validation.ValidateSendSMSRequest(&req); err != nil {
+	if err := validation.ValidateSendSMSRequest(&req, h.config.SMS.MaxLength, h.config.SMS.ConcatRef16); err != nil {
 		log.Printf("Validation error: %v", err)
 		h.writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	// The modem takes the number without the separators validation allows
+	req.To = validation.NormalizePhoneNumber(req.To)
 
 	// Send SMS
 	response, err := h.smsService.SendSMS(r.Context(), &req)
 	if err != nil {
 		log.Printf("SMS service error: %v", err)
 		// Return the response with error details
-		utils.WriteJSON(w, http.StatusInternalServerError, response)
+		utils.WriteJSON(w, portErrorStatus(err), response)
+		return
+	}
+
+	if req.Async || response.ScheduleID != "" {
+		utils.WriteJSON(w, http.StatusAccepted, response)
 		return
 	}
 
@@ -79,9 +92,81 @@ func (h *SMSHandler) HandleSendSMS(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSON(w, http.StatusOK, response)
 }
 
+// HandleListSMS handles listing outbound messages
+// @Summary List sent SMS
+// @Description List outbound messages in the order they were created, optionally filtered by status, recipient and sender
+// @Tags SMS
+// @Produce json
+// @Param status query string false "Comma-separated statuses: pending, sending, sent, delivered, failed or expired"
+// @Param to query string false "Recipient number, in any format"
+// @Param from query string false "Port the message was sent on, or sender number"
+// @Success 200 {array} model.SMS "Messages with their status"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /api/v1/sms [get]
+func (h *SMSHandler) HandleListSMS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use GET.")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := store.Filter{To: query.Get("to"), From: query.Get("from")}
+	if statuses := query.Get("status"); statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			switch status = strings.TrimSpace(status); status {
+			case model.StatusPending, model.StatusSending, model.StatusSent,
+				model.StatusDelivered, model.StatusFailed, model.StatusExpired:
+				filter.Statuses = append(filter.Statuses, status)
+			default:
+				h.writeError(w, http.StatusBadRequest, "unknown status "+status)
+				return
+			}
+		}
+	}
+
+	messages, err := h.smsService.ListMessages(filter)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if messages == nil {
+		messages = []*model.SMS{}
+	}
+	utils.WriteJSON(w, http.StatusOK, messages)
+}
+
+// HandleGetSMS handles message status requests
+// @Summary Get SMS status
+// @Description Get an outbound message by its gateway ID: its status and history, timestamps, attempts, port, error and the delivery status of each part
+// @Tags SMS
+// @Produce json
+// @Param id path string true "Message ID returned by /api/v1/sms/send"
+// @Success 200 {object} model.SMS "Message with its delivery status"
+// @Failure 404 {object} model.ErrorResponse "Message not found"
+// @Router /api/v1/sms/{id} [get]
+func (h *SMSHandler) HandleGetSMS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use GET.")
+		return
+	}
+
+	msg, err := h.smsService.GetMessage(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, msg)
+}
+
 // HandleHealth handles health check requests
 // @Summary Health check
-// @Description Check the health status of the SMS Gateway service
+// @Description Check the health status of the SMS Gateway service and its modem sessions
 // @Tags Health
 // @Produce json
 // @Success 200 {object} model.HealthResponse "Service health status"
@@ -94,17 +179,37 @@ func (h *SMSHandler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 		Version:   h.config.Version,
 		Timestamp: time.Now().Format(time.RFC3339),
 		Uptime:    uptime.String(),
+		Modems:    h.smsService.ModemHealth(),
 	}
 
 	utils.WriteJSON(w, http.StatusOK, response)
 }
 
+// HandleMetrics handles send queue metrics requests
+// @Summary Send queue metrics
+// @Description Number of messages waiting to be sent on each modem, by priority (urgent, high, normal)
+// @Tags Health
+// @Produce json
+// @Success 200 {object} model.MetricsResponse "Queue depth per modem and priority"
+// @Router /api/v1/metrics [get]
+func (h *SMSHandler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use GET.")
+		return
+	}
+
+	metrics := h.smsService.QueueMetrics()
+	metrics.Timestamp = time.Now().Format(time.RFC3339)
+	utils.WriteJSON(w, http.StatusOK, metrics)
+}
+
 // HandlePortStatus handles port status check requests
 // @Summary Check port status
 // @Description Check the status of a specific serial port and return SIM balance (if configured)
 // @Tags Modem
 // @Produce json
 // @Param port query string false "Port name (defaults to configured default port)"
+// @Param wait query bool false "Wait for a busy port (default true); false reports it as in use immediately"
 // @Success 200 {object} model.PortStatus "Port status information including balance if available"
 // @Failure 500 {object} model.ErrorResponse "Internal server error"
 // @Router /api/v1/ports/status [get]
@@ -114,7 +219,7 @@ func (h *SMSHandler) HandlePortStatus(w http.ResponseWriter, r *http.Request) {
 		port = h.config.Modem.DefaultPort
 	}
 
-	status, err := h.smsService.CheckPortStatus(port)
+	status, err := h.smsService.CheckPortStatus(r.Context(), port, waitForPort(r))
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -129,7 +234,9 @@ func (h *SMSHandler) HandlePortStatus(w http.ResponseWriter, r *http.Request) {
 // @Tags Modem
 // @Produce json
 // @Param port query string false "Port name (defaults to configured default port)"
+// @Param wait query bool false "Wait for a busy port (default true); false fails fast with 409"
 // @Success 200 {object} model.ModemInfo "Modem information"
+// @Failure 409 {object} model.ErrorResponse "Port is in use"
 // @Failure 500 {object} model.ErrorResponse "Internal server error"
 // @Router /api/v1/modem/info [get]
 func (h *SMSHandler) HandleModemInfo(w http.ResponseWriter, r *http.Request) {
@@ -141,9 +248,9 @@ func (h *SMSHandler) HandleModemInfo(w http.ResponseWriter, r *http.Request) {
 	baudRate := h.config.Modem.DefaultBaudRate
 	// You could add baudRate parameter parsing here if needed
 
-	info, err := h.smsService.GetModemInfo(r.Context(), port, baudRate)
+	info, err := h.smsService.GetModemInfo(r.Context(), port, baudRate, waitForPort(r))
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeError(w, portErrorStatus(err), err.Error())
 		return
 	}
 
@@ -159,7 +266,7 @@ func (h *SMSHandler) HandleModemInfo(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} model.ErrorResponse "Internal server error"
 // @Router /api/v1/ports [get]
 func (h *SMSHandler) HandleListPorts(w http.ResponseWriter, r *http.Request) {
-	ports, err := h.smsService.ListPortsWithInfo()
+	ports, err := h.smsService.ListPortsWithInfo(r.Context())
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -187,12 +294,18 @@ func (h *SMSHandler) HandleRoot(w http.ResponseWriter, r *http.Request) {
 		"service": "SMS Gateway API",
 		"version": h.config.Version,
 		"endpoints": map[string]string{
-			"POST /api/v1/sms/send":    "Send SMS message",
-			"GET /api/v1/health":       "Service health check",
-			"GET /api/v1/ports":        "List available ports",
-			"GET /api/v1/ports/status": "Check port status",
-			"GET /api/v1/modem/info":   "Get modem information",
-			"GET /api/v1/device/info":  "Get detailed device information",
+			"POST /api/v1/sms/send":     "Send SMS message",
+			"GET /api/v1/sms":           "List sent SMS",
+			"GET /api/v1/sms/scheduled": "List scheduled SMS",
+			"GET /api/v1/sms/{id}":      "Get SMS delivery status",
+			"GET /api/v1/sms/inbox":     "List received SMS",
+			"GET /api/v1/health":        "Service health check",
+			"GET /api/v1/metrics":       "Send queue depth by priority",
+			"GET /api/v1/ports":         "List available ports",
+			"GET /api/v1/ports/status":  "Check port status",
+			"GET /api/v1/modem/info":    "Get modem information",
+			"GET /api/v1/device/info":   "Get detailed device information",
+			"GET /api/v1/numbers":       "List own numbers of SIMs",
 		},
 		"timestamp": time.Now().Format(time.RFC3339),
 	}
@@ -207,7 +320,9 @@ func (h *SMSHandler) HandleRoot(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param port query string false "Port name (defaults to configured default port)"
 // @Param baud_rate query int false "Baud rate (defaults to configured default baud rate)"
+// @Param wait query bool false "Wait for a busy port (default true); false fails fast with 409"
 // @Success 200 {object} model.DeviceInfo "Detailed device information"
+// @Failure 409 {object} model.ErrorResponse "Port is in use"
 // @Failure 500 {object} model.ErrorResponse "Internal server error"
 // @Router /api/v1/device/info [get]
 func (h *SMSHandler) HandleDeviceInfo(w http.ResponseWriter, r *http.Request) {
@@ -225,10 +340,10 @@ func (h *SMSHandler) HandleDeviceInfo(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Getting device info for port: %s, baud rate: %d", port, baudRate)
 
-	info, err := h.smsService.GetDeviceInfo(r.Context(), port, baudRate)
+	info, err := h.smsService.GetDeviceInfo(r.Context(), port, baudRate, waitForPort(r))
 	if err != nil {
 		log.Printf("Error getting device info: %v", err)
-		h.writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeError(w, portErrorStatus(err), err.Error())
 		return
 	}
 
@@ -236,6 +351,21 @@ func (h *SMSHandler) HandleDeviceInfo(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSON(w, http.StatusOK, info)
 }
 
+// waitForPort reads the wait query parameter; read-only queries wait for a
+// busy port unless the caller asks to fail fast with wait=false
+func waitForPort(r *http.Request) bool {
+	wait, err := strconv.ParseBool(r.URL.Query().Get("wait"))
+	return err != nil || wait
+}
+
+// portErrorStatus maps a busy port to 409 and anything else to 500
+func portErrorStatus(err error) int {
+	if errors.Is(err, session.ErrBusy) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
 // writeError writes error response
 func (h *SMSHandler) writeError(w http.ResponseWriter, statusCode int, message string) {
 	response := model.ErrorResponse{
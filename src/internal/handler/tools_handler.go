@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/utils"
+	"sms-gateway/src/pkg/pdu"
+)
+
+// HandleDecodePDU decodes a PDU for inspection
+// @Summary Decode a PDU
+// @Description Decode an SMS-DELIVER, SMS-SUBMIT or SMS-STATUS-REPORT PDU, e.g. one captured from AT+CMGL
+// @Tags Tools
+// @Accept json
+// @Produce json
+// @Param request body model.PDUDecodeRequest true "PDU as hex"
+// @Success 200 {object} model.PDUInfo "Decoded PDU"
+// @Failure 400 {object} model.ErrorResponse "Malformed PDU"
+// @Router /api/v1/tools/pdu/decode [post]
+func (h *SMSHandler) HandleDecodePDU(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use POST.")
+		return
+	}
+
+	var req model.PDUDecodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+		return
+	}
+	pduHex := strings.Join(strings.Fields(req.PDU), "")
+	if pduHex == "" {
+		h.writeError(w, http.StatusBadRequest, "pdu is required")
+		return
+	}
+	if req.TPDUOnly {
+		pduHex = "00" + pduHex
+	}
+
+	msg, err := pdu.Decode(pduHex)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, pduInfo(msg))
+}
+
+// HandleEncodePDU encodes an SMS-SUBMIT
+// @Summary Encode a PDU
+// @Description Encode a message as SMS-SUBMIT PDUs, split into concatenated parts when the encoding is automatic and the text is long
+// @Tags Tools
+// @Accept json
+// @Produce json
+// @Param request body model.PDUEncodeRequest true "Message to encode"
+// @Success 200 {object} model.PDUEncodeResponse "Encoded PDUs"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Router /api/v1/tools/pdu/encode [post]
+func (h *SMSHandler) HandleEncodePDU(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use POST.")
+		return
+	}
+
+	var req model.PDUEncodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+		return
+	}
+
+	submit, err := submitFromRequest(&req)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Only automatic encoding is split; a forced encoding must fit one PDU
+	submits := []pdu.Submit{*submit}
+	if submit.Encoding == "" {
+		submits, err = pdu.SplitSubmit(*submit, uint16(rand.Intn(256)), false)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	response := model.PDUEncodeResponse{Encoding: submit.Encoding}
+	if response.Encoding == "" {
+		response.Encoding = pdu.SelectEncoding(submit.Text)
+	}
+	for _, part := range submits {
+		encoded, length, err := part.Encode()
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		response.Parts = append(response.Parts, model.PDUPart{PDU: encoded, Length: length})
+	}
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
+// submitFromRequest validates an encode request and builds its Submit
+func submitFromRequest(req *model.PDUEncodeRequest) (*pdu.Submit, error) {
+	if req.To == "" {
+		return nil, fmt.Errorf("to is required")
+	}
+	switch req.Encoding {
+	case "", pdu.EncodingGSM7, pdu.EncodingUCS2, pdu.Encoding8Bit:
+	default:
+		return nil, fmt.Errorf("encoding must be gsm7, ucs2 or 8bit")
+	}
+	class, err := pdu.ParseClass(req.Class)
+	if err != nil {
+		return nil, err
+	}
+	if req.ValidityMinutes < 0 {
+		return nil, fmt.Errorf("validity_minutes must not be negative")
+	}
+
+	submit := &pdu.Submit{
+		SMSC:           req.SMSC,
+		Destination:    req.To,
+		Text:           req.Message,
+		Encoding:       req.Encoding,
+		Class:          class,
+		ValidityPeriod: time.Duration(req.ValidityMinutes) * time.Minute,
+		StatusReport:   req.StatusReport,
+	}
+	if req.ValidUntil != "" {
+		if submit.ValidUntil, err = time.Parse(time.RFC3339, req.ValidUntil); err != nil {
+			return nil, fmt.Errorf("valid_until must be RFC 3339: %w", err)
+		}
+	}
+	return submit, nil
+}
+
+// pduInfo converts a decoded PDU to its API form
+func pduInfo(msg *pdu.Message) model.PDUInfo {
+	info := model.PDUInfo{Type: msg.Type}
+	var header []pdu.InformationElement
+
+	switch {
+	case msg.Deliver != nil:
+		d := msg.Deliver
+		info.SMSC, info.Sender = d.SMSC, d.Sender
+		info.Timestamp = d.Timestamp.Format(time.RFC3339)
+		info.Encoding, info.Class, info.Text = d.Encoding, d.Class.String(), d.Text
+		header = d.Header
+	case msg.Submit != nil:
+		s := msg.Submit
+		info.SMSC, info.Destination, info.Reference = s.SMSC, s.Destination, int(s.Reference)
+		info.Encoding, info.Class, info.Text = s.Encoding, s.Class.String(), s.Text
+		info.StatusReport = s.StatusReport
+		if s.ValidityPeriod > 0 {
+			info.ValidityPeriod = s.ValidityPeriod.String()
+		}
+		if !s.ValidUntil.IsZero() {
+			info.ValidUntil = s.ValidUntil.Format(time.RFC3339)
+		}
+		header = s.Header
+	case msg.StatusReport != nil:
+		s := msg.StatusReport
+		info.SMSC, info.Recipient, info.Reference = s.SMSC, s.Recipient, s.Reference
+		info.Timestamp = s.ServiceCentreTime.Format(time.RFC3339)
+		info.DischargeTime = s.DischargeTime.Format(time.RFC3339)
+		info.Status = fmt.Sprintf("%02X", s.Status)
+		info.State = s.State()
+	}
+
+	for _, ie := range header {
+		info.Header = append(info.Header, model.UDHElement{ID: int(ie.ID), Data: strings.ToUpper(hex.EncodeToString(ie.Data))})
+	}
+	if ref, total, part, ok := pdu.FindConcat(header); ok {
+		info.Concat = &model.ConcatInfo{Reference: ref, Total: total, Part: part}
+	}
+	return info
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/service"
+	"sms-gateway/src/internal/utils"
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/ussd"
+	"sms-gateway/src/pkg/validation"
+)
+
+// HandleStartUSSD starts a USSD session
+// @Summary Start a USSD session
+// @Description Send a USSD code (AT+CUSD=1). When the network answers with a menu (+CUSD: 1) the session stays open for replies and holds the port until it ends, is cancelled or stays idle for the configured session timeout.
+// @Tags USSD
+// @Accept json
+// @Produce json
+// @Param request body model.USSDRequest true "USSD code and port"
+// @Param wait query bool false "Wait for a busy port (default true); false fails fast with 409"
+// @Success 200 {object} model.USSDSession "Answer of the network"
+// @Failure 400 {object} model.ErrorResponse "Bad request or code not supported"
+// @Failure 409 {object} model.ErrorResponse "Port is in use, e.g. by another USSD session"
+// @Failure 504 {object} model.ErrorResponse "No answer from the network"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /api/v1/ussd [post]
+func (h *SMSHandler) HandleStartUSSD(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use POST.")
+		return
+	}
+
+	var req model.USSDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+		return
+	}
+	if err := validation.ValidateUSSDCode(req.Code); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	session, err := h.smsService.StartUSSD(r.Context(), &req, waitForPort(r))
+	if err != nil {
+		h.writeError(w, ussdErrorStatus(err), err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, session)
+}
+
+// HandleUSSDSession reads or cancels a USSD session
+// @Summary Get or cancel a USSD session
+// @Description GET returns an open session with the latest answer, DELETE cancels it (AT+CUSD=2) and releases the port
+// @Tags USSD
+// @Produce json
+// @Param session path string true "Session ID returned by /api/v1/ussd"
+// @Success 200 {object} model.USSDSession "Session"
+// @Failure 404 {object} model.ErrorResponse "No open session with this ID"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /api/v1/ussd/{session} [get]
+// @Router /api/v1/ussd/{session} [delete]
+func (h *SMSHandler) HandleUSSDSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("session")
+
+	switch r.Method {
+	case http.MethodGet:
+		session, err := h.smsService.GetUSSD(id)
+		if err != nil {
+			h.writeError(w, ussdErrorStatus(err), err.Error())
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, session)
+	case http.MethodDelete:
+		session, err := h.smsService.CancelUSSD(r.Context(), id)
+		if err != nil && session == nil {
+			h.writeError(w, ussdErrorStatus(err), err.Error())
+			return
+		}
+		// The session is closed even when the modem did not confirm
+		utils.WriteJSON(w, http.StatusOK, session)
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use GET or DELETE.")
+	}
+}
+
+// HandleReplyUSSD answers the prompt of a USSD session
+// @Summary Reply to a USSD menu
+// @Description Send a reply, e.g. a menu choice, to an open session. The session stays open while the network answers with another menu.
+// @Tags USSD
+// @Accept json
+// @Produce json
+// @Param session path string true "Session ID returned by /api/v1/ussd"
+// @Param request body model.USSDReplyRequest true "Reply"
+// @Success 200 {object} model.USSDSession "Answer of the network"
+// @Failure 400 {object} model.ErrorResponse "Bad request or reply not supported"
+// @Failure 404 {object} model.ErrorResponse "No open session with this ID"
+// @Failure 504 {object} model.ErrorResponse "No answer from the network"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /api/v1/ussd/{session}/reply [post]
+func (h *SMSHandler) HandleReplyUSSD(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use POST.")
+		return
+	}
+
+	var req model.USSDReplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+		return
+	}
+	if err := validation.ValidateUSSDInput(req.Input); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	session, err := h.smsService.ReplyUSSD(r.Context(), r.PathValue("session"), req.Input)
+	if err != nil {
+		h.writeError(w, ussdErrorStatus(err), err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, session)
+}
+
+// ussdErrorStatus maps USSD errors to HTTP status codes
+func ussdErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUSSDSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ussd.ErrNotSupported):
+		return http.StatusBadRequest
+	case errors.Is(err, at.ErrTimeout):
+		return http.StatusGatewayTimeout
+	}
+	return portErrorStatus(err)
+}
@@ -0,0 +1,66 @@
+package model
+
+// PDUDecodeRequest asks to decode a PDU captured from a modem
+type PDUDecodeRequest struct {
+	PDU      string `json:"pdu" validate:"required"` // hex
+	TPDUOnly bool   `json:"tpdu_only,omitempty"`     // the PDU has no leading SMSC field
+}
+
+// PDUInfo is a decoded PDU
+type PDUInfo struct {
+	Type           string       `json:"type"` // SMS-DELIVER, SMS-SUBMIT or SMS-STATUS-REPORT
+	SMSC           string       `json:"smsc,omitempty"`
+	Sender         string       `json:"sender,omitempty"`      // SMS-DELIVER
+	Destination    string       `json:"destination,omitempty"` // SMS-SUBMIT
+	Recipient      string       `json:"recipient,omitempty"`   // SMS-STATUS-REPORT
+	Reference      int          `json:"reference,omitempty"`   // TP-MR
+	Timestamp      string       `json:"timestamp,omitempty"`   // service centre time stamp
+	DischargeTime  string       `json:"discharge_time,omitempty"`
+	Status         string       `json:"status,omitempty"` // TP-Status as hex
+	State          string       `json:"state,omitempty"`  // delivered, pending, failed or expired
+	Encoding       string       `json:"encoding,omitempty"`
+	Class          string       `json:"class,omitempty"`
+	ValidityPeriod string       `json:"validity_period,omitempty"`
+	ValidUntil     string       `json:"valid_until,omitempty"`
+	StatusReport   bool         `json:"status_report,omitempty"` // TP-SRR
+	Header         []UDHElement `json:"header,omitempty"`
+	Concat         *ConcatInfo  `json:"concat,omitempty"`
+	Text           string       `json:"text,omitempty"` // 8-bit data as hex
+}
+
+// UDHElement is one user data header element
+type UDHElement struct {
+	ID   int    `json:"iei"`
+	Data string `json:"data"` // hex
+}
+
+// ConcatInfo identifies one part of a concatenated message
+type ConcatInfo struct {
+	Reference int `json:"reference"`
+	Total     int `json:"total"`
+	Part      int `json:"part"`
+}
+
+// PDUEncodeRequest asks to encode an SMS-SUBMIT
+type PDUEncodeRequest struct {
+	To              string `json:"to" validate:"required"`
+	Message         string `json:"message"`
+	Encoding        string `json:"encoding,omitempty"` // gsm7, ucs2 or 8bit (message as hex); default from the text
+	Class           string `json:"class,omitempty"`    // "0" (flash) to "3"
+	ValidityMinutes int    `json:"validity_minutes,omitempty"`
+	ValidUntil      string `json:"valid_until,omitempty"` // RFC 3339, instead of validity_minutes
+	StatusReport    bool   `json:"status_report,omitempty"`
+	SMSC            string `json:"smsc,omitempty"`
+}
+
+// PDUEncodeResponse holds the PDUs of an encoded message, one per part
+type PDUEncodeResponse struct {
+	Encoding string    `json:"encoding"`
+	Parts    []PDUPart `json:"parts"`
+}
+
+// PDUPart is one encoded PDU with the length to pass to AT+CMGS
+type PDUPart struct {
+	PDU    string `json:"pdu"`
+	Length int    `json:"length"`
+}
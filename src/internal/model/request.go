@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 // SendSMSRequest represents an SMS sending request
 type SendSMSRequest struct {
 	To       string `json:"to" validate:"required"`
@@ -9,14 +11,40 @@ type SendSMSRequest struct {
 	Timeout  int    `json:"timeout,omitempty"`
 	Mode     string `json:"mode,omitempty"`     // "text" or "pdu", default "text"
 	Priority string `json:"priority,omitempty"` // "normal", "high", "urgent"
+	Async    bool   `json:"async,omitempty"`    // return 202 once queued instead of waiting for the modem
+
+	// Scheduled sends, always answered with 202
+	SendAt     *time.Time `json:"send_at,omitempty"`    // RFC 3339 with offset, e.g. "2026-10-20T08:00:00+07:00"
+	Recurrence string     `json:"recurrence,omitempty"` // cron expression, e.g. "0 8 * * 1-5"
+	TimeZone   string     `json:"time_zone,omitempty"`  // IANA zone of the recurrence, default the server's
+}
+
+// RescheduleRequest changes the next send of a scheduled message or its
+// recurrence. Fields left empty keep their value.
+type RescheduleRequest struct {
+	SendAt     *time.Time `json:"send_at,omitempty"`
+	Recurrence *string    `json:"recurrence,omitempty"` // "" makes the schedule one-off
+	TimeZone   string     `json:"time_zone,omitempty"`
+}
+
+// AssignNumberRequest sets the own number of a SIM by hand, for SIMs whose
+// number cannot be discovered. The SIM is the one in Port unless its ICCID
+// or IMSI is given.
+type AssignNumberRequest struct {
+	PhoneNumber string `json:"phone_number" validate:"required"`
+	Port        string `json:"port,omitempty"`
+	BaudRate    int    `json:"baud_rate,omitempty"`
+	ICCID       string `json:"iccid,omitempty"`
+	IMSI        string `json:"imsi,omitempty"`
 }
 
 // HealthResponse represents health check response
 type HealthResponse struct {
-	Status    string `json:"status"`
-	Version   string `json:"version"`
-	Timestamp string `json:"timestamp"`
-	Uptime    string `json:"uptime,omitempty"`
+	Status    string        `json:"status"`
+	Version   string        `json:"version"`
+	Timestamp string        `json:"timestamp"`
+	Uptime    string        `json:"uptime,omitempty"`
+	Modems    []ModemHealth `json:"modems,omitempty"`
 }
 
 // PortStatusResponse represents port status response
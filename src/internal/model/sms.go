@@ -6,15 +6,89 @@ import (
 
 // SMS represents an SMS message
 type SMS struct {
-	ID          string     `json:"id"`
-	From        string     `json:"from"`
-	To          string     `json:"to"`
-	Message     string     `json:"message"`
-	Status      string     `json:"status"`
-	CreatedAt   time.Time  `json:"created_at"`
-	SentAt      *time.Time `json:"sent_at,omitempty"`
-	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
-	ErrorMsg    string     `json:"error_msg,omitempty"`
+	ID          string       `json:"id"`
+	From        string       `json:"from"`
+	To          string       `json:"to"`
+	Message     string       `json:"message"`
+	Status      string       `json:"status"`
+	Port        string       `json:"port,omitempty"`
+	Segments    []SMSSegment `json:"segments,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	SentAt      *time.Time   `json:"sent_at,omitempty"`
+	DeliveredAt *time.Time   `json:"delivered_at,omitempty"`
+	ErrorMsg    string       `json:"error_msg,omitempty"`
+	Encoding    string       `json:"encoding,omitempty"` // gsm7, ucs2 or, inbound only, 8bit (message is then hex)
+	Parts       int          `json:"parts,omitempty"`    // part count of a concatenated message
+
+	// Outbound messages only
+	Mode          string         `json:"mode,omitempty"`            // "text" or "pdu" as requested
+	BaudRate      int            `json:"baud_rate,omitempty"`       // of Port
+	Timeout       int            `json:"timeout,omitempty"`         // seconds to wait for a busy modem
+	Priority      string         `json:"priority,omitempty"`        // one of the Priority constants
+	ScheduleID    string         `json:"schedule_id,omitempty"`     // of the scheduled send that created the message
+	Attempts      []SendAttempt  `json:"attempts,omitempty"`        // every try at sending the message
+	NextAttemptAt *time.Time     `json:"next_attempt_at,omitempty"` // when a failed send is retried
+	History       []StatusChange `json:"history,omitempty"`         // every status the message went through
+
+	// Inbound messages only
+	Index      int        `json:"index,omitempty"`       // storage index on the SIM
+	ReceivedAt *time.Time `json:"received_at,omitempty"` // SMSC time stamp
+	Partial    bool       `json:"partial,omitempty"`     // parts were missing when reassembly timed out
+	// Stored are the modem memory slots holding the message or its parts
+	Stored []StorageSlot `json:"-"`
+}
+
+// StorageSlot is the place of a received message in modem memory
+type StorageSlot struct {
+	Memory string // "SM", "ME", ... as announced by +CMTI
+	Index  int
+}
+
+// ScheduledSMS is a message to send at a later time, once or following a
+// cron recurrence. Each run creates an outbound message.
+type ScheduledSMS struct {
+	ID         string `json:"id"`
+	To         string `json:"to"`
+	Message    string `json:"message"`
+	Port       string `json:"port,omitempty"`
+	BaudRate   int    `json:"baud_rate,omitempty"`
+	Timeout    int    `json:"timeout,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	Priority   string `json:"priority,omitempty"`
+	Status     string `json:"status"`               // one of the Schedule constants
+	Recurrence string `json:"recurrence,omitempty"` // cron expression
+	TimeZone   string `json:"time_zone,omitempty"`  // of the recurrence
+
+	NextRunAt     *time.Time `json:"next_run_at,omitempty"`
+	Runs          int        `json:"runs"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	LastMessageID string     `json:"last_message_id,omitempty"` // message created by the last run
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Schedule statuses
+const (
+	ScheduleActive    = "scheduled"
+	ScheduleDone      = "done" // one-off send that ran
+	ScheduleCancelled = "cancelled"
+)
+
+// SendAttempt is one try at sending an outbound message on a modem
+type SendAttempt struct {
+	Number    int        `json:"number"`
+	Port      string     `json:"port"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Retryable bool       `json:"retryable,omitempty"` // the error was temporary
+}
+
+// StatusChange is one status transition of an outbound message
+type StatusChange struct {
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+	Error  string    `json:"error,omitempty"`
 }
 
 // SMSStatus constants
@@ -24,6 +98,34 @@ const (
 	StatusSent      = "sent"
 	StatusDelivered = "delivered"
 	StatusFailed    = "failed"
+	StatusExpired   = "expired"
+	StatusUnread    = "unread"
+	StatusRead      = "read"
+)
+
+// Send priorities. Each modem sends urgent messages first, then high and
+// then normal ones.
+const (
+	PriorityUrgent = "urgent"
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+)
+
+// SegmentSubmitting is the status of a part while it is handed to the
+// modem; a part left in it by a crash may or may not have been sent
+const SegmentSubmitting = "submitting"
+
+// WebhookEvent is the body posted to the configured webhook URLs
+type WebhookEvent struct {
+	Event     string      `json:"event"` // e.g. "sms.received"
+	Data      interface{} `json:"data"`
+	Timestamp string      `json:"timestamp"`
+}
+
+// Webhook event names
+const (
+	EventSMSReceived = "sms.received"
+	EventSMSPartial  = "sms.partial" // incomplete concatenated message
 )
 
 // OTP represents an OTP entry
@@ -48,56 +150,123 @@ type ModemInfo struct {
 	Connected    bool   `json:"connected"`
 }
 
+// ModemHealth represents the state of a persistent modem session
+type ModemHealth struct {
+	Port        string `json:"port"`
+	State       string `json:"state"` // "ready", "failed" or "disconnected"
+	LastError   string `json:"last_error,omitempty"`
+	ConnectedAt string `json:"connected_at,omitempty"`
+	LastCheck   string `json:"last_check,omitempty"`
+	Reconnects  int    `json:"reconnects"`
+}
+
+// QueueMetrics is the number of messages waiting in the send queue of a
+// modem, by priority
+type QueueMetrics struct {
+	Port  string         `json:"port"`
+	Depth map[string]int `json:"depth"`
+	Total int            `json:"total"`
+}
+
+// MetricsResponse represents the send queue metrics
+type MetricsResponse struct {
+	QueueDepth map[string]int `json:"queue_depth"` // all modems together, by priority
+	Queues     []QueueMetrics `json:"queues"`
+	Timestamp  string         `json:"timestamp"`
+}
+
 // PortStatus represents port availability status
 type PortStatus struct {
-	Port      string `json:"port"`
-	Available bool   `json:"available"`
-	InUse     bool   `json:"in_use"`
-	Balance   string `json:"balance,omitempty"`
-	Error     string `json:"error,omitempty"`
+	Port       string   `json:"port"`
+	Available  bool     `json:"available"`
+	InUse      bool     `json:"in_use"`
+	InUseBy    string   `json:"in_use_by,omitempty"`
+	InUseSince string   `json:"in_use_since,omitempty"`
+	Balance    *Balance `json:"balance,omitempty"`
+	Error      string   `json:"error,omitempty"`
 }
 
 // PortInfo represents detailed port information
 type PortInfo struct {
-	Port        string   `json:"port"`
-	DeviceName  string   `json:"device_name,omitempty"`
-	Description string   `json:"description,omitempty"`
-	Msisdn      string   `json:"msisdn,omitempty"`
-	Balance     string   `json:"balance,omitempty"`
-	Packages    []string `json:"packages,omitempty"`
-	Available   bool     `json:"available"`
-	InUse       bool     `json:"in_use"`
-	Error       string   `json:"error,omitempty"`
+	Port        string    `json:"port"`
+	DeviceName  string    `json:"device_name,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Msisdn      string    `json:"msisdn,omitempty"`
+	Operator    string    `json:"operator,omitempty"`
+	Balance     *Balance  `json:"balance,omitempty"`
+	Packages    []Package `json:"packages,omitempty"`
+	Available   bool      `json:"available"`
+	InUse       bool      `json:"in_use"`
+	InUseBy     string    `json:"in_use_by,omitempty"`
+	InUseSince  string    `json:"in_use_since,omitempty"`
+	Error       string    `json:"error,omitempty"`
 }
 
 // SendSMSResponse represents the response from sending an SMS
 type SendSMSResponse struct {
-	Success   bool     `json:"success"`
-	MessageID string   `json:"message_id,omitempty"`
-	Error     string   `json:"error,omitempty"`
-	Steps     []string `json:"steps,omitempty"`
-	Duration  string   `json:"duration,omitempty"`
-	Mode      string   `json:"mode,omitempty"` // "text" or "pdu"
-	To        string   `json:"to,omitempty"`
-	Message   string   `json:"message,omitempty"`
-	Timestamp string   `json:"timestamp,omitempty"`
+	Success    bool          `json:"success"`
+	MessageID  string        `json:"message_id,omitempty"`
+	ScheduleID string        `json:"schedule_id,omitempty"` // of a scheduled send
+	SendAt     *time.Time    `json:"send_at,omitempty"`     // first run of a scheduled send
+	Status     string        `json:"status,omitempty"`      // of the message, "pending" when queued asynchronously, "scheduled" for a scheduled send
+	Port       string        `json:"port,omitempty"`        // the message was last sent on
+	Error      string        `json:"error,omitempty"`
+	Attempts   []SendAttempt `json:"attempts,omitempty"`
+	Steps      []string      `json:"steps,omitempty"`
+	Duration   string        `json:"duration,omitempty"`
+	Mode       string        `json:"mode,omitempty"`     // "text" or "pdu"
+	Encoding   string        `json:"encoding,omitempty"` // "gsm7" or "ucs2"
+	Segments   []SMSSegment  `json:"segments,omitempty"`
+	To         string        `json:"to,omitempty"`
+	Message    string        `json:"message,omitempty"`
+	Timestamp  string        `json:"timestamp,omitempty"`
+}
+
+// SMSSegment is one part of a sent message with the reference (TP-MR)
+// the network assigned to it. For a received part, Reference is the
+// concatenation reference shared by all parts.
+type SMSSegment struct {
+	Part      int    `json:"part"`
+	Reference int    `json:"reference"`
+	Status    string `json:"status,omitempty"` // from its status report: "delivered", "pending", "failed" or "expired"; "submitting" while sent
 }
 
 // DeviceInfo represents detailed device information including SIM details
 type DeviceInfo struct {
-	Port         string `json:"port"`
-	BaudRate     int    `json:"baud_rate"`
-	PhoneNumber  string `json:"phone_number,omitempty"`
-	Balance      string `json:"balance,omitempty"`
-	NetworkType  string `json:"network_type,omitempty"`
-	Operator     string `json:"operator,omitempty"`
-	SignalLevel  int    `json:"signal_level,omitempty"`
-	IMEI         string `json:"imei,omitempty"`
-	IMSI         string `json:"imsi,omitempty"`
-	Manufacturer string `json:"manufacturer,omitempty"`
-	Model        string `json:"model,omitempty"`
-	Version      string `json:"version,omitempty"`
-	Connected    bool   `json:"connected"`
-	Error        string `json:"error,omitempty"`
-	Timestamp    string `json:"timestamp"`
+	Port         string    `json:"port"`
+	BaudRate     int       `json:"baud_rate"`
+	PhoneNumber  string    `json:"phone_number,omitempty"`
+	NumberSource string    `json:"number_source,omitempty"` // where phone_number came from, see SIMNumber
+	Balance      *Balance  `json:"balance,omitempty"`
+	Packages     []Package `json:"packages,omitempty"`
+	NetworkType  string    `json:"network_type,omitempty"`
+	Operator     string    `json:"operator,omitempty"`
+	SignalLevel  int       `json:"signal_level,omitempty"`
+	IMEI         string    `json:"imei,omitempty"`
+	IMSI         string    `json:"imsi,omitempty"`
+	ICCID        string    `json:"iccid,omitempty"`
+	Manufacturer string    `json:"manufacturer,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	Version      string    `json:"version,omitempty"`
+	Connected    bool      `json:"connected"`
+	Error        string    `json:"error,omitempty"`
+	Timestamp    string    `json:"timestamp"`
+}
+
+// Sources of a SIM's own number
+const (
+	NumberSourceCNUM      = "cnum"      // AT+CNUM
+	NumberSourceUSSD      = "ussd"      // the operator's own-number USSD code
+	NumberSourcePhonebook = "phonebook" // the "ON" phonebook of the SIM
+	NumberSourceManual    = "manual"    // assigned through the API
+)
+
+// SIMNumber is the own number (MSISDN) of a SIM, identified by its ICCID
+// or IMSI
+type SIMNumber struct {
+	ICCID       string    `json:"iccid,omitempty"`
+	IMSI        string    `json:"imsi,omitempty"`
+	PhoneNumber string    `json:"phone_number"`
+	Source      string    `json:"source"` // one of the NumberSource constants
+	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -0,0 +1,72 @@
+package model
+
+import "time"
+
+// USSDRequest starts a USSD session
+type USSDRequest struct {
+	Code     string `json:"code" validate:"required"` // e.g. "*098#"
+	Port     string `json:"port,omitempty"`
+	BaudRate int    `json:"baud_rate,omitempty"`
+}
+
+// USSDReplyRequest answers the prompt of an open USSD session
+type USSDReplyRequest struct {
+	Input string `json:"input" validate:"required"` // e.g. a menu choice such as "1"
+}
+
+// USSDSession is a USSD dialog with the network and its latest answer
+type USSDSession struct {
+	ID         string     `json:"id"`
+	Port       string     `json:"port"`
+	Code       string     `json:"code"`
+	Status     int        `json:"status"`             // <m> of +CUSD, 1 when the network waits for a reply
+	StatusText string     `json:"status_text"`        // description of status
+	Message    string     `json:"message"`            // decoded text of the latest answer
+	Raw        string     `json:"raw,omitempty"`      // the answer as presented by the modem, e.g. hex UCS2
+	Encoding   string     `json:"encoding,omitempty"` // gsm7, ucs2 or 8bit, from the DCS of the answer
+	Active     bool       `json:"active"`             // the session accepts replies
+	StartedAt  time.Time  `json:"started_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // an active session is cancelled when idle until then
+}
+
+// Balance is the answer to the balance USSD code with the figures parsed
+// from it. Figures the operator's parser could not find are omitted.
+type Balance struct {
+	Text     string `json:"text"`               // decoded answer
+	Raw      string `json:"raw"`                // answer as presented by the modem, e.g. hex UCS2
+	Encoding string `json:"encoding,omitempty"` // gsm7, ucs2 or 8bit, from the DCS of the answer
+	Operator string `json:"operator,omitempty"` // operator whose parser was used
+
+	Amount     *float64        `json:"amount,omitempty"`     // main balance
+	Currency   string          `json:"currency,omitempty"`   // currency of the main balance, e.g. VND
+	ExpiresAt  string          `json:"expires_at,omitempty"` // expiry of the main balance, YYYY-MM-DD
+	Promotions []BalanceAmount `json:"promotions,omitempty"`
+	Data       []DataAllowance `json:"data,omitempty"`
+}
+
+// BalanceAmount is a promotional balance such as "KM1: 5.000d"
+type BalanceAmount struct {
+	Name      string  `json:"name"`
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency"`
+	ExpiresAt string  `json:"expires_at,omitempty"` // YYYY-MM-DD
+}
+
+// DataAllowance is a remaining data volume such as "DATA: 1.5GB"
+type DataAllowance struct {
+	Name      string  `json:"name"`
+	MB        float64 `json:"mb"`                   // remaining volume in megabytes
+	ExpiresAt string  `json:"expires_at,omitempty"` // YYYY-MM-DD
+}
+
+// Package is an active subscription from the answer to the packages code,
+// such as "Goi SMS100: con 45 SMS, HSD 20/11/2026". Quotas the answer does
+// not state are omitted.
+type Package struct {
+	Name      string   `json:"name"`
+	DataMB    *float64 `json:"data_mb,omitempty"`    // remaining data in megabytes
+	SMS       *int     `json:"sms,omitempty"`        // remaining messages
+	Minutes   *int     `json:"minutes,omitempty"`    // remaining call minutes
+	ExpiresAt string   `json:"expires_at,omitempty"` // YYYY-MM-DD
+}
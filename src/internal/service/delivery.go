@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/store"
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/pdu"
+	"sms-gateway/src/pkg/session"
+	"sms-gateway/src/pkg/sms"
+)
+
+// The reference of a segment is stored as soon as the modem acknowledges the
+// part, but a report from a fast network can be read just before that write
+// lands, so an unmatched report is looked up again briefly before it is
+// dropped
+const (
+	reportMatchAttempts = 3
+	reportMatchInterval = 50 * time.Millisecond
+)
+
+// handleReportURC processes +CDS, which carries the report, and +CDSI,
+// which announces a report stored in modem memory
+func (s *SMSService) handleReportURC(d *session.Device, urc at.URC) {
+	switch urc.Name {
+	case at.URCStatusReport:
+		report, err := sms.ParseStatusReport(urc)
+		if err != nil {
+			log.Printf("[%s] Ignoring status report: %v", d.Port(), err)
+			return
+		}
+		go s.applyStatusReport(d.Port(), report)
+	case at.URCStatusReportRef:
+		mem, index, err := sms.ParseStorageIndex(urc)
+		if err != nil {
+			log.Printf("[%s] Ignoring stored status report: %v", d.Port(), err)
+			return
+		}
+		// Reading the report needs the port, so it cannot block the URC loop
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.SMS.DefaultTimeout)*time.Second)
+			defer cancel()
+			report, err := s.smsClient.ReadStatusReport(ctx, d, mem, index)
+			if err != nil {
+				log.Printf("[%s] Failed to read status report %s/%d: %v", d.Port(), mem, index, err)
+				return
+			}
+			s.applyStatusReport(d.Port(), report)
+		}()
+	}
+}
+
+// applyStatusReport records the report on the segment it belongs to and
+// updates the status of the whole message
+func (s *SMSService) applyStatusReport(port string, report *pdu.StatusReport) {
+	var msg *model.SMS
+	var err error
+	for attempt := 0; attempt < reportMatchAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(reportMatchInterval)
+		}
+		msg, err = s.messages.FindByReference(port, report.Reference, report.Recipient)
+		if !errors.Is(err, store.ErrNotFound) {
+			break
+		}
+	}
+	if err != nil {
+		log.Printf("[%s] No message for status report %d to %s: %v", port, report.Reference, report.Recipient, err)
+		return
+	}
+
+	state := report.State()
+	updated, err := s.messages.Update(msg.ID, func(m *model.SMS) {
+		for i := range m.Segments {
+			if m.Segments[i].Reference == report.Reference {
+				m.Segments[i].Status = state
+			}
+		}
+		applyDeliveryState(m, report)
+	})
+	if err != nil {
+		log.Printf("Failed to update message %s: %v", msg.ID, err)
+		return
+	}
+	log.Printf("Status report for %s (reference %d): %s, message is %s", updated.ID, report.Reference, state, updated.Status)
+}
+
+// applyDeliveryState derives the message status from its segments: it is
+// delivered once every segment is, and failed or expired as soon as one is
+func applyDeliveryState(m *model.SMS, report *pdu.StatusReport) {
+	delivered := 0
+	for _, seg := range m.Segments {
+		switch seg.Status {
+		case pdu.ReportDelivered:
+			delivered++
+		case pdu.ReportFailed:
+			m.Status = model.StatusFailed
+			m.ErrorMsg = fmt.Sprintf("delivery of part %d failed (status %02X)", seg.Part, report.Status)
+			return
+		case pdu.ReportExpired:
+			m.Status = model.StatusExpired
+			m.ErrorMsg = fmt.Sprintf("part %d expired before delivery", seg.Part)
+			return
+		}
+	}
+	// Later parts of a message still being sent are not recorded yet
+	if delivered == len(m.Segments) && delivered > 0 && delivered >= m.Parts {
+		m.Status = model.StatusDelivered
+		deliveredAt := report.DischargeTime
+		if deliveredAt.IsZero() {
+			deliveredAt = time.Now()
+		}
+		m.DeliveredAt = &deliveredAt
+	}
+}
+
+// GetMessage returns an outbound message with its current delivery status
+func (s *SMSService) GetMessage(id string) (*model.SMS, error) {
+	return s.messages.Get(id)
+}
+
+// ListMessages returns the outbound messages matching f, oldest first
+func (s *SMSService) ListMessages(f store.Filter) ([]*model.SMS, error) {
+	return s.messages.List(f)
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/utils"
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/session"
+	"sms-gateway/src/pkg/sms"
+)
+
+// watchModem enables message and status report indications on a freshly
+// connected modem and handles the URCs it sends until the session ends
+func (s *SMSService) watchModem(d *session.Device, sess *at.Session) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err := s.smsClient.EnableIndications(ctx, sess, s.config.SMS.DeliveryReports)
+	cancel()
+	if err != nil {
+		log.Printf("[%s] Failed to enable message indications: %v", d.Port(), err)
+	}
+
+	urcs, unsubscribe := sess.Subscribe(16)
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case urc := <-urcs:
+				switch urc.Name {
+				case at.URCNewMessage, at.URCMessage:
+					s.handleMessageURC(d, urc)
+				case at.URCStatusReport, at.URCStatusReportRef:
+					s.handleReportURC(d, urc)
+				case at.URCUSSD:
+					s.handleUSSDURC(d, urc)
+				}
+			case <-sess.Done():
+				return
+			}
+		}
+	}()
+}
+
+// handleMessageURC processes +CMT, which carries the message, and +CMTI,
+// which announces a message stored in modem memory
+func (s *SMSService) handleMessageURC(d *session.Device, urc at.URC) {
+	if urc.Name == at.URCMessage {
+		msg, err := sms.ParseMessage(d.Port(), urc)
+		if err != nil {
+			log.Printf("[%s] Ignoring incoming message: %v", d.Port(), err)
+			return
+		}
+		s.receive(msg)
+		return
+	}
+
+	mem, index, err := sms.ParseStorageIndex(urc)
+	if err != nil {
+		log.Printf("[%s] Ignoring new message indication: %v", d.Port(), err)
+		return
+	}
+	// Reading the message needs the port, so it cannot block the URC loop
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.SMS.DefaultTimeout)*time.Second)
+		defer cancel()
+		release, err := d.Acquire(ctx, "incoming message")
+		if err != nil {
+			log.Printf("[%s] Failed to read message %s/%d: %v", d.Port(), mem, index, err)
+			return
+		}
+		msg, err := s.smsClient.ReadStoredMessage(ctx, d, mem, index)
+		release()
+		if err != nil {
+			log.Printf("[%s] Failed to read message %s/%d: %v", d.Port(), mem, index, err)
+			return
+		}
+		s.receive(msg)
+	}()
+}
+
+// receive passes an incoming message on once all its parts have arrived
+func (s *SMSService) receive(msg *model.SMS) {
+	s.reassembler.Add(msg)
+}
+
+// publish hands a complete or timed out incoming message to the webhooks
+func (s *SMSService) publish(msg *model.SMS) {
+	msg.ID = utils.GenerateMessageID()
+	event := model.EventSMSReceived
+	if msg.Partial {
+		event = model.EventSMSPartial
+		log.Printf("[%s] Incomplete message %s from %s: %s", msg.Port, msg.ID, msg.From, msg.ErrorMsg)
+	} else {
+		log.Printf("[%s] Received message %s from %s (%d characters)", msg.Port, msg.ID, msg.From, len([]rune(msg.Message)))
+	}
+
+	payload := model.WebhookEvent{
+		Event:     event,
+		Data:      msg,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	// The webhooks are the only place the message is kept, so it stays on
+	// the modem until every one of them has accepted it
+	if !s.config.SMS.DeleteReceived || len(msg.Stored) == 0 {
+		s.webhooks.Dispatch(payload)
+		return
+	}
+	s.webhooks.DispatchConfirmed(payload, func() { s.deleteStored(msg) })
+}
+
+// deleteStored frees the modem memory held by a delivered incoming message
+func (s *SMSService) deleteStored(msg *model.SMS) {
+	d := s.sessions.Device(msg.Port, s.config.Modem.DefaultBaudRate)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.SMS.DefaultTimeout)*time.Second)
+	defer cancel()
+	release, err := d.Acquire(ctx, "delete received message")
+	if err != nil {
+		log.Printf("[%s] Failed to delete message %s: %v", msg.Port, msg.ID, err)
+		return
+	}
+	defer release()
+	if err := s.smsClient.DeleteStoredMessages(ctx, d, msg.Stored); err != nil {
+		log.Printf("[%s] Failed to delete message %s: %v", msg.Port, msg.ID, err)
+	}
+}
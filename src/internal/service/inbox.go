@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+
+	"sms-gateway/src/internal/model"
+)
+
+// ListInbox lists the messages received on a modem; stat is one of the
+// sms.List* filters
+func (s *SMSService) ListInbox(ctx context.Context, port string, stat int, wait bool) ([]model.SMS, error) {
+	baudRate := s.config.Modem.DefaultBaudRate
+	release, err := s.leaseForQuery(ctx, port, baudRate, "inbox", wait)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return s.smsClient.ListMessages(ctx, port, baudRate, stat)
+}
+
+// ReadInbox reads one received message by its storage index
+func (s *SMSService) ReadInbox(ctx context.Context, port string, index int, wait bool) (*model.SMS, error) {
+	baudRate := s.config.Modem.DefaultBaudRate
+	release, err := s.leaseForQuery(ctx, port, baudRate, "inbox", wait)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return s.smsClient.ReadMessage(ctx, port, baudRate, index)
+}
+
+// DeleteInbox deletes one received message by its storage index
+func (s *SMSService) DeleteInbox(ctx context.Context, port string, index int, wait bool) error {
+	baudRate := s.config.Modem.DefaultBaudRate
+	release, err := s.leaseForQuery(ctx, port, baudRate, "inbox", wait)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return s.smsClient.DeleteMessage(ctx, port, baudRate, index)
+}
+
+// ClearInbox deletes every message stored on a modem
+func (s *SMSService) ClearInbox(ctx context.Context, port string, wait bool) error {
+	baudRate := s.config.Modem.DefaultBaudRate
+	release, err := s.leaseForQuery(ctx, port, baudRate, "inbox", wait)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return s.smsClient.DeleteAllMessages(ctx, port, baudRate)
+}
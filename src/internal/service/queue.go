@@ -0,0 +1,493 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/store"
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/session"
+	"sms-gateway/src/pkg/sms"
+)
+
+// maxRetryDelay caps the backoff between two attempts of a send
+const maxRetryDelay = time.Minute
+
+// sendOutcome is the result of a send, passed to a caller waiting for it
+type sendOutcome struct {
+	msg    *model.SMS
+	result *sms.Result
+	err    error
+}
+
+// priorities lists the send priorities from the most urgent. A queue has
+// one lane per priority in this order.
+var priorities = []string{model.PriorityUrgent, model.PriorityHigh, model.PriorityNormal}
+
+// lane returns the lane of a priority; unknown priorities are normal
+func lane(priority string) int {
+	for i, p := range priorities {
+		if p == priority {
+			return i
+		}
+	}
+	return len(priorities) - 1
+}
+
+// portQueue holds the IDs of the queued messages of one modem, oldest
+// first in the lane of their priority
+type portQueue struct {
+	lanes   [][]string
+	skipped []int // sends that went ahead of each waiting lane in a row
+	wake    chan struct{}
+}
+
+func newPortQueue() *portQueue {
+	return &portQueue{
+		lanes:   make([][]string, len(priorities)),
+		skipped: make([]int, len(priorities)),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// next takes the ID of the message to send next, "" when the queue is
+// empty. The most urgent lane goes first, except that a lane passed over
+// limit times in a row sends its oldest message, so that bulk traffic still
+// moves while urgent messages keep coming. Callers must hold queueMu.
+func (q *portQueue) next(limit int) string {
+	pick := -1
+	for i := len(q.lanes) - 1; i >= 0 && limit > 0; i-- {
+		if len(q.lanes[i]) > 0 && q.skipped[i] >= limit {
+			pick = i
+			break
+		}
+	}
+	for i := 0; i < len(q.lanes) && pick < 0; i++ {
+		if len(q.lanes[i]) > 0 {
+			pick = i
+		}
+	}
+	if pick < 0 {
+		return ""
+	}
+
+	id := q.lanes[pick][0]
+	q.lanes[pick] = q.lanes[pick][1:]
+	q.skipped[pick] = 0
+	for i := pick + 1; i < len(q.lanes); i++ {
+		if len(q.lanes[i]) > 0 {
+			q.skipped[i]++
+		}
+	}
+	return id
+}
+
+// enqueue stores msg as pending and queues it on its port. Unless async,
+// the returned channel receives the outcome once the worker of the port
+// sent it.
+func (s *SMSService) enqueue(msg *model.SMS, async bool) (<-chan sendOutcome, error) {
+	msg.Status = model.StatusPending
+	if err := s.messages.Save(msg); err != nil {
+		return nil, fmt.Errorf("failed to store message: %w", err)
+	}
+
+	var done chan sendOutcome
+	if !async {
+		done = make(chan sendOutcome, 1)
+		s.queueMu.Lock()
+		s.waiters[msg.ID] = done
+		s.queueMu.Unlock()
+	}
+
+	s.schedule(msg)
+	return done, nil
+}
+
+// schedule appends a stored pending message to the queue of its port,
+// starting the worker of the port on first use
+func (s *SMSService) schedule(msg *model.SMS) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	q, ok := s.queues[msg.Port]
+	if !ok {
+		q = newPortQueue()
+		s.queues[msg.Port] = q
+		s.workers.Add(1)
+		go s.runWorker(q)
+	}
+	l := lane(msg.Priority)
+	q.lanes[l] = append(q.lanes[l], msg.ID)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// forgetWaiter stops delivering the outcome of a message to its caller
+func (s *SMSService) forgetWaiter(id string) {
+	s.queueMu.Lock()
+	delete(s.waiters, id)
+	s.queueMu.Unlock()
+}
+
+// runWorker sends the queued messages of one modem one after the other, by
+// priority, until the service stops. Messages still queued then stay pending in the
+// store and are picked up again on the next start.
+func (s *SMSService) runWorker(q *portQueue) {
+	defer s.workers.Done()
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		s.queueMu.Lock()
+		id := q.next(s.config.SMS.StarvationLimit)
+		s.queueMu.Unlock()
+
+		if id == "" {
+			select {
+			case <-q.wake:
+			case <-s.stop:
+				return
+			}
+			continue
+		}
+		s.process(id)
+	}
+}
+
+// process makes one attempt at sending a queued message and records the
+// outcome
+func (s *SMSService) process(id string) {
+	msg, err := s.messages.Get(id)
+	if err != nil {
+		log.Printf("Failed to load queued message %s: %v", id, err)
+		return
+	}
+	if msg.Status != model.StatusPending {
+		return
+	}
+
+	msg, err = s.messages.Update(id, func(m *model.SMS) {
+		m.NextAttemptAt = nil
+		m.Attempts = append(m.Attempts, model.SendAttempt{
+			Number:    len(m.Attempts) + 1,
+			Port:      m.Port,
+			StartedAt: time.Now(),
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to record attempt of message %s: %v", id, err)
+		return
+	}
+
+	result, err := s.transmit(msg)
+	s.finish(msg, result, err)
+}
+
+// transmit sends a message on its modem. Each part is recorded as
+// submitting before it is handed to the modem and with its reference once
+// accepted, so that a crash never leaves the state of a part unknown.
+func (s *SMSService) transmit(msg *model.SMS) (*sms.Result, error) {
+	timeout := time.Duration(msg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = time.Duration(s.config.SMS.DefaultTimeout) * time.Second
+	}
+
+	// Only this port is locked, sends on other modems proceed in parallel
+	leaseCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	release, err := s.sessions.Device(msg.Port, msg.BaudRate).Acquire(leaseCtx, "send to "+msg.To)
+	cancel()
+	if err != nil {
+		log.Printf("Port %s not available: %v", msg.Port, err)
+		return nil, err
+	}
+	defer release()
+
+	// Parts accepted by an earlier attempt belong to that attempt
+	s.updateMessage(msg.ID, func(m *model.SMS) {
+		m.Status = model.StatusSending
+		m.Parts = 0
+		m.Segments = nil
+	})
+	progress := &sms.Progress{
+		Submitting: func(part, total int) {
+			s.updateMessage(msg.ID, func(m *model.SMS) {
+				m.Parts = total
+				m.Segments = append(m.Segments, model.SMSSegment{Part: part, Reference: -1, Status: model.SegmentSubmitting})
+			})
+		},
+		Submitted: func(segment model.SMSSegment) {
+			s.updateMessage(msg.ID, func(m *model.SMS) {
+				for i := range m.Segments {
+					if m.Segments[i].Part == segment.Part {
+						m.Segments[i] = segment
+					}
+				}
+			})
+		},
+	}
+
+	ctx := context.Background()
+	if msg.Mode == "pdu" {
+		log.Printf("Calling SMS client SendViaPDU...")
+		return s.smsClient.SendViaPDU(ctx, msg.Port, msg.BaudRate, msg.To, msg.Message, progress)
+	}
+	log.Printf("Calling SMS client SendViaText...")
+	return s.smsClient.SendViaText(ctx, msg.Port, msg.BaudRate, msg.To, msg.Message, progress)
+}
+
+// finish records the outcome of an attempt. A temporary failure before any
+// part may have reached the network is retried after a backoff, on another modem
+// when failover is enabled; otherwise the outcome is handed to the waiting
+// caller.
+func (s *SMSService) finish(msg *model.SMS, result *sms.Result, err error) {
+	retryable := sms.Retryable(err)
+	// Only an answer from the modem tells that a part being submitted was
+	// refused; after a timeout or a lost connection it may have been sent
+	var atErr *at.Error
+	refused := errors.As(err, &atErr)
+	retry := retryable && len(msg.Attempts) <= s.config.SMS.RetryCount
+	delay, port := s.retryDelay(len(msg.Attempts)), msg.Port
+	if retry && s.config.SMS.RetryFailover {
+		port = s.failoverPort(msg.Port)
+	}
+
+	updated, uerr := s.messages.Update(msg.ID, func(m *model.SMS) {
+		// A part the modem refused was not sent
+		if refused {
+			kept := m.Segments[:0]
+			for _, seg := range m.Segments {
+				if seg.Status != model.SegmentSubmitting {
+					kept = append(kept, seg)
+				}
+			}
+			m.Segments = kept
+		}
+		if result != nil {
+			m.Encoding = result.Encoding
+		}
+
+		now := time.Now()
+		if n := len(m.Attempts); n > 0 {
+			m.Attempts[n-1].EndedAt = &now
+			if err != nil {
+				m.Attempts[n-1].Error = err.Error()
+				m.Attempts[n-1].Retryable = retryable
+			}
+		}
+
+		if err != nil {
+			m.ErrorMsg = err.Error()
+			// Sending the accepted parts, or one that may have gone out,
+			// again would duplicate them
+			if len(m.Segments) > 0 {
+				retry = false
+			}
+			if retry {
+				next := now.Add(delay)
+				m.Status = model.StatusPending
+				m.Port = port
+				m.NextAttemptAt = &next
+				return
+			}
+			m.Status = model.StatusFailed
+			return
+		}
+		m.ErrorMsg = ""
+		m.SentAt = &now
+		// Reports of the first parts may already have settled the message
+		if m.Status == model.StatusSending {
+			m.Status = model.StatusSent
+		}
+	})
+	if uerr != nil {
+		log.Printf("Failed to update message %s: %v", msg.ID, uerr)
+		updated = msg
+		retry = false
+	}
+
+	if err != nil {
+		log.Printf("SMS client error for %s: %v", msg.ID, err)
+	} else {
+		log.Printf("SMS sent successfully - MessageID: %s, Mode: %s, Encoding: %s, Parts: %d",
+			msg.ID, result.Mode, result.Encoding, len(result.Segments))
+	}
+	if retry {
+		log.Printf("Retrying message %s on %s in %v (attempt %d of %d)",
+			msg.ID, updated.Port, delay, len(updated.Attempts)+1, s.config.SMS.RetryCount+1)
+		s.retryLater(updated, delay)
+		return
+	}
+
+	s.queueMu.Lock()
+	done, ok := s.waiters[msg.ID]
+	delete(s.waiters, msg.ID)
+	s.queueMu.Unlock()
+	if ok {
+		done <- sendOutcome{msg: updated, result: result, err: err}
+	}
+}
+
+// QueueMetrics returns the number of messages waiting in the queue of each
+// modem by priority, and the totals of all modems
+func (s *SMSService) QueueMetrics() *model.MetricsResponse {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	metrics := &model.MetricsResponse{QueueDepth: map[string]int{}, Queues: []model.QueueMetrics{}}
+	for _, p := range priorities {
+		metrics.QueueDepth[p] = 0
+	}
+	for port, q := range s.queues {
+		m := model.QueueMetrics{Port: port, Depth: map[string]int{}}
+		for i, p := range priorities {
+			m.Depth[p] = len(q.lanes[i])
+			m.Total += len(q.lanes[i])
+			metrics.QueueDepth[p] += len(q.lanes[i])
+		}
+		metrics.Queues = append(metrics.Queues, m)
+	}
+	sort.Slice(metrics.Queues, func(i, j int) bool {
+		return metrics.Queues[i].Port < metrics.Queues[j].Port
+	})
+	return metrics
+}
+
+// retryDelay returns the backoff after the given number of attempts: the
+// configured delay doubled after every failure up to a minute, spread by
+// ±50% so that messages failing together are not retried together
+func (s *SMSService) retryDelay(attempts int) time.Duration {
+	delay := s.config.SMS.RetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)))
+}
+
+// failoverPort picks the ready modem following port in port order, or port
+// itself when no other modem is ready
+func (s *SMSService) failoverPort(port string) string {
+	var ready []string
+	for _, d := range s.sessions.Devices() {
+		if d.Port() == port || d.Health().State == session.StateReady {
+			ready = append(ready, d.Port())
+		}
+	}
+	for i, p := range ready {
+		if p == port {
+			return ready[(i+1)%len(ready)]
+		}
+	}
+	if len(ready) > 0 {
+		return ready[0]
+	}
+	return port
+}
+
+// retryLater queues a pending message again after delay. A message still
+// waiting when the service stops is queued again by the next start.
+func (s *SMSService) retryLater(msg *model.SMS, delay time.Duration) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		select {
+		case <-time.After(delay):
+			s.schedule(msg)
+		case <-s.stop:
+		}
+	}()
+}
+
+// updateMessage applies fn to a stored message, logging failures
+func (s *SMSService) updateMessage(id string, fn func(m *model.SMS)) {
+	if _, err := s.messages.Update(id, fn); err != nil {
+		log.Printf("Failed to update message %s: %v", id, err)
+	}
+}
+
+// recoverMessages resumes the messages a previous run left unfinished.
+// Pending messages are queued again, those waiting for a retry once their
+// backoff is over, and so are messages left sending
+// before any part reached the modem. A message whose parts were all
+// accepted is marked sent. Otherwise it is marked failed rather than sent
+// twice, since the part being submitted at the crash may have gone out.
+func (s *SMSService) recoverMessages() {
+	list, err := s.messages.List(store.Filter{Statuses: []string{model.StatusPending, model.StatusSending}})
+	if err != nil {
+		log.Printf("Failed to load unfinished messages: %v", err)
+		return
+	}
+
+	for _, msg := range list {
+		// The attempt in progress at the crash ended with it
+		if n := len(msg.Attempts); n > 0 && msg.Attempts[n-1].EndedAt == nil {
+			s.updateMessage(msg.ID, func(m *model.SMS) {
+				now := time.Now()
+				m.Attempts[n-1].EndedAt = &now
+				m.Attempts[n-1].Error = "interrupted by a restart"
+			})
+		}
+
+		if msg.Status == model.StatusPending {
+			if msg.NextAttemptAt != nil {
+				s.retryLater(msg, time.Until(*msg.NextAttemptAt))
+			} else {
+				s.schedule(msg)
+			}
+			continue
+		}
+
+		accepted, submitting := 0, 0
+		for _, seg := range msg.Segments {
+			if seg.Status == model.SegmentSubmitting {
+				submitting = seg.Part
+			} else {
+				accepted++
+			}
+		}
+		switch {
+		case len(msg.Segments) == 0:
+			log.Printf("Requeueing message %s interrupted before sending", msg.ID)
+			s.updateMessage(msg.ID, func(m *model.SMS) {
+				m.Status = model.StatusPending
+			})
+			s.schedule(msg)
+		case submitting == 0 && accepted >= msg.Parts:
+			log.Printf("Message %s was sent before the restart", msg.ID)
+			s.updateMessage(msg.ID, func(m *model.SMS) {
+				sentAt := time.Now()
+				m.Status = model.StatusSent
+				m.SentAt = &sentAt
+			})
+		default:
+			reason := fmt.Sprintf("interrupted by a restart after %d of %d parts", accepted, msg.Parts)
+			if submitting > 0 {
+				reason = fmt.Sprintf("interrupted by a restart while part %d of %d was being sent, it may have been delivered", submitting, msg.Parts)
+			}
+			log.Printf("Message %s %s", msg.ID, reason)
+			s.updateMessage(msg.ID, func(m *model.SMS) {
+				m.Status = model.StatusFailed
+				m.ErrorMsg = reason
+			})
+		}
+	}
+	if len(list) > 0 {
+		log.Printf("Recovered %d unfinished messages", len(list))
+	}
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	_ "time/tzdata" // time zones of recurrences on hosts without a zoneinfo database, e.g. Windows
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/store"
+	"sms-gateway/src/internal/utils"
+	"sms-gateway/src/pkg/validation"
+)
+
+// ErrScheduleClosed is returned when a scheduled send that already ran or
+// was cancelled is changed
+var ErrScheduleClosed = errors.New("scheduled message is no longer active")
+
+// errNotDue skips a schedule changed since it was found due
+var errNotDue = errors.New("schedule not due")
+
+// nextRun returns the first time after t matched by a cron recurrence,
+// evaluated in timeZone or, when empty, the server's zone
+func nextRun(recurrence, timeZone string, t time.Time) (time.Time, error) {
+	spec, err := validation.ParseRecurrence(recurrence)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc := time.Local
+	if timeZone != "" {
+		if loc, err = time.LoadLocation(timeZone); err != nil {
+			return time.Time{}, err
+		}
+	}
+	next := spec.Next(t.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("recurrence %q never matches", recurrence)
+	}
+	return next, nil
+}
+
+// scheduleSend stores a send for later: at req.SendAt, then following
+// req.Recurrence if any, or at the next match of the recurrence
+func (s *SMSService) scheduleSend(req *model.SendSMSRequest) (*model.SendSMSResponse, error) {
+	now := time.Now()
+	sch := &model.ScheduledSMS{
+		ID:         utils.GenerateScheduleID(),
+		To:         req.To,
+		Message:    req.Message,
+		Port:       req.Port,
+		BaudRate:   req.BaudRate,
+		Timeout:    req.Timeout,
+		Mode:       req.Mode,
+		Priority:   req.Priority,
+		Status:     model.ScheduleActive,
+		Recurrence: req.Recurrence,
+		TimeZone:   req.TimeZone,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	response := &model.SendSMSResponse{
+		ScheduleID: sch.ID,
+		Mode:       req.Mode,
+		To:         req.To,
+		Message:    req.Message,
+		Timestamp:  now.Format(time.RFC3339),
+	}
+
+	first := req.SendAt
+	if first == nil {
+		next, err := nextRun(req.Recurrence, req.TimeZone, now)
+		if err != nil {
+			response.Error = err.Error()
+			return response, err
+		}
+		first = &next
+	}
+	sch.NextRunAt = first
+
+	if err := s.schedules.SaveSchedule(sch); err != nil {
+		response.Error = err.Error()
+		return response, fmt.Errorf("failed to store scheduled message: %w", err)
+	}
+	log.Printf("SMS scheduled - ScheduleID: %s, SendAt: %s, Recurrence: %q",
+		sch.ID, first.Format(time.RFC3339), sch.Recurrence)
+	s.startScheduler()
+	s.wakeScheduler()
+
+	response.Success = true
+	response.Status = model.ScheduleActive
+	response.SendAt = first
+	return response, nil
+}
+
+// ListSchedules returns the scheduled sends in one of statuses, all of
+// them when none is given, next run first
+func (s *SMSService) ListSchedules(statuses ...string) ([]*model.ScheduledSMS, error) {
+	return s.schedules.ListSchedules(statuses...)
+}
+
+// GetSchedule returns a scheduled send
+func (s *SMSService) GetSchedule(id string) (*model.ScheduledSMS, error) {
+	return s.schedules.GetSchedule(id)
+}
+
+// Reschedule moves the next run of an active schedule or changes its
+// recurrence. Without a new send time, a recurring schedule runs next at
+// the first match of its recurrence from now.
+func (s *SMSService) Reschedule(id string, req *model.RescheduleRequest) (*model.ScheduledSMS, error) {
+	sch, err := s.schedules.UpdateSchedule(id, func(sch *model.ScheduledSMS) error {
+		if sch.Status != model.ScheduleActive {
+			return ErrScheduleClosed
+		}
+		if req.Recurrence != nil {
+			sch.Recurrence = *req.Recurrence
+		}
+		if req.TimeZone != "" {
+			sch.TimeZone = req.TimeZone
+		}
+
+		now := time.Now()
+		switch {
+		case req.SendAt != nil:
+			sch.NextRunAt = req.SendAt
+		case sch.Recurrence != "":
+			next, err := nextRun(sch.Recurrence, sch.TimeZone, now)
+			if err != nil {
+				return err
+			}
+			sch.NextRunAt = &next
+		}
+		sch.UpdatedAt = now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("SMS rescheduled - ScheduleID: %s, NextRun: %s", id, sch.NextRunAt.Format(time.RFC3339))
+	s.wakeScheduler()
+	return sch, nil
+}
+
+// CancelSchedule stops an active schedule. Messages of earlier runs are
+// not affected.
+func (s *SMSService) CancelSchedule(id string) (*model.ScheduledSMS, error) {
+	sch, err := s.schedules.UpdateSchedule(id, func(sch *model.ScheduledSMS) error {
+		if sch.Status != model.ScheduleActive {
+			return ErrScheduleClosed
+		}
+		sch.Status = model.ScheduleCancelled
+		sch.NextRunAt = nil
+		sch.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("SMS schedule cancelled - ScheduleID: %s", id)
+	return sch, nil
+}
+
+// startScheduler starts the loop running due schedules, once
+func (s *SMSService) startScheduler() {
+	s.schedulerOnce.Do(func() {
+		s.workers.Add(1)
+		go s.runScheduler()
+	})
+}
+
+// wakeScheduler makes the scheduler look at the schedules again
+func (s *SMSService) wakeScheduler() {
+	select {
+	case s.scheduleWake <- struct{}{}:
+	default:
+	}
+}
+
+// runScheduler queues the messages of due schedules and sleeps until the
+// next run or a change, until the service stops
+func (s *SMSService) runScheduler() {
+	defer s.workers.Done()
+	for {
+		wait := time.Hour
+		if next := s.runDueSchedules(time.Now()); !next.IsZero() {
+			wait = time.Until(next)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-s.scheduleWake:
+		case <-s.stop:
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+	}
+}
+
+// runDueSchedules runs every schedule due at now and returns the earliest
+// run still ahead, zero when there is none
+func (s *SMSService) runDueSchedules(now time.Time) time.Time {
+	list, err := s.schedules.ListSchedules(model.ScheduleActive)
+	if err != nil {
+		log.Printf("Failed to load scheduled messages: %v", err)
+		return now.Add(time.Minute)
+	}
+
+	var next time.Time
+	for _, sch := range list {
+		if sch.NextRunAt == nil {
+			continue
+		}
+		if !sch.NextRunAt.After(now) {
+			if sch = s.runSchedule(sch.ID, now); sch == nil || sch.NextRunAt == nil {
+				continue
+			}
+		}
+		if next.IsZero() || sch.NextRunAt.Before(next) {
+			next = *sch.NextRunAt
+		}
+	}
+	return next
+}
+
+// runSchedule records a run of a due schedule and queues its message. The
+// ID of the message is stored with the run, so that a crash before it is
+// queued is repaired on the next start. Runs missed while the gateway was
+// down are sent once, then the recurrence continues from now.
+func (s *SMSService) runSchedule(id string, now time.Time) *model.ScheduledSMS {
+	messageID := utils.GenerateMessageID()
+	sch, err := s.schedules.UpdateSchedule(id, func(sch *model.ScheduledSMS) error {
+		if sch.Status != model.ScheduleActive || sch.NextRunAt == nil || sch.NextRunAt.After(now) {
+			return errNotDue
+		}
+		sch.Runs++
+		sch.LastRunAt = &now
+		sch.LastMessageID = messageID
+		sch.UpdatedAt = now
+		sch.NextRunAt = nil
+		sch.Status = model.ScheduleDone
+		if sch.Recurrence == "" {
+			return nil
+		}
+
+		next, err := nextRun(sch.Recurrence, sch.TimeZone, now)
+		if err != nil {
+			log.Printf("Schedule %s ends, its recurrence failed: %v", sch.ID, err)
+			return nil
+		}
+		sch.Status = model.ScheduleActive
+		sch.NextRunAt = &next
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, errNotDue) {
+			log.Printf("Failed to run schedule %s: %v", id, err)
+		}
+		return nil
+	}
+
+	s.sendScheduled(sch, messageID)
+	return sch
+}
+
+// sendScheduled queues the message of a run of sch under messageID
+func (s *SMSService) sendScheduled(sch *model.ScheduledSMS, messageID string) {
+	msg := &model.SMS{
+		ID:         messageID,
+		To:         sch.To,
+		Message:    sch.Message,
+		Port:       sch.Port,
+		Mode:       sch.Mode,
+		BaudRate:   sch.BaudRate,
+		Timeout:    sch.Timeout,
+		Priority:   sch.Priority,
+		ScheduleID: sch.ID,
+		CreatedAt:  time.Now(),
+	}
+	if _, err := s.enqueue(msg, true); err != nil {
+		log.Printf("Failed to queue message of schedule %s: %v", sch.ID, err)
+		return
+	}
+	log.Printf("Scheduled SMS queued - ScheduleID: %s, MessageID: %s, Run: %d", sch.ID, messageID, sch.Runs)
+}
+
+// recoverSchedules queues the message of a last run that a crash left
+// unqueued and starts the scheduler
+func (s *SMSService) recoverSchedules() {
+	list, err := s.schedules.ListSchedules(model.ScheduleActive, model.ScheduleDone)
+	if err != nil {
+		log.Printf("Failed to load scheduled messages: %v", err)
+	}
+	for _, sch := range list {
+		if sch.LastMessageID == "" {
+			continue
+		}
+		if _, err := s.messages.Get(sch.LastMessageID); errors.Is(err, store.ErrNotFound) {
+			log.Printf("Queueing message %s of schedule %s interrupted by a restart", sch.LastMessageID, sch.ID)
+			s.sendScheduled(sch, sch.LastMessageID)
+		}
+	}
+	s.startScheduler()
+}
@@ -2,47 +2,145 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
 	"sms-gateway/src/internal/config"
 	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/store"
+	"sms-gateway/src/internal/utils"
 	"sms-gateway/src/pkg/modem"
+	"sms-gateway/src/pkg/port"
+	"sms-gateway/src/pkg/session"
 	"sms-gateway/src/pkg/sms"
-)
-
-var (
-	portMutex = &sync.Mutex{} // Ensure no conflicts when using ports
+	"sms-gateway/src/pkg/webhook"
 )
 
 // SMSService handles SMS operations
 type SMSService struct {
 	config      *config.Config
+	sessions    *session.Manager
 	modemClient *modem.Client
 	smsClient   *sms.Client
-	mutex       sync.RWMutex
+	messages    store.MessageStore
+	schedules   store.ScheduleStore
+	webhooks    *webhook.Dispatcher
+	reassembler *sms.Reassembler
+
+	ussdMu       sync.Mutex
+	ussdSessions map[string]*ussdSession
+
+	queueMu sync.Mutex
+	queues  map[string]*portQueue        // per port
+	waiters map[string]chan sendOutcome // by message ID
+	workers sync.WaitGroup
+	stop    chan struct{}
+
+	schedulerOnce sync.Once
+	scheduleWake  chan struct{}
 }
 
 // NewSMSService creates a new SMS service instance
 func NewSMSService(cfg *config.Config) *SMSService {
-	return &SMSService{
+	sessions := session.NewManager(cfg.Modem.DefaultBaudRate, cfg.Modem.HealthInterval)
+	numbers, err := store.NewFileNumberStore(cfg.Modem.NumbersFile)
+	if err != nil {
+		log.Printf("Failed to load SIM numbers from %s, starting empty: %v", cfg.Modem.NumbersFile, err)
+		numbers, _ = store.NewFileNumberStore("")
+	}
+	db := openStore(cfg.SMS.StorePath)
+	s := &SMSService{
 		config:      cfg,
-		modemClient: modem.NewClient(cfg),
-		smsClient:   sms.NewClient(cfg),
+		sessions:    sessions,
+		modemClient: modem.NewClient(cfg, sessions, numbers),
+		smsClient:   sms.NewClient(cfg, sessions),
+		messages:    db,
+		schedules:   db,
+		webhooks:    webhook.NewDispatcher(cfg.Webhook.URLs, cfg.Webhook.Timeout, cfg.Webhook.MaxRetries, cfg.Webhook.RetryDelay),
+
+		ussdSessions: make(map[string]*ussdSession),
+
+		queues:  make(map[string]*portQueue),
+		waiters: make(map[string]chan sendOutcome),
+		stop:    make(chan struct{}),
+
+		scheduleWake: make(chan struct{}, 1),
+	}
+	s.reassembler = sms.NewReassembler(cfg.SMS.ReassemblyTimeout, s.publish)
+	sessions.OnConnect(s.watchModem)
+	return s
+}
+
+// openStore opens the message database at path, or keeps messages and
+// schedules in memory when path is empty or the database cannot be opened
+func openStore(path string) store.Store {
+	if path == "" {
+		return store.NewMemoryStore()
+	}
+	db, err := store.NewBoltStore(path)
+	if err != nil {
+		log.Printf("Failed to open message store %s, keeping messages in memory: %v", path, err)
+		return store.NewMemoryStore()
+	}
+	return db
+}
+
+// Start opens the configured modems, keeps their sessions healthy, resumes
+// the messages left unfinished by the previous run and runs scheduled sends
+func (s *SMSService) Start() {
+	ports := s.config.Modem.Ports
+	if len(ports) == 0 {
+		ports = []string{s.config.Modem.DefaultPort}
+	}
+	log.Printf("Opening modem sessions: %v", ports)
+	s.sessions.Start(ports)
+	s.recoverMessages()
+	s.recoverSchedules()
+}
+
+// Close lets the send workers finish their current message, cancels open
+// USSD sessions, closes every modem session and the message store and stops
+// webhook retries
+func (s *SMSService) Close() {
+	close(s.stop)
+	s.workers.Wait()
+	s.closeUSSDSessions()
+	s.sessions.Close()
+	if err := s.messages.Close(); err != nil {
+		log.Printf("Failed to close message store: %v", err)
+	}
+	s.webhooks.Close()
+}
+
+// ModemHealth returns the connection state of every managed modem
+func (s *SMSService) ModemHealth() []model.ModemHealth {
+	var health []model.ModemHealth
+	for _, h := range s.sessions.Health() {
+		mh := model.ModemHealth{
+			Port:       h.Port,
+			State:      h.State,
+			LastError:  h.LastError,
+			Reconnects: h.Reconnects,
+		}
+		if !h.ConnectedAt.IsZero() {
+			mh.ConnectedAt = h.ConnectedAt.Format(time.RFC3339)
+		}
+		if !h.LastCheck.IsZero() {
+			mh.LastCheck = h.LastCheck.Format(time.RFC3339)
+		}
+		health = append(health, mh)
 	}
+	return health
 }
 
 // SendSMS sends an SMS message
 func (s *SMSService) SendSMS(ctx context.Context, req *model.SendSMSRequest) (*model.SendSMSResponse, error) {
-	log.Printf("Starting SMS send process - Port: %s, BaudRate: %d, To: %s, Mode: %s",
-		req.Port, req.BaudRate, req.To, req.Mode)
-
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	log.Printf("Starting SMS send process - Port: %s, BaudRate: %d, To: %s, Mode: %s, Priority: %s",
+		req.Port, req.BaudRate, req.To, req.Mode, req.Priority)
 
 	// Set defaults if not provided
 	if req.Port == "" {
@@ -61,81 +159,177 @@ func (s *SMSService) SendSMS(ctx context.Context, req *model.SendSMSRequest) (*m
 		req.Mode = "text" // Default to text mode
 		log.Printf("Using default mode: %s", req.Mode)
 	}
+	if req.Priority == "" {
+		req.Priority = model.PriorityNormal
+	}
+	if req.SendAt != nil || req.Recurrence != "" {
+		return s.scheduleSend(req)
+	}
 
 	startTime := time.Now()
 
-	// Send SMS using the appropriate mode
-	var steps []string
-	var messageID string
-	var err error
-
-	if req.Mode == "pdu" {
-		log.Printf("Calling SMS client SendViaPDU...")
-		steps, messageID, err = s.smsClient.SendViaPDU(ctx, req.Port, req.BaudRate, req.To, req.Message)
-	} else {
-		log.Printf("Calling SMS client SendViaText...")
-		steps, messageID, err = s.smsClient.SendViaText(ctx, req.Port, req.BaudRate, req.To, req.Message)
+	// The gateway ID identifies the message for its whole life; the network
+	// references of its segments are stored with it once known
+	msg := &model.SMS{
+		ID:        utils.GenerateMessageID(),
+		To:        req.To,
+		Message:   req.Message,
+		Port:      req.Port,
+		Mode:      req.Mode,
+		BaudRate:  req.BaudRate,
+		Timeout:   req.Timeout,
+		Priority:  req.Priority,
+		CreatedAt: startTime,
 	}
-
-	duration := time.Since(startTime)
-
 	response := &model.SendSMSResponse{
-		Steps:     steps,
-		Duration:  duration.String(),
+		MessageID: msg.ID,
 		Mode:      req.Mode,
 		To:        req.To,
 		Message:   req.Message,
-		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
+	// The worker of the port sends queued messages one at a time
+	done, err := s.enqueue(msg, req.Async)
 	if err != nil {
-		log.Printf("SMS client error: %v", err)
-		response.Success = false
 		response.Error = err.Error()
+		response.Timestamp = time.Now().Format(time.RFC3339)
 		return response, err
 	}
+	if req.Async {
+		log.Printf("SMS queued - MessageID: %s, Port: %s", msg.ID, msg.Port)
+		response.Success = true
+		response.Status = model.StatusPending
+		response.Port = msg.Port
+		response.Duration = time.Since(startTime).String()
+		response.Timestamp = time.Now().Format(time.RFC3339)
+		return response, nil
+	}
 
-	log.Printf("SMS sent successfully - MessageID: %s, Steps: %d, Duration: %v, Mode: %s",
-		messageID, len(steps), duration, req.Mode)
-	response.Success = true
-	response.MessageID = messageID
-	return response, nil
+	select {
+	case out := <-done:
+		response.Duration = time.Since(startTime).String()
+		response.Timestamp = time.Now().Format(time.RFC3339)
+		if out.result != nil {
+			response.Steps = out.result.Steps
+			response.Mode = out.result.Mode
+			response.Encoding = out.result.Encoding
+			response.Segments = out.result.Segments
+		}
+		response.Status = out.msg.Status
+		response.Port = out.msg.Port
+		response.Attempts = out.msg.Attempts
+		if out.err != nil {
+			response.Error = out.err.Error()
+			return response, out.err
+		}
+		log.Printf("SMS sent successfully - MessageID: %s, Duration: %v", msg.ID, response.Duration)
+		response.Success = true
+		return response, nil
+	case <-ctx.Done():
+		// The message stays queued and can be followed with its ID
+		s.forgetWaiter(msg.ID)
+		response.Status = model.StatusPending
+		response.Error = "gave up waiting, message " + msg.ID + " is still queued"
+		response.Duration = time.Since(startTime).String()
+		response.Timestamp = time.Now().Format(time.RFC3339)
+		return response, ctx.Err()
+	}
 }
 
-// CheckPortStatus checks if a serial port is available
-func (s *SMSService) CheckPortStatus(portName string) (*model.PortStatus, error) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+// CheckPortStatus checks if a serial port is available. A port busy with
+// another operation is reported as in use instead of being queried.
+func (s *SMSService) CheckPortStatus(ctx context.Context, portName string, wait bool) (*model.PortStatus, error) {
+	release, err := s.leaseForQuery(ctx, portName, 0, "port status", wait)
+	if err != nil {
+		var busy *session.BusyError
+		if errors.As(err, &busy) {
+			return &model.PortStatus{
+				Port:       portName,
+				Available:  true,
+				InUse:      true,
+				InUseBy:    busy.Lease.Owner,
+				InUseSince: busy.Lease.Since.Format(time.RFC3339),
+			}, nil
+		}
+		return nil, err
+	}
+	defer release()
 
-	return s.modemClient.CheckPortStatus(portName)
+	return s.modemClient.CheckPortStatus(ctx, portName)
 }
 
 // GetModemInfo gets modem information
-func (s *SMSService) GetModemInfo(ctx context.Context, port string, baudRate int) (*model.ModemInfo, error) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+func (s *SMSService) GetModemInfo(ctx context.Context, port string, baudRate int, wait bool) (*model.ModemInfo, error) {
+	release, err := s.leaseForQuery(ctx, port, baudRate, "modem info", wait)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	return s.modemClient.GetInfo(ctx, port, baudRate)
 }
 
+// leaseForQuery takes a port for a read-only query. With wait the query
+// queues behind the current holder for up to the configured lease wait,
+// otherwise it fails immediately with a *session.BusyError.
+func (s *SMSService) leaseForQuery(ctx context.Context, portName string, baudRate int, owner string, wait bool) (func(), error) {
+	device := s.sessions.Device(portName, baudRate)
+	if !wait {
+		return device.TryAcquire(owner)
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.config.Modem.LeaseWait)
+	defer cancel()
+	return device.Acquire(ctx, owner)
+}
+
 // ListPorts lists available serial ports
 func (s *SMSService) ListPorts() ([]string, error) {
 	return s.modemClient.ListPorts()
 }
 
 // ListPortsWithInfo lists available serial ports with device information
-func (s *SMSService) ListPortsWithInfo() ([]model.PortInfo, error) {
-	return s.modemClient.ListPortsWithInfo()
+func (s *SMSService) ListPortsWithInfo(ctx context.Context) ([]model.PortInfo, error) {
+	return s.modemClient.ListPortsWithInfo(ctx)
 }
 
 // GetDeviceInfo gets comprehensive device information including SIM details
-func (s *SMSService) GetDeviceInfo(ctx context.Context, port string, baudRate int) (*model.DeviceInfo, error) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+func (s *SMSService) GetDeviceInfo(ctx context.Context, port string, baudRate int, wait bool) (*model.DeviceInfo, error) {
+	release, err := s.leaseForQuery(ctx, port, baudRate, "device info", wait)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	return s.modemClient.GetDeviceInfo(ctx, port, baudRate)
 }
 
+// AssignNumber sets the own number of a SIM by hand. Without an ICCID or
+// IMSI in the request, the SIM in the requested port is identified first.
+func (s *SMSService) AssignNumber(ctx context.Context, req *model.AssignNumberRequest, wait bool) (*model.SIMNumber, error) {
+	if req.ICCID != "" || req.IMSI != "" {
+		return s.modemClient.AssignMsisdn(ctx, req)
+	}
+	if req.Port == "" {
+		req.Port = s.config.Modem.DefaultPort
+	}
+	if req.BaudRate == 0 {
+		req.BaudRate = s.config.Modem.DefaultBaudRate
+	}
+
+	release, err := s.leaseForQuery(ctx, req.Port, req.BaudRate, "number assignment", wait)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return s.modemClient.AssignMsisdn(ctx, req)
+}
+
+// ListNumbers returns the own numbers known for every SIM
+func (s *SMSService) ListNumbers() ([]model.SIMNumber, error) {
+	return s.modemClient.ListNumbers()
+}
+
 // GetAllDevicesInfo gets device information for all available USB ports with optimizations
 func (s *SMSService) GetAllDevicesInfo(ctx context.Context) ([]model.DeviceInfo, error) {
 	startTime := time.Now()
@@ -148,11 +342,11 @@ func (s *SMSService) GetAllDevicesInfo(ctx context.Context) ([]model.DeviceInfo,
 		return nil, fmt.Errorf("failed to get ports: %w", err)
 	}
 
-	// Filter for USB ports only
+	// Filter for USB (and simulated) modem ports only
 	var usbPorts []string
-	for _, port := range allPorts {
-		if strings.Contains(port, "ttyUSB") {
-			usbPorts = append(usbPorts, port)
+	for _, p := range allPorts {
+		if port.IsModemCandidate(p) {
+			usbPorts = append(usbPorts, p)
 		}
 	}
 
@@ -216,7 +410,7 @@ func (s *SMSService) GetAllDevicesInfo(ctx context.Context) ([]model.DeviceInfo,
 			deviceCtx, deviceCancel := context.WithTimeout(overallCtx, deviceTimeout)
 			defer deviceCancel()
 
-			info, err := s.modemClient.GetDeviceInfo(deviceCtx, portName, s.config.Modem.DefaultBaudRate)
+			info, err := s.GetDeviceInfo(deviceCtx, portName, s.config.Modem.DefaultBaudRate, true)
 			workerDuration := time.Since(workerStart)
 			
 			if err != nil {
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/utils"
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/session"
+	"sms-gateway/src/pkg/ussd"
+)
+
+// ErrUSSDSessionNotFound is returned for unknown or already closed sessions
+var ErrUSSDSessionNotFound = errors.New("USSD session not found")
+
+// ussdSession is an open USSD dialog. It holds the lease of its port until
+// the network ends it, the caller cancels it or it stays idle too long, so
+// only one session runs per modem and no other command interleaves with it.
+type ussdSession struct {
+	mu      sync.Mutex // serializes replies
+	info    model.USSDSession
+	device  *session.Device
+	release func()
+	timer   *time.Timer
+	closed  bool
+}
+
+// StartUSSD sends a USSD code and keeps the session open while the network
+// waits for a reply. With wait the request queues behind the current holder
+// of the port, otherwise it fails immediately with a *session.BusyError.
+func (s *SMSService) StartUSSD(ctx context.Context, req *model.USSDRequest, wait bool) (*model.USSDSession, error) {
+	if req.Port == "" {
+		req.Port = s.config.Modem.DefaultPort
+	}
+	if req.BaudRate == 0 {
+		req.BaudRate = s.config.Modem.DefaultBaudRate
+	}
+
+	id := "USSD_" + utils.GenerateID()
+	release, err := s.leaseForQuery(ctx, req.Port, req.BaudRate, "USSD session "+id, wait)
+	if err != nil {
+		return nil, err
+	}
+	device := s.sessions.Device(req.Port, req.BaudRate)
+	us := &ussdSession{
+		info: model.USSDSession{
+			ID:        id,
+			Port:      req.Port,
+			Code:      req.Code,
+			StartedAt: time.Now(),
+		},
+		device:  device,
+		release: release,
+	}
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	resp, err := s.exchangeUSSD(ctx, us, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[%s] USSD %s: %s", req.Port, req.Code, resp.StatusText())
+	if resp.Open() {
+		s.ussdMu.Lock()
+		s.ussdSessions[id] = us
+		s.ussdMu.Unlock()
+	}
+	info := us.info
+	return &info, nil
+}
+
+// ReplyUSSD answers the prompt of an open session
+func (s *SMSService) ReplyUSSD(ctx context.Context, id, input string) (*model.USSDSession, error) {
+	us, err := s.lockUSSD(id)
+	if err != nil {
+		return nil, err
+	}
+	defer us.mu.Unlock()
+
+	if _, err := s.exchangeUSSD(ctx, us, input); err != nil {
+		return nil, err
+	}
+	info := us.info
+	return &info, nil
+}
+
+// CancelUSSD ends an open session with AT+CUSD=2
+func (s *SMSService) CancelUSSD(ctx context.Context, id string) (*model.USSDSession, error) {
+	us, err := s.lockUSSD(id)
+	if err != nil {
+		return nil, err
+	}
+	defer us.mu.Unlock()
+
+	err = s.cancelUSSD(ctx, us)
+	us.info.StatusText = "cancelled"
+	s.closeUSSD(us)
+	info := us.info
+	return &info, err
+}
+
+// GetUSSD returns an open session with its latest answer
+func (s *SMSService) GetUSSD(id string) (*model.USSDSession, error) {
+	us, err := s.lockUSSD(id)
+	if err != nil {
+		return nil, err
+	}
+	defer us.mu.Unlock()
+
+	info := us.info
+	return &info, nil
+}
+
+// exchangeUSSD sends text within the session and records the answer. The
+// session is closed unless the network waits for a further reply. Callers
+// must hold us.mu.
+func (s *SMSService) exchangeUSSD(ctx context.Context, us *ussdSession, text string) (*ussd.Response, error) {
+	if us.timer != nil {
+		us.timer.Stop()
+	}
+
+	sess, err := us.device.Session(ctx)
+	if err != nil {
+		s.closeUSSD(us)
+		return nil, err
+	}
+	charset := ussd.PrepareCharset(ctx, us.device, s.config.Modem.USSDCharset)
+	resp, err := ussd.Send(ctx, sess, text, charset, s.config.Modem.USSDTimeout)
+	if err != nil {
+		// Do not leave a menu open on the network when its answer was lost
+		if !errors.Is(err, ussd.ErrNotSupported) {
+			s.cancelUSSD(context.Background(), us)
+		}
+		s.closeUSSD(us)
+		return nil, err
+	}
+
+	now := time.Now()
+	us.info.Status = resp.Status
+	us.info.StatusText = resp.StatusText()
+	us.info.Message = resp.Text
+	us.info.Raw = resp.Raw
+	us.info.Encoding = resp.Encoding()
+	us.info.UpdatedAt = now
+	if !resp.Open() {
+		s.closeUSSD(us)
+		return resp, nil
+	}
+
+	us.info.Active = true
+	expires := now.Add(s.config.Modem.USSDSessionTimeout)
+	us.info.ExpiresAt = &expires
+	us.timer = time.AfterFunc(s.config.Modem.USSDSessionTimeout, func() {
+		s.expireUSSD(us)
+	})
+	return resp, nil
+}
+
+// expireUSSD cancels a session nobody replied to in time
+func (s *SMSService) expireUSSD(us *ussdSession) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.closed || us.info.ExpiresAt == nil || time.Now().Before(*us.info.ExpiresAt) {
+		return
+	}
+
+	log.Printf("[%s] USSD session %s timed out", us.info.Port, us.info.ID)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.cancelUSSD(ctx, us)
+	us.info.StatusText = "timed out"
+	s.closeUSSD(us)
+}
+
+// handleUSSDURC closes the idle session of a port when the network ends it
+// on its own, e.g. with +CUSD: 2 after its menu timeout. Answers to pending
+// exchanges are handled by exchangeUSSD.
+func (s *SMSService) handleUSSDURC(d *session.Device, urc at.URC) {
+	resp, err := ussd.Parse(urc, d.CharacterSet())
+	if err != nil || resp.Open() {
+		return
+	}
+
+	s.ussdMu.Lock()
+	var open *ussdSession
+	for _, us := range s.ussdSessions {
+		if us.info.Port == d.Port() {
+			open = us
+		}
+	}
+	s.ussdMu.Unlock()
+
+	// A locked session is waiting for this very answer
+	if open == nil || !open.mu.TryLock() {
+		return
+	}
+	defer open.mu.Unlock()
+	if open.closed || !urc.Time.After(open.info.UpdatedAt) {
+		return
+	}
+	log.Printf("[%s] USSD session %s ended by the network: %s", d.Port(), open.info.ID, resp.StatusText())
+	open.info.Status = resp.Status
+	open.info.StatusText = resp.StatusText()
+	if resp.Raw != "" {
+		open.info.Message = resp.Text
+		open.info.Raw = resp.Raw
+		open.info.Encoding = resp.Encoding()
+	}
+	s.closeUSSD(open)
+}
+
+// cancelUSSD sends AT+CUSD=2 for the session. Callers must hold us.mu.
+func (s *SMSService) cancelUSSD(ctx context.Context, us *ussdSession) error {
+	sess, err := us.device.Session(ctx)
+	if err == nil {
+		err = ussd.Cancel(ctx, sess)
+	}
+	if err != nil {
+		log.Printf("[%s] Failed to cancel USSD session %s: %v", us.info.Port, us.info.ID, err)
+	}
+	return err
+}
+
+// closeUSSD forgets the session and releases its port. Callers must hold
+// us.mu.
+func (s *SMSService) closeUSSD(us *ussdSession) {
+	if us.closed {
+		return
+	}
+	us.closed = true
+	us.info.Active = false
+	us.info.ExpiresAt = nil
+	if us.timer != nil {
+		us.timer.Stop()
+	}
+	us.release()
+
+	s.ussdMu.Lock()
+	delete(s.ussdSessions, us.info.ID)
+	s.ussdMu.Unlock()
+}
+
+// lockUSSD returns the open session with the given ID, locked
+func (s *SMSService) lockUSSD(id string) (*ussdSession, error) {
+	s.ussdMu.Lock()
+	us, ok := s.ussdSessions[id]
+	s.ussdMu.Unlock()
+	if !ok {
+		return nil, ErrUSSDSessionNotFound
+	}
+
+	us.mu.Lock()
+	if us.closed {
+		us.mu.Unlock()
+		return nil, ErrUSSDSessionNotFound
+	}
+	return us, nil
+}
+
+// closeUSSDSessions cancels every open session on shutdown
+func (s *SMSService) closeUSSDSessions() {
+	s.ussdMu.Lock()
+	open := make([]*ussdSession, 0, len(s.ussdSessions))
+	for _, us := range s.ussdSessions {
+		open = append(open, us)
+	}
+	s.ussdMu.Unlock()
+
+	for _, us := range open {
+		us.mu.Lock()
+		if !us.closed {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			s.cancelUSSD(ctx, us)
+			cancel()
+			s.closeUSSD(us)
+		}
+		us.mu.Unlock()
+	}
+}
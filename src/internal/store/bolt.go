@@ -0,0 +1,249 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/utils"
+)
+
+// messagesBucket holds the messages as JSON keyed by gateway ID. IDs start
+// with the creation time in seconds, so keys are roughly in send order.
+// schedulesBucket holds the scheduled sends the same way. referencesBucket
+// maps the port, reference and recipient of a segment awaiting its status
+// report to the newest message sent with them, so that a report is matched
+// without reading every message.
+var (
+	messagesBucket   = []byte("messages")
+	schedulesBucket  = []byte("schedules")
+	referencesBucket = []byte("references")
+)
+
+// BoltStore is a Store kept in a bbolt database file, so that messages,
+// their status and scheduled sends survive restarts
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens or creates the database at path. It fails when another
+// process holds the database open.
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{messagesBucket, schedulesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket(referencesBucket) != nil {
+			return nil
+		}
+		// Index the messages of a database written before the index existed
+		if _, err := tx.CreateBucket(referencesBucket); err != nil {
+			return err
+		}
+		return tx.Bucket(messagesBucket).ForEach(func(_, v []byte) error {
+			msg, err := decode(v)
+			if err != nil {
+				return err
+			}
+			return indexReferences(tx, nil, msg)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Save inserts or replaces a message, keeping its status history
+func (s *BoltStore) Save(msg *model.SMS) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(messagesBucket)
+		prev, err := decode(b.Get([]byte(msg.ID)))
+		if err != nil {
+			return err
+		}
+		stored := clone(msg)
+		if prev != nil {
+			stored.History = prev.History
+		}
+		trackStatus(prev, stored)
+		if err := indexReferences(tx, prev, stored); err != nil {
+			return err
+		}
+		return put(b, stored)
+	})
+}
+
+// Get returns the message with the given gateway ID
+func (s *BoltStore) Get(id string) (*model.SMS, error) {
+	var msg *model.SMS
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		msg, err = decode(tx.Bucket(messagesBucket).Get([]byte(id)))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, ErrNotFound
+	}
+	return msg, nil
+}
+
+// FindByReference returns the most recent message sent on port to
+// recipient with a segment carrying reference. The index points at the
+// newest message, so older messages with the same reference are shadowed.
+func (s *BoltStore) FindByReference(port string, reference int, recipient string) (*model.SMS, error) {
+	var found *model.SMS
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(referencesBucket).Get(referenceKey(port, reference, recipient))
+		if id == nil {
+			return nil
+		}
+		// The message may have been sent again since, on another port
+		msg, err := decode(tx.Bucket(messagesBucket).Get(id))
+		if err != nil || msg == nil || msg.Port != port {
+			return err
+		}
+		for _, seg := range msg.Segments {
+			if seg.Reference == reference && awaitingReport(seg) {
+				found = msg
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
+
+// Update applies fn to the stored message atomically and returns the result
+func (s *BoltStore) Update(id string, fn func(msg *model.SMS)) (*model.SMS, error) {
+	var updated *model.SMS
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(messagesBucket)
+		msg, err := decode(b.Get([]byte(id)))
+		if err != nil {
+			return err
+		}
+		if msg == nil {
+			return ErrNotFound
+		}
+		updated = clone(msg)
+		fn(updated)
+		trackStatus(msg, updated)
+		if err := indexReferences(tx, msg, updated); err != nil {
+			return err
+		}
+		return put(b, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// List returns the messages matching f, oldest first
+func (s *BoltStore) List(f Filter) ([]*model.SMS, error) {
+	var list []*model.SMS
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(messagesBucket).ForEach(func(_, v []byte) error {
+			msg, err := decode(v)
+			if err != nil {
+				return err
+			}
+			if f.match(msg) {
+				list = append(list, msg)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortByCreation(list)
+	return list, nil
+}
+
+// Close closes the database
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// referenceKey is the key of a segment in referencesBucket
+func referenceKey(port string, reference int, recipient string) []byte {
+	return []byte(port + "\x00" + strconv.Itoa(reference) + "\x00" + utils.PhoneNumberKey(recipient))
+}
+
+// indexReferences points the index at msg for the references it was given
+// since prev, nil for a new message, and drops the entries of segments that
+// got their final status report
+func indexReferences(tx *bolt.Tx, prev, msg *model.SMS) error {
+	b := tx.Bucket(referencesBucket)
+	known := map[string]bool{}
+	if prev != nil {
+		for _, seg := range prev.Segments {
+			known[string(referenceKey(prev.Port, seg.Reference, prev.To))] = true
+		}
+	}
+	for _, seg := range msg.Segments {
+		if seg.Reference < 0 {
+			continue
+		}
+		key := referenceKey(msg.Port, seg.Reference, msg.To)
+		switch {
+		case !awaitingReport(seg):
+			if string(b.Get(key)) == msg.ID {
+				if err := b.Delete(key); err != nil {
+					return err
+				}
+			}
+		case !known[string(key)]:
+			if err := b.Put(key, []byte(msg.ID)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decode unmarshals a stored message, nil when data is nil
+func decode(data []byte) (*model.SMS, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var msg model.SMS
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// put stores msg under its ID
+func put(b *bolt.Bucket, msg *model.SMS) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(msg.ID), data)
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"sms-gateway/src/internal/model"
+)
+
+// ErrNumberNotFound is returned when no number is known for a SIM
+var ErrNumberNotFound = errors.New("SIM number not found")
+
+// NumberStore keeps the own numbers of SIMs, keyed by ICCID or IMSI so
+// that a number follows its SIM across restarts and port changes
+type NumberStore interface {
+	// Get returns the number of the SIM with the given ICCID or, failing
+	// that, IMSI
+	Get(iccid, imsi string) (*model.SIMNumber, error)
+	// Save records the number of a SIM, replacing the one of the same SIM
+	Save(n *model.SIMNumber) error
+	// List returns every known number
+	List() ([]model.SIMNumber, error)
+}
+
+// FileNumberStore is a NumberStore kept in memory and written to a JSON
+// file after every change
+type FileNumberStore struct {
+	mu      sync.RWMutex
+	path    string
+	numbers []model.SIMNumber
+}
+
+// NewFileNumberStore loads the numbers saved at path. A missing file starts
+// empty; an empty path keeps the numbers in memory only.
+func NewFileNumberStore(path string) (*FileNumberStore, error) {
+	s := &FileNumberStore{path: path}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.numbers); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the number of the SIM with the given ICCID or, failing that,
+// IMSI. The ICCID identifies the card itself, so it is matched first.
+func (s *FileNumberStore) Get(iccid, imsi string) (*model.SIMNumber, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if i := s.find(iccid, ""); i >= 0 {
+		n := s.numbers[i]
+		return &n, nil
+	}
+	if i := s.find("", imsi); i >= 0 {
+		n := s.numbers[i]
+		return &n, nil
+	}
+	return nil, ErrNumberNotFound
+}
+
+// Save records the number of a SIM, replacing the one of the same SIM
+func (s *FileNumberStore) Save(n *model.SIMNumber) error {
+	if n.ICCID == "" && n.IMSI == "" {
+		return errors.New("SIM number needs an ICCID or IMSI")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.find(n.ICCID, n.IMSI); i >= 0 {
+		s.numbers[i] = *n
+	} else {
+		s.numbers = append(s.numbers, *n)
+	}
+	return s.write()
+}
+
+// List returns every known number ordered by ICCID and IMSI
+func (s *FileNumberStore) List() ([]model.SIMNumber, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	numbers := append([]model.SIMNumber(nil), s.numbers...)
+	sort.Slice(numbers, func(i, j int) bool {
+		if numbers[i].ICCID != numbers[j].ICCID {
+			return numbers[i].ICCID < numbers[j].ICCID
+		}
+		return numbers[i].IMSI < numbers[j].IMSI
+	})
+	return numbers, nil
+}
+
+// find returns the index of the entry with the given ICCID or IMSI, -1
+// when there is none. Callers must hold s.mu.
+func (s *FileNumberStore) find(iccid, imsi string) int {
+	for i, n := range s.numbers {
+		if (iccid != "" && n.ICCID == iccid) || (imsi != "" && n.IMSI == imsi) {
+			return i
+		}
+	}
+	return -1
+}
+
+// write replaces the file atomically so that a crash never leaves it
+// half written. Callers must hold s.mu.
+func (s *FileNumberStore) write() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.numbers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+
+	"sms-gateway/src/internal/model"
+)
+
+// ErrScheduleNotFound is returned when no scheduled send matches
+var ErrScheduleNotFound = errors.New("scheduled message not found")
+
+// ScheduleStore keeps scheduled sends until they are done or cancelled
+type ScheduleStore interface {
+	// SaveSchedule inserts or replaces a scheduled send
+	SaveSchedule(sch *model.ScheduledSMS) error
+	// GetSchedule returns the scheduled send with the given ID
+	GetSchedule(id string) (*model.ScheduledSMS, error)
+	// UpdateSchedule applies fn to the stored schedule atomically and
+	// returns the result. Nothing is stored when fn fails.
+	UpdateSchedule(id string, fn func(sch *model.ScheduledSMS) error) (*model.ScheduledSMS, error)
+	// ListSchedules returns the scheduled sends in one of statuses, or all
+	// of them when none is given, next run first
+	ListSchedules(statuses ...string) ([]*model.ScheduledSMS, error)
+}
+
+// Store keeps both outbound messages and scheduled sends
+type Store interface {
+	MessageStore
+	ScheduleStore
+}
+
+// SaveSchedule inserts or replaces a scheduled send
+func (s *MemoryStore) SaveSchedule(sch *model.ScheduledSMS) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := *sch
+	s.schedules[sch.ID] = &c
+	return nil
+}
+
+// GetSchedule returns the scheduled send with the given ID
+func (s *MemoryStore) GetSchedule(id string) (*model.ScheduledSMS, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sch, ok := s.schedules[id]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	c := *sch
+	return &c, nil
+}
+
+// UpdateSchedule applies fn to the stored schedule atomically
+func (s *MemoryStore) UpdateSchedule(id string, fn func(sch *model.ScheduledSMS) error) (*model.ScheduledSMS, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sch, ok := s.schedules[id]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	updated := *sch
+	if err := fn(&updated); err != nil {
+		return nil, err
+	}
+	s.schedules[id] = &updated
+	c := updated
+	return &c, nil
+}
+
+// ListSchedules returns the scheduled sends in one of statuses, next run
+// first
+func (s *MemoryStore) ListSchedules(statuses ...string) ([]*model.ScheduledSMS, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []*model.ScheduledSMS
+	for _, sch := range s.schedules {
+		if hasStatus(sch.Status, statuses) {
+			c := *sch
+			list = append(list, &c)
+		}
+	}
+	sortByNextRun(list)
+	return list, nil
+}
+
+// SaveSchedule inserts or replaces a scheduled send
+func (s *BoltStore) SaveSchedule(sch *model.ScheduledSMS) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putSchedule(tx.Bucket(schedulesBucket), sch)
+	})
+}
+
+// GetSchedule returns the scheduled send with the given ID
+func (s *BoltStore) GetSchedule(id string) (*model.ScheduledSMS, error) {
+	var sch *model.ScheduledSMS
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		sch, err = decodeSchedule(tx.Bucket(schedulesBucket).Get([]byte(id)))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sch == nil {
+		return nil, ErrScheduleNotFound
+	}
+	return sch, nil
+}
+
+// UpdateSchedule applies fn to the stored schedule atomically
+func (s *BoltStore) UpdateSchedule(id string, fn func(sch *model.ScheduledSMS) error) (*model.ScheduledSMS, error) {
+	var updated *model.ScheduledSMS
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(schedulesBucket)
+		sch, err := decodeSchedule(b.Get([]byte(id)))
+		if err != nil {
+			return err
+		}
+		if sch == nil {
+			return ErrScheduleNotFound
+		}
+		if err := fn(sch); err != nil {
+			return err
+		}
+		updated = sch
+		return putSchedule(b, sch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// ListSchedules returns the scheduled sends in one of statuses, next run
+// first
+func (s *BoltStore) ListSchedules(statuses ...string) ([]*model.ScheduledSMS, error) {
+	var list []*model.ScheduledSMS
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(schedulesBucket).ForEach(func(_, v []byte) error {
+			sch, err := decodeSchedule(v)
+			if err != nil {
+				return err
+			}
+			if hasStatus(sch.Status, statuses) {
+				list = append(list, sch)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortByNextRun(list)
+	return list, nil
+}
+
+// decodeSchedule unmarshals a stored schedule, nil when data is nil
+func decodeSchedule(data []byte) (*model.ScheduledSMS, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var sch model.ScheduledSMS
+	if err := json.Unmarshal(data, &sch); err != nil {
+		return nil, err
+	}
+	return &sch, nil
+}
+
+// putSchedule stores sch under its ID
+func putSchedule(b *bolt.Bucket, sch *model.ScheduledSMS) error {
+	data, err := json.Marshal(sch)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(sch.ID), data)
+}
+
+// hasStatus reports whether status is one of statuses, true when statuses
+// is empty
+func hasStatus(status string, statuses []string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// sortByNextRun orders schedules by their next run, those without one
+// last, then by creation
+func sortByNextRun(list []*model.ScheduledSMS) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].NextRunAt, list[j].NextRunAt
+		switch {
+		case a != nil && b != nil && !a.Equal(*b):
+			return a.Before(*b)
+		case (a == nil) != (b == nil):
+			return a != nil
+		}
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+}
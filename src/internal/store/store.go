@@ -0,0 +1,214 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/internal/utils"
+)
+
+// ErrNotFound is returned when no message matches
+var ErrNotFound = errors.New("message not found")
+
+// MessageStore keeps outbound messages together with the references the
+// network assigned to them
+type MessageStore interface {
+	// Save inserts or replaces a message. Status changes are appended to
+	// the history of the stored message.
+	Save(msg *model.SMS) error
+	// Get returns the message with the given gateway ID
+	Get(id string) (*model.SMS, error)
+	// FindByReference returns the most recent message sent on port to
+	// recipient with a segment carrying reference, unless that segment has
+	// its final status report already
+	FindByReference(port string, reference int, recipient string) (*model.SMS, error)
+	// Update applies fn to the stored message atomically and returns the result
+	Update(id string, fn func(msg *model.SMS)) (*model.SMS, error)
+	// List returns the messages matching f, oldest first
+	List(f Filter) ([]*model.SMS, error)
+	// Close releases the storage
+	Close() error
+}
+
+// Filter selects messages in List. Empty fields match every message.
+type Filter struct {
+	Statuses []string
+	Port     string
+	// To matches the recipient in any number format
+	To string
+	// From matches the port a message was sent on or its sender number
+	From string
+}
+
+// match reports whether msg passes the filter
+func (f Filter) match(msg *model.SMS) bool {
+	if f.Port != "" && msg.Port != f.Port {
+		return false
+	}
+	if f.To != "" && !utils.SamePhoneNumber(msg.To, f.To) {
+		return false
+	}
+	if f.From != "" && msg.Port != f.From && (msg.From == "" || !utils.SamePhoneNumber(msg.From, f.From)) {
+		return false
+	}
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, status := range f.Statuses {
+		if msg.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryStore is a Store held in memory
+type MemoryStore struct {
+	mu        sync.RWMutex
+	messages  map[string]*model.SMS
+	order     []string
+	schedules map[string]*model.ScheduledSMS
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		messages:  make(map[string]*model.SMS),
+		schedules: make(map[string]*model.ScheduledSMS),
+	}
+}
+
+// Save inserts or replaces a message, keeping its status history
+func (s *MemoryStore) Save(msg *model.SMS) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.messages[msg.ID]
+	if !ok {
+		s.order = append(s.order, msg.ID)
+	}
+	stored := clone(msg)
+	if ok {
+		// The history is kept by the store, not by callers
+		stored.History = append([]model.StatusChange(nil), prev.History...)
+	}
+	trackStatus(prev, stored)
+	s.messages[msg.ID] = stored
+	return nil
+}
+
+// Get returns the message with the given gateway ID
+func (s *MemoryStore) Get(id string) (*model.SMS, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	msg, ok := s.messages[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(msg), nil
+}
+
+// FindByReference returns the most recent message sent on port to
+// recipient with a segment carrying reference. References wrap at 255, so
+// older messages with the same reference and recipient are shadowed.
+func (s *MemoryStore) FindByReference(port string, reference int, recipient string) (*model.SMS, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.order) - 1; i >= 0; i-- {
+		msg := s.messages[s.order[i]]
+		if msg.Port != port || !utils.SamePhoneNumber(msg.To, recipient) {
+			continue
+		}
+		for _, seg := range msg.Segments {
+			if seg.Reference == reference {
+				if !awaitingReport(seg) {
+					return nil, ErrNotFound
+				}
+				return clone(msg), nil
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Update applies fn to the stored message atomically and returns the result
+func (s *MemoryStore) Update(id string, fn func(msg *model.SMS)) (*model.SMS, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	updated := clone(msg)
+	fn(updated)
+	trackStatus(msg, updated)
+	s.messages[id] = updated
+	return clone(updated), nil
+}
+
+// List returns the messages matching f, oldest first
+func (s *MemoryStore) List(f Filter) ([]*model.SMS, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []*model.SMS
+	for _, id := range s.order {
+		if msg := s.messages[id]; f.match(msg) {
+			list = append(list, clone(msg))
+		}
+	}
+	sortByCreation(list)
+	return list, nil
+}
+
+// Close does nothing for a memory store
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// trackStatus appends the status of msg to its history when it differs
+// from the stored version prev, nil for a new message
+func trackStatus(prev, msg *model.SMS) {
+	if prev != nil && prev.Status == msg.Status {
+		return
+	}
+	change := model.StatusChange{Status: msg.Status, At: time.Now()}
+	switch msg.Status {
+	case model.StatusFailed, model.StatusExpired, model.StatusPending:
+		// A message back to pending waits to be retried after an error
+		change.Error = msg.ErrorMsg
+	}
+	msg.History = append(msg.History, change)
+}
+
+// awaitingReport reports whether the network accepted seg and may still
+// send a status report for it
+func awaitingReport(seg model.SMSSegment) bool {
+	switch seg.Status {
+	case model.StatusDelivered, model.StatusFailed, model.StatusExpired:
+		return false
+	}
+	return seg.Reference >= 0
+}
+
+// sortByCreation orders messages oldest first
+func sortByCreation(list []*model.SMS) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+}
+
+// clone copies a message so callers never share state with the store
+func clone(msg *model.SMS) *model.SMS {
+	c := *msg
+	c.Segments = append([]model.SMSSegment(nil), msg.Segments...)
+	c.History = append([]model.StatusChange(nil), msg.History...)
+	c.Attempts = append([]model.SendAttempt(nil), msg.Attempts...)
+	return &c
+}
@@ -0,0 +1,262 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"sms-gateway/src/internal/model"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+
+	first := &model.SMS{ID: "a", To: "0912345678", Port: "sim://1", CreatedAt: time.Now(),
+		Segments: []model.SMSSegment{{Part: 1, Reference: 7}}}
+	second := &model.SMS{ID: "b", To: "0987654321", Port: "sim://1", CreatedAt: time.Now(),
+		Segments: []model.SMSSegment{{Part: 1, Reference: 8}, {Part: 2, Reference: 9}}}
+	other := &model.SMS{ID: "c", To: "0912345678", Port: "sim://2", CreatedAt: time.Now(),
+		Segments: []model.SMSSegment{{Part: 1, Reference: 7}}}
+	for _, msg := range []*model.SMS{first, second, other} {
+		if err := s.Save(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := s.Get("b")
+	if err != nil || got.To != "0987654321" {
+		t.Fatalf("Get(b) = %+v, %v", got, err)
+	}
+	got.Segments[0].Reference = 99
+	if again, _ := s.Get("b"); again.Segments[0].Reference != 8 {
+		t.Error("Get must return a copy")
+	}
+
+	if got, err := s.FindByReference("sim://1", 9, "+84987654321"); err != nil || got.ID != "b" {
+		t.Errorf("reference of a later segment: %+v, %v", got, err)
+	}
+	if got, err := s.FindByReference("sim://2", 7, "0912345678"); err != nil || got.ID != "c" {
+		t.Errorf("references are per port: %+v, %v", got, err)
+	}
+	if _, err := s.FindByReference("sim://1", 10, "0912345678"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown reference: %v", err)
+	}
+
+	// A wrapped reference resolves to the newest message
+	newer := &model.SMS{ID: "d", To: "0912345678", Port: "sim://1", Segments: []model.SMSSegment{{Part: 1, Reference: 7}}}
+	s.Save(newer)
+	if got, _ := s.FindByReference("sim://1", 7, "+84912345678"); got.ID != "d" {
+		t.Errorf("expected newest message, got %s", got.ID)
+	}
+
+	// The recipient must match as well as the reference
+	if _, err := s.FindByReference("sim://1", 8, "0912345678"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("reference of another recipient matched: %v", err)
+	}
+
+	updated, err := s.Update("a", func(msg *model.SMS) { msg.Status = model.StatusDelivered })
+	if err != nil || updated.Status != model.StatusDelivered {
+		t.Errorf("Update = %+v, %v", updated, err)
+	}
+	if _, err := s.Update("missing", func(*model.SMS) {}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update of unknown message: %v", err)
+	}
+
+	for _, tt := range []struct {
+		filter Filter
+		want   int
+	}{
+		{Filter{To: "+84912345678"}, 3},
+		{Filter{From: "sim://2"}, 1},
+		{Filter{To: "0912345678", From: "sim://1", Statuses: []string{model.StatusDelivered}}, 1},
+	} {
+		if list, _ := s.List(tt.filter); len(list) != tt.want {
+			t.Errorf("List(%+v) = %d messages, want %d", tt.filter, len(list), tt.want)
+		}
+	}
+}
+
+func TestFileNumberStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "numbers", "sim_numbers.json")
+	s, err := NewFileNumberStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("8984", "4520"); !errors.Is(err, ErrNumberNotFound) {
+		t.Errorf("empty store: %v", err)
+	}
+	if err := s.Save(&model.SIMNumber{PhoneNumber: "0912345678"}); err == nil {
+		t.Error("a number without ICCID or IMSI must be rejected")
+	}
+
+	s.Save(&model.SIMNumber{ICCID: "8984", IMSI: "4520", PhoneNumber: "0912345678", Source: model.NumberSourceUSSD})
+	s.Save(&model.SIMNumber{IMSI: "4521", PhoneNumber: "0987654321", Source: model.NumberSourceManual})
+	// The same SIM replaces its entry
+	s.Save(&model.SIMNumber{ICCID: "8984", IMSI: "4520", PhoneNumber: "0911111111", Source: model.NumberSourceManual})
+
+	// Numbers survive a restart and are found by ICCID or IMSI
+	reloaded, err := NewFileNumberStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := reloaded.Get("8984", ""); err != nil || n.PhoneNumber != "0911111111" {
+		t.Errorf("by ICCID: %+v, %v", n, err)
+	}
+	if n, err := reloaded.Get("other", "4521"); err != nil || n.PhoneNumber != "0987654321" {
+		t.Errorf("by IMSI: %+v, %v", n, err)
+	}
+	if list, _ := reloaded.List(); len(list) != 2 {
+		t.Errorf("List = %+v", list)
+	}
+}
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.db")
+	s, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Now()
+	msg := &model.SMS{ID: "SMS_1_a", To: "0912345678", Port: "sim://1", Status: model.StatusPending, CreatedAt: created}
+	s.Save(msg)
+	msg.Status = model.StatusSending
+	s.Save(msg)
+	s.Save(&model.SMS{ID: "SMS_2_b", To: "0987654321", Port: "sim://2", Status: model.StatusPending, CreatedAt: created.Add(time.Second)})
+	if _, err := s.Update("SMS_1_a", func(m *model.SMS) {
+		m.Status = model.StatusFailed
+		m.ErrorMsg = "modem error"
+		m.Segments = []model.SMSSegment{{Part: 1, Reference: 7}}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Messages and their status history survive a restart
+	s, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	got, err := s.Get("SMS_1_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	for _, change := range got.History {
+		statuses = append(statuses, change.Status)
+	}
+	if len(statuses) != 3 || statuses[0] != model.StatusPending || statuses[1] != model.StatusSending ||
+		statuses[2] != model.StatusFailed || got.History[2].Error != "modem error" {
+		t.Errorf("history = %+v", got.History)
+	}
+	if _, err := s.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing): %v", err)
+	}
+
+	if found, err := s.FindByReference("sim://1", 7, "+84912345678"); err != nil || found.ID != "SMS_1_a" {
+		t.Errorf("FindByReference = %+v, %v", found, err)
+	}
+	if list, _ := s.List(Filter{Statuses: []string{model.StatusPending, model.StatusSending}}); len(list) != 1 || list[0].ID != "SMS_2_b" {
+		t.Errorf("List(pending, sending) = %+v", list)
+	}
+	if list, _ := s.List(Filter{}); len(list) != 2 || list[0].ID != "SMS_1_a" {
+		t.Errorf("List() must return every message oldest first: %+v", list)
+	}
+}
+
+func TestFindByReference(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.db")
+	db, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, s := range map[string]Store{"memory": NewMemoryStore(), "bolt": db} {
+		older := &model.SMS{ID: "SMS_1_a", To: "0912345678", Port: "sim://1", CreatedAt: time.Now(),
+			Segments: []model.SMSSegment{{Part: 1, Reference: 7}}}
+		newer := &model.SMS{ID: "SMS_2_b", To: "+84912345678", Port: "sim://1", CreatedAt: time.Now(),
+			Segments: []model.SMSSegment{{Part: 1, Reference: 8}, {Part: 2, Reference: -1, Status: model.SegmentSubmitting}}}
+		s.Save(older)
+		s.Save(newer)
+
+		// The second part gets the wrapped reference of the older message
+		s.Update(newer.ID, func(m *model.SMS) { m.Segments[1] = model.SMSSegment{Part: 2, Reference: 7} })
+		if got, err := s.FindByReference("sim://1", 7, "0912345678"); err != nil || got.ID != newer.ID {
+			t.Errorf("%s: a reused reference must resolve to the newest message: %+v, %v", name, got, err)
+		}
+
+		// A segment with its final report expects no other one
+		s.Update(newer.ID, func(m *model.SMS) { m.Segments[1].Status = model.StatusDelivered })
+		if _, err := s.FindByReference("sim://1", 7, "0912345678"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: reference of a delivered segment matched: %v", name, err)
+		}
+		if got, err := s.FindByReference("sim://1", 8, "0912345678"); err != nil || got.ID != newer.ID {
+			t.Errorf("%s: reference of a pending segment: %+v, %v", name, got, err)
+		}
+	}
+
+	// A database written before the index existed is indexed when opened
+	db.db.Update(func(tx *bolt.Tx) error { return tx.DeleteBucket(referencesBucket) })
+	db.Close()
+	db, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got, err := db.FindByReference("sim://1", 8, "0912345678"); err != nil || got.ID != "SMS_2_b" {
+		t.Errorf("reference of a database without index: %+v, %v", got, err)
+	}
+}
+
+func TestScheduleStore(t *testing.T) {
+	db, err := NewBoltStore(filepath.Join(t.TempDir(), "messages.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for name, s := range map[string]Store{"memory": NewMemoryStore(), "bolt": db} {
+		now := time.Now()
+		later, sooner := now.Add(time.Hour), now.Add(time.Minute)
+		for _, sch := range []*model.ScheduledSMS{
+			{ID: "SCH_1", Status: model.ScheduleActive, NextRunAt: &later, CreatedAt: now},
+			{ID: "SCH_2", Status: model.ScheduleActive, NextRunAt: &sooner, CreatedAt: now},
+			{ID: "SCH_3", Status: model.ScheduleDone, CreatedAt: now},
+		} {
+			if err := s.SaveSchedule(sch); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		list, _ := s.ListSchedules(model.ScheduleActive)
+		if len(list) != 2 || list[0].ID != "SCH_2" || list[1].ID != "SCH_1" {
+			t.Errorf("%s: active schedules must be listed next run first: %+v", name, list)
+		}
+		if list, _ := s.ListSchedules(); len(list) != 3 || list[2].ID != "SCH_3" {
+			t.Errorf("%s: schedules without a next run must come last: %+v", name, list)
+		}
+
+		// A failing update leaves the schedule as it was
+		if _, err := s.UpdateSchedule("SCH_1", func(sch *model.ScheduledSMS) error {
+			sch.Status = model.ScheduleCancelled
+			return errors.New("refused")
+		}); err == nil {
+			t.Errorf("%s: update error must be returned", name)
+		}
+		updated, err := s.UpdateSchedule("SCH_1", func(sch *model.ScheduledSMS) error {
+			sch.Runs++
+			return nil
+		})
+		if got, _ := s.GetSchedule("SCH_1"); err != nil || updated.Runs != 1 || got.Runs != 1 || got.Status != model.ScheduleActive {
+			t.Errorf("%s: UpdateSchedule = %+v, %v; stored %+v", name, updated, err, got)
+		}
+		if _, err := s.GetSchedule("missing"); !errors.Is(err, ErrScheduleNotFound) {
+			t.Errorf("%s: GetSchedule(missing): %v", name, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -14,11 +15,17 @@ func GenerateID() string {
 	return hex.EncodeToString(bytes)
 }
 
-// GenerateMessageID generates a message ID with timestamp
+// GenerateMessageID generates a message ID with timestamp. The 64 random
+// bits keep IDs unique across messages sent in the same second.
 func GenerateMessageID() string {
 	timestamp := time.Now().Unix()
-	id := GenerateID()
-	return fmt.Sprintf("SMS_%d_%s", timestamp, id[:8])
+	return fmt.Sprintf("SMS_%d_%s", timestamp, GenerateID())
+}
+
+// GenerateScheduleID generates the ID of a scheduled send, shaped like a
+// message ID
+func GenerateScheduleID() string {
+	return fmt.Sprintf("SCH_%d_%s", time.Now().Unix(), GenerateID())
 }
 
 // FormatDuration formats duration to human readable string
@@ -43,3 +50,33 @@ func SanitizeString(s string) string {
 	}
 	return result
 }
+
+// SamePhoneNumber reports whether two numbers denote the same subscriber,
+// ignoring formatting and the country code or trunk prefix, e.g.
+// "+84912345678" and "0912345678"
+func SamePhoneNumber(a, b string) bool {
+	ka := PhoneNumberKey(a)
+	return ka != "" && ka == PhoneNumberKey(b)
+}
+
+// PhoneNumberKey returns the significant digits of a number, equal for
+// numbers SamePhoneNumber matches and "" when there are none
+func PhoneNumberKey(s string) string {
+	const significant = 9
+	digits := digitsOnly(s)
+	if len(digits) > significant {
+		digits = digits[len(digits)-significant:]
+	}
+	return digits
+}
+
+// digitsOnly strips everything but decimal digits
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,14 @@
+package at
+
+import (
+	"sms-gateway/src/pkg/port"
+)
+
+// Open opens a modem port (serial or simulated) and starts a session on it
+func Open(portName string, baudRate int) (*Session, error) {
+	p, err := port.Open(portName, baudRate)
+	if err != nil {
+		return nil, err
+	}
+	return NewSession(portName, p), nil
+}
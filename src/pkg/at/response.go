@@ -0,0 +1,120 @@
+package at
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Final result codes
+const (
+	ResultOK       = "OK"
+	ResultError    = "ERROR"
+	ResultCMEError = "+CME ERROR"
+	ResultCMSError = "+CMS ERROR"
+)
+
+// finalErrors are final result codes that terminate a command unsuccessfully
+var finalErrors = []string{
+	ResultError,
+	"NO CARRIER",
+	"NO DIALTONE",
+	"NO ANSWER",
+	"BUSY",
+	"COMMAND NOT SUPPORT",
+	"TOO MANY PARAMETERS",
+}
+
+// Response is the outcome of a single command exchange
+type Response struct {
+	Command string   // command as sent, without terminator
+	Lines   []string // information lines, excluding echo and final result
+	Result  string   // final result code line, e.g. "OK" or "+CMS ERROR: 500"
+}
+
+// Error describes a command that finished with an error result code
+type Error struct {
+	Command string
+	Result  string // ERROR, +CME ERROR or +CMS ERROR
+	Code    int    // numeric CME/CMS code, -1 when absent
+	Text    string // verbose error text when the modem uses AT+CMEE=2
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	switch {
+	case e.Code >= 0:
+		return fmt.Sprintf("%s: %s: %d", e.Command, e.Result, e.Code)
+	case e.Text != "":
+		return fmt.Sprintf("%s: %s: %s", e.Command, e.Result, e.Text)
+	default:
+		return fmt.Sprintf("%s: %s", e.Command, e.Result)
+	}
+}
+
+// IsFinalResult reports whether line is a final result code
+func IsFinalResult(line string) bool {
+	if line == ResultOK {
+		return true
+	}
+	if strings.HasPrefix(line, ResultCMEError+":") || strings.HasPrefix(line, ResultCMSError+":") {
+		return true
+	}
+	for _, code := range finalErrors {
+		if line == code {
+			return true
+		}
+	}
+	return false
+}
+
+// OK reports whether the command finished with OK
+func (r *Response) OK() bool {
+	return r != nil && r.Result == ResultOK
+}
+
+// Err returns nil for OK and an *Error for any error result code
+func (r *Response) Err() error {
+	if r.OK() {
+		return nil
+	}
+	e := &Error{Command: r.Command, Result: r.Result, Code: -1}
+	for _, prefix := range []string{ResultCMEError, ResultCMSError} {
+		if strings.HasPrefix(r.Result, prefix+":") {
+			e.Result = prefix
+			detail := strings.TrimSpace(strings.TrimPrefix(r.Result, prefix+":"))
+			if code, err := strconv.Atoi(detail); err == nil {
+				e.Code = code
+			} else {
+				e.Text = detail
+			}
+		}
+	}
+	return e
+}
+
+// Text returns the information lines joined by newlines
+func (r *Response) Text() string {
+	return strings.Join(r.Lines, "\n")
+}
+
+// Value returns the first information line, which is the whole answer for
+// commands like AT+CGMI, AT+CGSN or AT+CIMI
+func (r *Response) Value() string {
+	if len(r.Lines) == 0 {
+		return ""
+	}
+	return r.Lines[0]
+}
+
+// Prefixed returns the payload of every line starting with prefix
+// (e.g. "+CSQ:"), with the prefix and surrounding spaces removed
+func (r *Response) Prefixed(prefix string) []string {
+	var values []string
+	for _, line := range r.Lines {
+		if strings.HasPrefix(line, prefix) {
+			values = append(values, strings.TrimSpace(strings.TrimPrefix(line, prefix)))
+		}
+	}
+	return values
+}
@@ -0,0 +1,379 @@
+package at
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is applied to commands that are not given an explicit timeout
+const DefaultTimeout = 5 * time.Second
+
+// ctrlZ terminates the payload of prompt commands such as AT+CMGS and esc
+// abandons it
+const (
+	ctrlZ = "\x1A"
+	esc   = "\x1B"
+)
+
+var (
+	// ErrTimeout is returned when no final result code arrives in time
+	ErrTimeout = errors.New("timeout waiting for modem response")
+	// ErrClosed is returned when the session or its port has been closed
+	ErrClosed = errors.New("modem session closed")
+	// ErrWrite wraps the port error when a command cannot be written
+	ErrWrite = errors.New("write to modem failed")
+)
+
+// Session owns a serial port and runs AT command exchanges over it.
+// A single reader goroutine consumes the port and splits it into lines,
+// so no bytes are lost between commands.
+type Session struct {
+	name string
+	port io.ReadWriteCloser
+
+	cmdSlot chan struct{} // serializes command exchanges
+
+	mu          sync.Mutex
+	current     *exchange
+	pendingURC  *URC // two-line URC waiting for its data line
+	subscribers []*subscriber
+	closed      bool
+	readErr     error
+	done        chan struct{}
+}
+
+// exchange is the state of the command currently waiting for its result
+type exchange struct {
+	command string
+	name    string // response prefix, e.g. "+CMGS"
+	lines   []string
+	prompt  chan struct{}
+	result  chan *Response
+	gotEcho bool
+}
+
+// NewSession wraps an open port and starts its reader goroutine
+func NewSession(name string, port io.ReadWriteCloser) *Session {
+	s := &Session{
+		name:    name,
+		port:    port,
+		cmdSlot: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go s.readLoop()
+	return s
+}
+
+// Name returns the port name the session was opened on
+func (s *Session) Name() string {
+	return s.name
+}
+
+// Done is closed when the reader goroutine stops
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that stopped the reader goroutine, if any
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readErr
+}
+
+// Close closes the port and stops the reader goroutine
+func (s *Session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	err := s.port.Close()
+	select {
+	case <-s.done:
+	case <-time.After(2 * time.Second):
+		logf(s.name, "reader did not stop after close")
+	}
+	return err
+}
+
+// Command sends a command and waits for its final result code
+func (s *Session) Command(ctx context.Context, command string) (*Response, error) {
+	return s.CommandTimeout(ctx, command, DefaultTimeout)
+}
+
+// CommandTimeout sends a command and waits up to timeout for its final result code
+func (s *Session) CommandTimeout(ctx context.Context, command string, timeout time.Duration) (*Response, error) {
+	return s.exec(ctx, command, "", false, timeout)
+}
+
+// SendPayload sends a prompt command (AT+CMGS, AT+CMGW), waits for the "> "
+// prompt, writes payload terminated by Ctrl+Z and waits for the final result
+func (s *Session) SendPayload(ctx context.Context, command, payload string, timeout time.Duration) (*Response, error) {
+	return s.exec(ctx, command, payload, true, timeout)
+}
+
+func (s *Session) exec(ctx context.Context, command, payload string, withPrompt bool, timeout time.Duration) (*Response, error) {
+	// Waiting for the previous exchange does not count against timeout, but
+	// the caller may give up
+	select {
+	case s.cmdSlot <- struct{}{}:
+		defer func() { <-s.cmdSlot }()
+	case <-s.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, waitError(ctx, command)
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ex := &exchange{
+		command: command,
+		name:    commandName(command),
+		prompt:  make(chan struct{}),
+		result:  make(chan *Response, 1),
+	}
+
+	s.mu.Lock()
+	if s.closed || s.readErr != nil {
+		s.mu.Unlock()
+		return nil, ErrClosed
+	}
+	s.current = ex
+	s.mu.Unlock()
+	defer s.clearExchange(ex)
+
+	if _, err := s.port.Write([]byte(command + "\r")); err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", command, ErrWrite, err)
+	}
+
+	if withPrompt {
+		select {
+		case <-ex.prompt:
+		case resp := <-ex.result:
+			// The modem rejected the command before prompting
+			return resp, resp.Err()
+		case <-s.done:
+			return nil, ErrClosed
+		case <-ctx.Done():
+			// The prompt may still come and leave the modem waiting for input
+			s.abortPayload(command)
+			return nil, waitError(ctx, command)
+		}
+
+		if _, err := s.port.Write([]byte(payload + ctrlZ)); err != nil {
+			return nil, fmt.Errorf("%s payload: %w: %w", command, ErrWrite, err)
+		}
+	}
+
+	select {
+	case resp := <-ex.result:
+		return resp, resp.Err()
+	case <-s.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		if withPrompt {
+			s.abortPayload(command)
+		}
+		return nil, waitError(ctx, command)
+	}
+}
+
+// abortPayload sends ESC so that a modem still reading the payload of a
+// prompt command drops it instead of swallowing the next command
+func (s *Session) abortPayload(command string) {
+	if _, err := s.port.Write([]byte(esc)); err != nil {
+		logf(s.name, "failed to abort %s: %v", command, err)
+	}
+}
+
+// clearExchange detaches ex once its caller has stopped waiting for it
+func (s *Session) clearExchange(ex *exchange) {
+	s.mu.Lock()
+	if s.current == ex {
+		s.current = nil
+	}
+	s.mu.Unlock()
+}
+
+// waitError converts a finished context into the error reported to callers
+func waitError(ctx context.Context, command string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s: %w", command, ErrTimeout)
+	}
+	return ctx.Err()
+}
+
+// WaitURC reads urcs (from Subscribe) until a URC named name arrives
+func WaitURC(ctx context.Context, urcs <-chan URC, name string, timeout time.Duration) (URC, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		select {
+		case urc := <-urcs:
+			if urc.Name == name {
+				return urc, nil
+			}
+		case <-ctx.Done():
+			return URC{}, waitError(ctx, name)
+		}
+	}
+}
+
+// readLoop is the only reader of the port. It splits the byte stream into
+// lines and hands them to the pending exchange or the unsolicited listeners.
+func (s *Session) readLoop() {
+	defer close(s.done)
+
+	buf := make([]byte, 512)
+	var pending bytes.Buffer
+	for {
+		n, err := s.port.Read(buf)
+		if n > 0 {
+			pending.Write(buf[:n])
+			s.drain(&pending)
+		}
+		if err != nil {
+			s.mu.Lock()
+			if !s.closed {
+				logf(s.name, "reader stopped: %v", err)
+			}
+			s.readErr = err
+			s.mu.Unlock()
+			return
+		}
+		if n == 0 {
+			// Ports with a read timeout return (0, nil) when idle
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+		}
+	}
+}
+
+// drain consumes complete lines (and a bare "> " prompt) from pending
+func (s *Session) drain(pending *bytes.Buffer) {
+	for {
+		data := pending.Bytes()
+		idx := bytes.IndexAny(data, "\r\n")
+		if idx < 0 {
+			// The SMS prompt is not followed by a line terminator
+			if rest := strings.TrimSpace(string(data)); rest == ">" {
+				pending.Reset()
+				s.handlePrompt()
+			}
+			return
+		}
+		// A USSD menu spans several lines inside its quotes; wait for the
+		// closing quote and keep the line breaks as part of the text
+		if head := string(data[:idx]); strings.HasPrefix(strings.TrimSpace(head), URCUSSD) && strings.Count(head, "\"")%2 == 1 {
+			closing := bytes.IndexByte(data[idx:], '"')
+			if closing < 0 {
+				return
+			}
+			end := bytes.IndexAny(data[idx+closing:], "\r\n")
+			if end < 0 {
+				return
+			}
+			idx += closing + end
+		}
+		line := strings.TrimSpace(strings.ReplaceAll(string(data[:idx]), "\r\n", "\n"))
+		pending.Next(idx + 1)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, ">") && strings.TrimSpace(strings.TrimPrefix(line, ">")) == "" {
+			s.handlePrompt()
+			continue
+		}
+		s.handleLine(line)
+	}
+}
+
+func (s *Session) handlePrompt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ex := s.current; ex != nil {
+		select {
+		case <-ex.prompt:
+		default:
+			close(ex.prompt)
+		}
+	}
+}
+
+func (s *Session) handleLine(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The line after +CMT/+CDS/+CBM is the payload of that URC
+	if urc := s.pendingURC; urc != nil {
+		s.pendingURC = nil
+		urc.Data = line
+		s.publish(urc)
+		return
+	}
+
+	ex := s.current
+	if ex == nil && !isURC(line) {
+		// The rest of a response whose caller gave up waiting
+		logf(s.name, "discarded line outside a command: %s", line)
+		return
+	}
+	if ex == nil || s.unsolicitedDuring(ex, line) {
+		urc := newURC(line)
+		if hasData(urc) {
+			s.pendingURC = urc
+			return
+		}
+		s.publish(urc)
+		return
+	}
+
+	// Drop the command echo when the modem still has echo enabled
+	if !ex.gotEcho && len(ex.lines) == 0 && strings.EqualFold(line, ex.command) {
+		ex.gotEcho = true
+		return
+	}
+
+	if IsFinalResult(line) {
+		ex.result <- &Response{Command: ex.command, Lines: ex.lines, Result: line}
+		s.current = nil
+		return
+	}
+	ex.lines = append(ex.lines, line)
+}
+
+// unsolicitedDuring reports whether line, read while ex is running, is a URC
+// rather than part of the response. A URC name that matches the command
+// (+CREG: during AT+CREG?) is the command's own answer.
+func (s *Session) unsolicitedDuring(ex *exchange, line string) bool {
+	if !isURC(line) {
+		return false
+	}
+	name := lineName(line)
+	return alwaysURC[name] || !strings.EqualFold(name, ex.name)
+}
+
+// logf logs a message tagged with the port name
+func logf(port, format string, args ...interface{}) {
+	log.Printf("AT [%s]: "+format, append([]interface{}{port}, args...)...)
+}
@@ -0,0 +1,243 @@
+package at
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pipePort joins the host ends of two pipes into a port
+type pipePort struct {
+	io.Reader
+	io.Writer
+	closers []io.Closer
+}
+
+func (p *pipePort) Close() error {
+	for _, c := range p.closers {
+		c.Close()
+	}
+	return nil
+}
+
+// modem is the far end of a test session: it reads what the session writes
+// and answers with lines
+type modem struct {
+	t   *testing.T
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// newTestSession starts a session over pipes and returns it with its modem
+func newTestSession(t *testing.T) (*Session, *modem) {
+	t.Helper()
+	hostR, modemW := io.Pipe()
+	modemR, hostW := io.Pipe()
+	port := &pipePort{Reader: hostR, Writer: hostW, closers: []io.Closer{hostR, hostW, modemR, modemW}}
+	sess := NewSession("test", port)
+	t.Cleanup(func() { sess.Close() })
+	return sess, &modem{t: t, in: bufio.NewReader(modemR), out: modemW}
+}
+
+// expect reads what the session wrote up to and including delim and
+// compares it with want
+func (m *modem) expect(want string, delim byte) {
+	m.t.Helper()
+	got := make(chan string, 1)
+	go func() {
+		data, _ := m.in.ReadString(delim)
+		got <- data
+	}()
+	select {
+	case data := <-got:
+		if data != want {
+			m.t.Fatalf("modem read %q, want %q", data, want)
+		}
+	case <-time.After(2 * time.Second):
+		m.t.Fatalf("modem did not receive %q", want)
+	}
+}
+
+// command expects an AT command line
+func (m *modem) command(command string) {
+	m.t.Helper()
+	m.expect(command+"\r", '\r')
+}
+
+// send writes each line as the modem frames it
+func (m *modem) send(lines ...string) {
+	m.t.Helper()
+	for _, line := range lines {
+		if _, err := io.WriteString(m.out, "\r\n"+line+"\r\n"); err != nil {
+			m.t.Fatalf("modem write: %v", err)
+		}
+	}
+}
+
+// prompt writes the SMS prompt, which has no line terminator
+func (m *modem) prompt() {
+	m.t.Helper()
+	if _, err := io.WriteString(m.out, "\r\n> "); err != nil {
+		m.t.Fatalf("modem write: %v", err)
+	}
+}
+
+type result struct {
+	resp *Response
+	err  error
+}
+
+// async runs a command in the background, as the modem side of a test
+// answers from the test goroutine
+func async(f func() (*Response, error)) <-chan result {
+	ch := make(chan result, 1)
+	go func() {
+		resp, err := f()
+		ch <- result{resp, err}
+	}()
+	return ch
+}
+
+// wait returns the result of a command started with async
+func wait(t *testing.T, ch <-chan result) result {
+	t.Helper()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("command did not return")
+		return result{}
+	}
+}
+
+func TestCommandResponse(t *testing.T) {
+	sess, m := newTestSession(t)
+
+	done := async(func() (*Response, error) { return sess.Command(context.Background(), "AT+CMGR=1") })
+	m.command("AT+CMGR=1")
+	// Echo, a header and a body line that merely contains OK
+	m.send("AT+CMGR=1", `+CMGR: "REC READ","+84912345678",,"26/10/16,09:00:00+28"`, "OK, see you at 9", "OK")
+
+	r := wait(t, done)
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+	want := []string{`+CMGR: "REC READ","+84912345678",,"26/10/16,09:00:00+28"`, "OK, see you at 9"}
+	if !r.resp.OK() || !reflect.DeepEqual(r.resp.Lines, want) {
+		t.Errorf("response = %+v, want lines %q", r.resp, want)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	for _, tt := range []struct {
+		result string
+		want   Error
+	}{
+		{"ERROR", Error{Command: "AT+CPIN?", Result: ResultError, Code: -1}},
+		{"+CME ERROR: 10", Error{Command: "AT+CPIN?", Result: ResultCMEError, Code: 10}},
+		{"+CMS ERROR: 500", Error{Command: "AT+CPIN?", Result: ResultCMSError, Code: 500}},
+		{"+CME ERROR: SIM not inserted", Error{Command: "AT+CPIN?", Result: ResultCMEError, Code: -1, Text: "SIM not inserted"}},
+	} {
+		sess, m := newTestSession(t)
+		done := async(func() (*Response, error) { return sess.Command(context.Background(), "AT+CPIN?") })
+		m.command("AT+CPIN?")
+		m.send(tt.result)
+
+		var atErr *Error
+		if r := wait(t, done); !errors.As(r.err, &atErr) || *atErr != tt.want {
+			t.Errorf("%s: error = %#v, want %#v", tt.result, r.err, tt.want)
+		}
+	}
+}
+
+func TestSendPayload(t *testing.T) {
+	sess, m := newTestSession(t)
+
+	done := async(func() (*Response, error) {
+		return sess.SendPayload(context.Background(), "AT+CMGS=18", "0011000B914889214365F70000AA05C8329BFD06", time.Second)
+	})
+	m.command("AT+CMGS=18")
+	m.prompt()
+	m.expect("0011000B914889214365F70000AA05C8329BFD06\x1A", 0x1A)
+	m.send("+CMGS: 42", "OK")
+
+	r := wait(t, done)
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+	if refs := r.resp.Prefixed("+CMGS:"); len(refs) != 1 || refs[0] != "42" {
+		t.Errorf("references = %q, want [42]", refs)
+	}
+}
+
+func TestSendPayloadRejected(t *testing.T) {
+	sess, m := newTestSession(t)
+
+	done := async(func() (*Response, error) {
+		return sess.SendPayload(context.Background(), "AT+CMGS=18", "00", time.Second)
+	})
+	m.command("AT+CMGS=18")
+	m.send("+CMS ERROR: 304")
+
+	var atErr *Error
+	if r := wait(t, done); !errors.As(r.err, &atErr) || atErr.Code != 304 {
+		t.Errorf("error = %v, want +CMS ERROR: 304", r.err)
+	}
+}
+
+func TestSendPayloadTimeoutAborts(t *testing.T) {
+	t.Run("after prompt", func(t *testing.T) {
+		sess, m := newTestSession(t)
+		done := async(func() (*Response, error) {
+			return sess.SendPayload(context.Background(), "AT+CMGS=18", "00", 100*time.Millisecond)
+		})
+		m.command("AT+CMGS=18")
+		m.prompt()
+		m.expect("00\x1A", 0x1A)
+		m.expect("\x1B", 0x1B)
+		if r := wait(t, done); !errors.Is(r.err, ErrTimeout) {
+			t.Errorf("error = %v, want timeout", r.err)
+		}
+	})
+
+	t.Run("before prompt", func(t *testing.T) {
+		sess, m := newTestSession(t)
+		done := async(func() (*Response, error) {
+			return sess.SendPayload(context.Background(), "AT+CMGS=18", "00", 100*time.Millisecond)
+		})
+		m.command("AT+CMGS=18")
+		m.expect("\x1B", 0x1B)
+		if r := wait(t, done); !errors.Is(r.err, ErrTimeout) {
+			t.Errorf("error = %v, want timeout", r.err)
+		}
+	})
+}
+
+func TestCommandWaitHonorsContext(t *testing.T) {
+	sess, m := newTestSession(t)
+
+	first := async(func() (*Response, error) {
+		return sess.SendPayload(context.Background(), "AT+CMGS=18", "00", 5*time.Second)
+	})
+	m.command("AT+CMGS=18")
+
+	// A command queued behind a slow send gives up with its caller
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if r := wait(t, async(func() (*Response, error) { return sess.Command(ctx, "AT") })); !errors.Is(r.err, ErrTimeout) {
+		t.Errorf("queued command error = %v, want timeout", r.err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("queued command returned after %s", elapsed)
+	}
+
+	m.send("+CMS ERROR: 304")
+	if r := wait(t, first); r.err == nil {
+		t.Error("expected the send to fail")
+	}
+}
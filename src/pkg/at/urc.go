@@ -0,0 +1,166 @@
+package at
+
+import (
+	"strings"
+	"time"
+)
+
+// Well-known unsolicited result codes
+const (
+	URCNewMessage       = "+CMTI" // new message stored: +CMTI: "SM",3
+	URCMessage          = "+CMT"  // message routed to TE, followed by a PDU/text line
+	URCStatusReportRef  = "+CDSI" // status report stored: +CDSI: "SR",2
+	URCStatusReport     = "+CDS"  // status report routed to TE, followed by a PDU line
+	URCBroadcast        = "+CBM"  // cell broadcast, followed by a PDU line
+	URCUSSD             = "+CUSD" // USSD answer or network initiated USSD
+	URCRing             = "RING"
+	URCCellularRing     = "+CRING"
+	URCCallerID         = "+CLIP"
+	URCRegistration     = "+CREG"
+	URCGPRSRegistration = "+CGREG"
+	URCEPSRegistration  = "+CEREG"
+)
+
+// urcNames are the line prefixes recognised as unsolicited while a command
+// is running. Vendor codes (Huawei ^, Quectel +Q...) are included so that
+// periodic reports never leak into a command response.
+var urcNames = []string{
+	URCNewMessage,
+	URCMessage,
+	URCStatusReportRef,
+	URCStatusReport,
+	URCBroadcast,
+	URCUSSD,
+	URCRing,
+	URCCellularRing,
+	URCCallerID,
+	URCRegistration,
+	URCGPRSRegistration,
+	URCEPSRegistration,
+	"+CIEV",
+	"+CPIN",
+	"^RSSI",
+	"^BOOT",
+	"^MODE",
+	"^SRVST",
+	"^SIMST",
+	"+QIND",
+}
+
+// urcWithData are URCs whose payload follows on the next line
+var urcWithData = map[string]bool{
+	URCMessage:      true,
+	URCStatusReport: true,
+	URCBroadcast:    true,
+}
+
+// hasData reports whether a data line follows urc. A text mode status
+// report carries all its fields on the +CDS line itself, while the PDU
+// mode form "+CDS: <length>" is followed by the PDU.
+func hasData(urc *URC) bool {
+	if urc.Name == URCStatusReport {
+		return !strings.Contains(urc.Params, ",")
+	}
+	return urcWithData[urc.Name]
+}
+
+// alwaysURC never belong to a command response even when they echo the
+// command name; a USSD answer may arrive before or after the final OK.
+var alwaysURC = map[string]bool{
+	URCUSSD: true,
+}
+
+// URC is an unsolicited result code reported by the modem
+type URC struct {
+	Name   string    // code name, e.g. "+CMTI" or "RING"
+	Params string    // text after "<name>:", trimmed
+	Line   string    // the complete first line
+	Data   string    // second line for +CMT, PDU mode +CDS and +CBM
+	Time   time.Time // when the line was read
+}
+
+// lineName returns the result code name of a line: the text before ':'
+// for "+XXX: ..." lines and the whole line otherwise
+func lineName(line string) string {
+	if i := strings.Index(line, ":"); i > 0 {
+		return strings.TrimSpace(line[:i])
+	}
+	return line
+}
+
+// isURC reports whether line is a known unsolicited result code
+func isURC(line string) bool {
+	name := lineName(line)
+	for _, n := range urcNames {
+		if name == n {
+			return true
+		}
+	}
+	return false
+}
+
+// commandName returns the response prefix of an extended command, e.g.
+// "+CREG" for "AT+CREG?" and "+CMGS" for "AT+CMGS=23"
+func commandName(command string) string {
+	upper := strings.ToUpper(command)
+	if !strings.HasPrefix(upper, "AT") {
+		return ""
+	}
+	name := command[2:]
+	if i := strings.IndexAny(name, "=?"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// newURC parses a URC line
+func newURC(line string) *URC {
+	name := lineName(line)
+	urc := &URC{Name: name, Line: line, Time: time.Now()}
+	if name != line {
+		urc.Params = strings.TrimSpace(line[len(name)+1:])
+	}
+	return urc
+}
+
+// subscriber is a registered URC listener
+type subscriber struct {
+	ch chan URC
+}
+
+// Subscribe registers a listener for every URC reported on the port. The
+// returned function unsubscribes and must be called when done. URCs are
+// dropped for listeners that do not keep up with the stream.
+func (s *Session) Subscribe(buffer int) (<-chan URC, func()) {
+	sub := &subscriber{ch: make(chan URC, buffer)}
+
+	s.mu.Lock()
+	s.subscribers = append(s.subscribers, sub)
+	s.mu.Unlock()
+
+	return sub.ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, other := range s.subscribers {
+			if other == sub {
+				s.subscribers = append(s.subscribers[:i], s.subscribers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// publish hands a URC to every subscriber. Callers must hold s.mu.
+func (s *Session) publish(urc *URC) {
+	if len(s.subscribers) == 0 {
+		logf(s.name, "unhandled URC: %s", urc.Line)
+		return
+	}
+	for _, sub := range s.subscribers {
+		select {
+		case sub.ch <- *urc:
+		default:
+			logf(s.name, "URC listener is full, dropped: %s", urc.Line)
+		}
+	}
+}
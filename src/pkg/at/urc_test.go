@@ -0,0 +1,127 @@
+package at
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// nextURC returns the next URC published to urcs
+func nextURC(t *testing.T, urcs <-chan URC) URC {
+	t.Helper()
+	select {
+	case urc := <-urcs:
+		return urc
+	case <-time.After(2 * time.Second):
+		t.Fatal("no URC published")
+		return URC{}
+	}
+}
+
+func TestURCDuringCommand(t *testing.T) {
+	sess, m := newTestSession(t)
+	urcs, unsubscribe := sess.Subscribe(8)
+	defer unsubscribe()
+
+	done := async(func() (*Response, error) { return sess.Command(context.Background(), "AT+CSQ") })
+	m.command("AT+CSQ")
+	m.send("RING", "+CSQ: 20,99", `+CMTI: "SM",3`, "OK")
+
+	r := wait(t, done)
+	if r.err != nil || !reflect.DeepEqual(r.resp.Lines, []string{"+CSQ: 20,99"}) {
+		t.Fatalf("response = %+v, %v", r.resp, r.err)
+	}
+	if urc := nextURC(t, urcs); urc.Name != URCRing {
+		t.Errorf("first URC = %+v, want RING", urc)
+	}
+	if urc := nextURC(t, urcs); urc.Name != URCNewMessage || urc.Params != `"SM",3` {
+		t.Errorf("second URC = %+v, want +CMTI", urc)
+	}
+}
+
+func TestURCAnsweringCommand(t *testing.T) {
+	sess, m := newTestSession(t)
+	urcs, unsubscribe := sess.Subscribe(8)
+	defer unsubscribe()
+
+	// +CREG: is the answer to AT+CREG? and a URC otherwise
+	done := async(func() (*Response, error) { return sess.Command(context.Background(), "AT+CREG?") })
+	m.command("AT+CREG?")
+	m.send("+CREG: 0,1", "OK")
+	if r := wait(t, done); r.err != nil || r.resp.Value() != "+CREG: 0,1" {
+		t.Fatalf("response = %+v, %v", r.resp, r.err)
+	}
+
+	m.send("+CREG: 5")
+	if urc := nextURC(t, urcs); urc.Name != URCRegistration || urc.Params != "5" {
+		t.Errorf("URC = %+v, want +CREG: 5", urc)
+	}
+}
+
+func TestURCWithData(t *testing.T) {
+	sess, m := newTestSession(t)
+	urcs, unsubscribe := sess.Subscribe(8)
+	defer unsubscribe()
+
+	m.send("+CMT: ,24", "07914889214365F7040B914889214365F70000621061900000000AC8329BFD06")
+	if urc := nextURC(t, urcs); urc.Name != URCMessage || urc.Params != ",24" || urc.Data != "07914889214365F7040B914889214365F70000621061900000000AC8329BFD06" {
+		t.Errorf("+CMT = %+v", urc)
+	}
+
+	// The data line of a status report is not taken as part of a response
+	done := async(func() (*Response, error) { return sess.Command(context.Background(), "AT+CSQ") })
+	m.command("AT+CSQ")
+	m.send("+CDS: 25", "07914889214365F706230B914889214365F7", "+CSQ: 18,99", "OK")
+	if r := wait(t, done); r.err != nil || !reflect.DeepEqual(r.resp.Lines, []string{"+CSQ: 18,99"}) {
+		t.Fatalf("response = %+v, %v", r.resp, r.err)
+	}
+	if urc := nextURC(t, urcs); urc.Name != URCStatusReport || urc.Data != "07914889214365F706230B914889214365F7" {
+		t.Errorf("+CDS = %+v", urc)
+	}
+
+	// A text mode status report fits on one line
+	m.send(`+CDS: 6,42,"+84912345678",145,"26/10/16,09:00:00+28","26/10/16,09:00:05+28",0`)
+	if urc := nextURC(t, urcs); urc.Name != URCStatusReport || urc.Data != "" {
+		t.Errorf("text +CDS = %+v", urc)
+	}
+}
+
+func TestMultiLineUSSD(t *testing.T) {
+	sess, m := newTestSession(t)
+	urcs, unsubscribe := sess.Subscribe(8)
+	defer unsubscribe()
+
+	done := async(func() (*Response, error) {
+		return sess.Command(context.Background(), `AT+CUSD=1,"*101#",15`)
+	})
+	m.command(`AT+CUSD=1,"*101#",15`)
+	m.send("OK", "+CUSD: 1,\"1. Nap tien\r\n2. Tra cuu\r\n3. Thoat\",15")
+
+	if r := wait(t, done); r.err != nil || len(r.resp.Lines) != 0 {
+		t.Fatalf("response = %+v, %v", r.resp, r.err)
+	}
+	want := "1,\"1. Nap tien\n2. Tra cuu\n3. Thoat\",15"
+	if urc := nextURC(t, urcs); urc.Name != URCUSSD || urc.Params != want {
+		t.Errorf("URC = %+v, want params %q", urc, want)
+	}
+}
+
+func TestLateResponseAfterTimeout(t *testing.T) {
+	sess, m := newTestSession(t)
+	urcs, unsubscribe := sess.Subscribe(8)
+	defer unsubscribe()
+
+	done := async(func() (*Response, error) {
+		return sess.CommandTimeout(context.Background(), "AT+COPS?", 50*time.Millisecond)
+	})
+	m.command("AT+COPS?")
+	if r := wait(t, done); r.err == nil {
+		t.Fatalf("expected a timeout, got %+v", r.resp)
+	}
+
+	m.send(`+COPS: 0,0,"Viettel",7`, "OK", "RING")
+	if urc := nextURC(t, urcs); urc.Name != URCRing {
+		t.Errorf("late response published as URC: %+v", urc)
+	}
+}
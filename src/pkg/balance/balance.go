@@ -0,0 +1,238 @@
+package balance
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"sms-gateway/src/internal/model"
+)
+
+// Parser extracts the structured fields of a balance from the decoded text
+// of a USSD answer. Fields it cannot find are left empty.
+type Parser interface {
+	Parse(text string, b *model.Balance)
+}
+
+// ParserFunc adapts a function to the Parser interface
+type ParserFunc func(text string, b *model.Balance)
+
+// Parse calls f(text, b)
+func (f ParserFunc) Parse(text string, b *model.Balance) {
+	f(text, b)
+}
+
+var (
+	mu      sync.RWMutex
+	parsers = map[string]Parser{}
+)
+
+// Register installs the parser for an operator name as reported by
+// modem.Client (e.g. "Viettel"), replacing any previous one
+func Register(operator string, p Parser) {
+	mu.Lock()
+	defer mu.Unlock()
+	parsers[strings.ToLower(operator)] = p
+}
+
+// Parse builds a balance from the decoded answer to the balance code using
+// the parser of operator, or the generic rules for unknown operators
+func Parse(operator, text string) *model.Balance {
+	b := &model.Balance{Text: text, Operator: operator}
+
+	mu.RLock()
+	p, ok := parsers[strings.ToLower(operator)]
+	mu.RUnlock()
+	if !ok {
+		p = Generic
+	}
+	p.Parse(text, b)
+	return b
+}
+
+// Rules is a Parser driven by the labels an operator puts in front of each
+// figure, given as regular expressions over lower case text without
+// Vietnamese diacritics, e.g. "tk chinh" also matches "TK chính"
+type Rules struct {
+	Main       []string // main balance, e.g. "tkc", "tk chinh"
+	Promotions []string // promotional balances, e.g. "km\\d*"
+	Data       []string // data allowances, e.g. "data"
+}
+
+// Figures are "<label>[:=] <number> <unit>" and dates apply to the figure
+// they follow, as in "TKC: 12.345d, HSD: 31/12/2026"
+const (
+	figure = `\s*(?:[:=]|la)?\s*(\d[\d.,]*)\s*(vnd|dong|d|kb|mb|gb)\b`
+	date   = `\b(\d{1,2})[/-](\d{1,2})[/-](\d{2,4})\b`
+)
+
+var dateRe = regexp.MustCompile(date)
+
+// Parse implements Parser
+func (r Rules) Parse(text string, b *model.Balance) {
+	folded := Fold(text)
+	groups := map[string][]string{
+		"main":  r.Main,
+		"promo": r.Promotions,
+		"data":  r.Data,
+	}
+	type match struct {
+		kind       string
+		label      string
+		start, end int
+		number     string
+		unit       string
+	}
+	var matches []match
+	for kind, labels := range groups {
+		if len(labels) == 0 {
+			continue
+		}
+		re := regexp.MustCompile(`\b(` + strings.Join(labels, "|") + `)` + figure)
+		for _, m := range re.FindAllStringSubmatchIndex(folded, -1) {
+			matches = append(matches, match{
+				kind:   kind,
+				label:  folded[m[2]:m[3]],
+				start:  m[0],
+				end:    m[1],
+				number: folded[m[4]:m[5]],
+				unit:   folded[m[6]:m[7]],
+			})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	for i, m := range matches {
+		// A figure owns the first date before the next figure
+		next := len(folded)
+		if i+1 < len(matches) {
+			next = matches[i+1].start
+		}
+		expires := ""
+		if d := dateRe.FindStringSubmatch(folded[m.end:next]); d != nil {
+			expires = isoDate(d[1], d[2], d[3])
+		}
+
+		money := isMoney(m.unit)
+		switch {
+		case m.kind == "main" && money && b.Amount == nil:
+			amount := parseAmount(m.number, true)
+			b.Amount = &amount
+			b.Currency = "VND"
+			b.ExpiresAt = expires
+		case m.kind == "promo" && money:
+			b.Promotions = append(b.Promotions, model.BalanceAmount{
+				Name:      strings.ToUpper(m.label),
+				Amount:    parseAmount(m.number, true),
+				Currency:  "VND",
+				ExpiresAt: expires,
+			})
+		case m.kind == "data" && !money:
+			b.Data = append(b.Data, model.DataAllowance{
+				Name:      strings.ToUpper(m.label),
+				MB:        toMB(parseAmount(m.number, false), m.unit),
+				ExpiresAt: expires,
+			})
+		}
+	}
+
+	// Answers such as "HSD: 31/12/2026" before the amount still date the
+	// main balance
+	if b.Amount != nil && b.ExpiresAt == "" {
+		if d := dateRe.FindStringSubmatch(folded); d != nil {
+			b.ExpiresAt = isoDate(d[1], d[2], d[3])
+		}
+	}
+}
+
+// isMoney reports whether unit is a currency rather than a data unit
+func isMoney(unit string) bool {
+	return unit == "d" || unit == "vnd" || unit == "dong"
+}
+
+// parseAmount parses "12.345" or "12,345" as 12345. Dong have no
+// subunit, so for money every separator groups thousands; otherwise a
+// separator followed by exactly three digits does, as in "1.024MB" but not
+// "1.5GB".
+func parseAmount(s string, money bool) float64 {
+	s = strings.TrimRight(s, ".,")
+	if money {
+		s = strings.NewReplacer(".", "", ",", "").Replace(s)
+	} else {
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if c != '.' && c != ',' {
+				b.WriteByte(c)
+				continue
+			}
+			rest := s[i+1:]
+			if len(rest) >= 3 && strings.IndexAny(rest[:3], ".,") < 0 && (len(rest) == 3 || rest[3] == '.' || rest[3] == ',') {
+				continue
+			}
+			b.WriteByte('.')
+		}
+		s = b.String()
+	}
+	value, _ := strconv.ParseFloat(s, 64)
+	return value
+}
+
+// toMB converts a data amount to megabytes
+func toMB(value float64, unit string) float64 {
+	switch unit {
+	case "gb":
+		return value * 1024
+	case "kb":
+		return value / 1024
+	}
+	return value
+}
+
+// isoDate formats a day/month/year date as YYYY-MM-DD; two digit years are
+// in this century
+func isoDate(day, month, year string) string {
+	d, _ := strconv.Atoi(day)
+	m, _ := strconv.Atoi(month)
+	y, _ := strconv.Atoi(year)
+	if y < 100 {
+		y += 2000
+	}
+	if d < 1 || d > 31 || m < 1 || m > 12 {
+		return ""
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", y, m, d)
+}
+
+// vietnamese maps the letters with diacritics to their base letter
+var vietnamese = map[rune]rune{}
+
+func init() {
+	for base, letters := range map[rune]string{
+		'a': "àáảãạăằắẳẵặâầấẩẫậ",
+		'e': "èéẻẽẹêềếểễệ",
+		'i': "ìíỉĩị",
+		'o': "òóỏõọôồốổỗộơờớởỡợ",
+		'u': "ùúủũụưừứửữự",
+		'y': "ỳýỷỹỵ",
+		'd': "đ",
+	} {
+		for _, r := range letters {
+			vietnamese[r] = base
+		}
+	}
+}
+
+// Fold lowercases text and removes Vietnamese diacritics, so that "Tài
+// khoản chính: 12.345đ" reads "tai khoan chinh: 12.345d"
+func Fold(text string) string {
+	return strings.Map(func(r rune) rune {
+		if base, ok := vietnamese[r]; ok {
+			return base
+		}
+		return r
+	}, strings.ToLower(text))
+}
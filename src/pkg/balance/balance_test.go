@@ -0,0 +1,157 @@
+package balance
+
+import (
+	"testing"
+
+	"sms-gateway/src/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		operator   string
+		text       string
+		amount     float64
+		expires    string
+		promotions []model.BalanceAmount
+		data       []model.DataAllowance
+	}{
+		{
+			operator: "Viettel",
+			text:     "TKC: 12.345d, HSD: 31/12/2026. KM1: 5.000d, HSD: 15/11/2026. DATA: 1.5GB",
+			amount:   12345,
+			expires:  "2026-12-31",
+			promotions: []model.BalanceAmount{
+				{Name: "KM1", Amount: 5000, Currency: "VND", ExpiresAt: "2026-11-15"},
+			},
+			data: []model.DataAllowance{{Name: "DATA", MB: 1536}},
+		},
+		{
+			operator: "Mobifone",
+			text:     "TK chinh: 120345 d, het han 31-12-2026; TK KM1: 5000 d; TK data: 2.048MB",
+			amount:   120345,
+			expires:  "2026-12-31",
+			promotions: []model.BalanceAmount{
+				{Name: "TK KM1", Amount: 5000, Currency: "VND"},
+			},
+			data: []model.DataAllowance{{Name: "TK DATA", MB: 2048}},
+		},
+		{
+			operator: "Vinaphone",
+			text:     "TK chinh=1.234.567 VND, HSD:01/02/27; TK KM=5,000 VND",
+			amount:   1234567,
+			expires:  "2027-02-01",
+			promotions: []model.BalanceAmount{
+				{Name: "TK KM", Amount: 5000, Currency: "VND"},
+			},
+		},
+		{
+			operator: "Vietnamobile",
+			text:     "Tài khoản gốc: 12.345đ. Hạn dùng: 31/12/2026. TK KM: 2.000đ",
+			amount:   12345,
+			expires:  "2026-12-31",
+			promotions: []model.BalanceAmount{
+				{Name: "TK KM", Amount: 2000, Currency: "VND"},
+			},
+		},
+		{
+			operator: "Unknown",
+			text:     "So du: 50.000d",
+			amount:   50000,
+		},
+	}
+	for _, tt := range tests {
+		b := Parse(tt.operator, tt.text)
+		if b.Amount == nil || *b.Amount != tt.amount || b.Currency != "VND" || b.ExpiresAt != tt.expires {
+			t.Errorf("%s: main balance %v %s %q", tt.operator, b.Amount, b.Currency, b.ExpiresAt)
+		}
+		if len(b.Promotions) != len(tt.promotions) {
+			t.Errorf("%s: promotions %+v", tt.operator, b.Promotions)
+		} else {
+			for i := range b.Promotions {
+				if b.Promotions[i] != tt.promotions[i] {
+					t.Errorf("%s: promotion %+v, want %+v", tt.operator, b.Promotions[i], tt.promotions[i])
+				}
+			}
+		}
+		if len(b.Data) != len(tt.data) {
+			t.Errorf("%s: data %+v", tt.operator, b.Data)
+		} else {
+			for i := range b.Data {
+				if b.Data[i] != tt.data[i] {
+					t.Errorf("%s: data %+v, want %+v", tt.operator, b.Data[i], tt.data[i])
+				}
+			}
+		}
+		if b.Text != tt.text || b.Operator != tt.operator {
+			t.Errorf("%s: text %q", tt.operator, b.Text)
+		}
+	}
+}
+
+func TestParseWithoutFigures(t *testing.T) {
+	b := Parse("Viettel", "He thong dang ban, vui long thu lai sau")
+	if b.Amount != nil || len(b.Promotions) != 0 || len(b.Data) != 0 {
+		t.Errorf("unexpected figures %+v", b)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("Gmobile", ParserFunc(func(text string, b *model.Balance) {
+		amount := 42.0
+		b.Amount = &amount
+	}))
+	defer Register("Gmobile", Generic)
+
+	if b := Parse("gmobile", "anything"); b.Amount == nil || *b.Amount != 42 {
+		t.Errorf("registered parser not used: %+v", b)
+	}
+}
+
+func TestParsePackages(t *testing.T) {
+	mb := func(v float64) *float64 { return &v }
+	n := func(v int) *int { return &v }
+	tests := []struct {
+		text string
+		want []model.Package
+	}{
+		{
+			text: "Goi ST90N: con 3.5GB, HSD 31/12/2026. Goi SMS100: con 45 SMS, HSD 20/11/2026",
+			want: []model.Package{
+				{Name: "ST90N", DataMB: mb(3584), ExpiresAt: "2026-12-31"},
+				{Name: "SMS100", SMS: n(45), ExpiresAt: "2026-11-20"},
+			},
+		},
+		{
+			text: "TKC: 12.345d. Quý khách đang dùng gói C90N: 1.024MB, 1.480 phút nội mạng, hết hạn 15-11-2026",
+			want: []model.Package{
+				{Name: "C90N", DataMB: mb(1024), Minutes: n(1480), ExpiresAt: "2026-11-15"},
+			},
+		},
+		{
+			text: "Goi cuoc: V120 con 0 tin nhan",
+			want: []model.Package{{Name: "V120", SMS: n(0)}},
+		},
+		{text: "Quy khach chua dang ky goi cuoc nao"},
+	}
+	for _, tt := range tests {
+		got := ParsePackages(tt.text)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got %+v", tt.text, got)
+			continue
+		}
+		for i, p := range got {
+			w := tt.want[i]
+			if p.Name != w.Name || p.ExpiresAt != w.ExpiresAt ||
+				!equalPtr(p.DataMB, w.DataMB) || !equalPtr(p.SMS, w.SMS) || !equalPtr(p.Minutes, w.Minutes) {
+				t.Errorf("%q: package %d = %+v, want %+v", tt.text, i, p, w)
+			}
+		}
+	}
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,50 @@
+package balance
+
+// Labels shared by the Vietnamese operators
+var (
+	promotionLabels = []string{`tai khoan khuyen mai\d*`, `tk khuyen mai\d*`, `tk ?km\d*`, `khuyen mai\d*`, `km\d*`}
+	dataLabels      = []string{`tk data`, `luu luong`, `data`, `dl`}
+)
+
+// Parsers of the operators supported out of the box. Other operators use
+// Generic until a parser is registered for them.
+var (
+	// Viettel: "TKC: 12.345d, HSD: 31/12/2026. KM1: 5.000d, HSD: 15/11/2026"
+	Viettel = Rules{
+		Main:       []string{`tai khoan chinh`, `tai khoan goc`, `tk chinh`, `tk goc`, `tkc`, `tkg`},
+		Promotions: promotionLabels,
+		Data:       dataLabels,
+	}
+	// Mobifone: "TK chinh: 12345 d, het han 31-12-2026; TK KM1: 5000 d; TK data: 2048MB"
+	Mobifone = Rules{
+		Main:       []string{`tai khoan chinh`, `tk chinh`, `tkc`},
+		Promotions: promotionLabels,
+		Data:       dataLabels,
+	}
+	// Vinaphone: "TK chinh=12345 VND, HSD:31/12/2026; TK KM=5000 VND"
+	Vinaphone = Rules{
+		Main:       []string{`tai khoan chinh`, `tk chinh`, `tkc`},
+		Promotions: promotionLabels,
+		Data:       dataLabels,
+	}
+	// Vietnamobile: "Tai khoan goc: 12.345d. Han dung: 31/12/2026. TK KM: 2.000d"
+	Vietnamobile = Rules{
+		Main:       []string{`tai khoan goc`, `tk goc`, `tkg`, `tai khoan chinh`, `tk chinh`},
+		Promotions: promotionLabels,
+		Data:       dataLabels,
+	}
+
+	// Generic accepts the labels of every known operator and a bare "so du"
+	Generic = Rules{
+		Main:       []string{`tai khoan chinh`, `tai khoan goc`, `tk chinh`, `tk goc`, `tkc`, `tkg`, `so du`, `balance`},
+		Promotions: promotionLabels,
+		Data:       dataLabels,
+	}
+)
+
+func init() {
+	Register("Viettel", Viettel)
+	Register("Mobifone", Mobifone)
+	Register("Vinaphone", Vinaphone)
+	Register("Vietnamobile", Vietnamobile)
+}
@@ -0,0 +1,78 @@
+package balance
+
+import (
+	"regexp"
+	"strings"
+
+	"sms-gateway/src/internal/model"
+)
+
+// Package answers name each subscription after "goi" or "goi cuoc" and
+// follow it with what remains of it, as in
+// "Goi ST90N: con 3.5GB, HSD 31/12/2026. Goi SMS100: con 45 SMS, HSD 20/11/2026"
+var (
+	packageRe = regexp.MustCompile(`\b(?:goi cuoc|goi)\s*:?\s*([a-z0-9_]{2,})\b`)
+	quotaRe   = regexp.MustCompile(`(\d[\d.,]*)\s*(gb|mb|kb|sms|tin nhan|tin|phut|p)\b`)
+)
+
+// packageWords are words that follow "goi" without naming a package
+var packageWords = map[string]bool{
+	"cua": true, "dang": true, "da": true, "nay": true, "data": true,
+	"khuyen": true, "cuoc": true, "thue": true, "bao": true, "hien": true,
+	"nao": true, "moi": true, "tang": true,
+}
+
+// ParsePackages extracts the subscriptions listed in the decoded answer to
+// the packages code. Each one owns the text up to the next package, from
+// which its first data, SMS and minute quota and its first date are taken.
+func ParsePackages(text string) []model.Package {
+	folded := Fold(text)
+
+	type match struct {
+		name       string
+		start, end int
+	}
+	var matches []match
+	for _, m := range packageRe.FindAllStringSubmatchIndex(folded, -1) {
+		name := folded[m[2]:m[3]]
+		if packageWords[name] {
+			continue
+		}
+		matches = append(matches, match{name: name, start: m[0], end: m[1]})
+	}
+
+	var packages []model.Package
+	for i, m := range matches {
+		next := len(folded)
+		if i+1 < len(matches) {
+			next = matches[i+1].start
+		}
+		segment := folded[m.end:next]
+
+		p := model.Package{Name: strings.ToUpper(m.name)}
+		for _, q := range quotaRe.FindAllStringSubmatch(segment, -1) {
+			switch unit := q[2]; unit {
+			case "gb", "mb", "kb":
+				if p.DataMB == nil {
+					mb := toMB(parseAmount(q[1], false), unit)
+					p.DataMB = &mb
+				}
+			case "sms", "tin nhan", "tin":
+				if p.SMS == nil {
+					n := int(parseAmount(q[1], true))
+					p.SMS = &n
+				}
+			default:
+				if p.Minutes == nil {
+					n := int(parseAmount(q[1], true))
+					p.Minutes = &n
+				}
+			}
+		}
+		if d := dateRe.FindStringSubmatch(segment); d != nil {
+			p.ExpiresAt = isoDate(d[1], d[2], d[3])
+		}
+		packages = append(packages, p)
+	}
+	return packages
+}
@@ -2,6 +2,7 @@ package modem
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -9,67 +10,64 @@ import (
 
 	"sms-gateway/src/internal/config"
 	"sms-gateway/src/internal/model"
-
-	serial "go.bug.st/serial"
+	"sms-gateway/src/internal/store"
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/balance"
+	"sms-gateway/src/pkg/port"
+	"sms-gateway/src/pkg/session"
+	"sms-gateway/src/pkg/simulator"
+	"sms-gateway/src/pkg/ussd"
 )
 
 // Client handles modem operations
 type Client struct {
-	config *config.Config
+	config   *config.Config
+	sessions *session.Manager
+	profiles map[string]Profile
+	numbers  store.NumberStore
 }
 
-// NewClient creates a new modem client
-func NewClient(cfg *config.Config) *Client {
+// NewClient creates a new modem client on top of the shared modem sessions.
+// Own numbers of the SIMs are kept in numbers. Invalid profile overrides are
+// logged and ignored.
+func NewClient(cfg *config.Config, sessions *session.Manager, numbers store.NumberStore) *Client {
+	profiles, err := ParseProfiles(DefaultProfiles, cfg.Modem.USSDProfiles)
+	if err != nil {
+		log.Printf("Modem Client: Ignoring MODEM_USSD_PROFILES: %v", err)
+		profiles = DefaultProfiles
+	}
 	return &Client{
-		config: cfg,
+		config:   cfg,
+		sessions: sessions,
+		profiles: profiles,
+		numbers:  numbers,
 	}
 }
 
-// CheckPortStatus checks if a serial port is available
-func (c *Client) CheckPortStatus(portName string) (*model.PortStatus, error) {
+// CheckPortStatus checks if a serial port is available. The exchange with
+// the modem stops when ctx is done.
+func (c *Client) CheckPortStatus(ctx context.Context, portName string) (*model.PortStatus, error) {
 	status := &model.PortStatus{
 		Port: portName,
 	}
 
 	// Try to open the port
-	mode := &serial.Mode{
-		BaudRate: c.config.Modem.DefaultBaudRate,
-		Parity:   serial.NoParity,
-		DataBits: 8,
-		StopBits: serial.OneStopBit,
-	}
-
-	port, err := serial.Open(portName, mode)
+	device := c.sessions.Device(portName, 0)
+	sess, err := device.Session(ctx)
 	if err != nil {
 		status.Available = false
 		status.Error = err.Error()
 		return status, nil
 	}
-	defer port.Close()
 
 	status.Available = true
 
-	// Try to fetch SIM balance via USSD if configured
-	if ussd := strings.TrimSpace(c.config.Modem.BalanceUSSD); ussd != "" {
-		// Keep it short to avoid blocking for too long
-		ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
-		defer cancel()
-		// Enable USSD and query; ignore errors but capture response
-		_ = func() error {
-			if _, err := c.sendATCommand(ctx, port, "AT+CUSD=1"); err != nil {
-				return err
-			}
-			cmd := "AT+CUSD=1,\"" + ussd + "\",15"
-			if _, err := c.sendATCommand(ctx, port, cmd); err != nil {
-				return err
-			}
-			resp, err := c.readResponse(ctx, port, 8*time.Second)
-			if err == nil && strings.TrimSpace(resp) != "" {
-				status.Balance = strings.TrimSpace(resp)
-			}
-			return nil
-		}()
-	}
+	// Try to fetch SIM balance with the USSD code of its network. Keep it
+	// short to avoid blocking for too long; the balance is best effort.
+	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
+	defer cancel()
+	profile := c.detectProfile(ctx, sess, c.queryIMSI(ctx, sess))
+	status.Balance = c.queryBalance(ctx, device, sess, profile)
 	return status, nil
 }
 
@@ -80,39 +78,31 @@ func (c *Client) GetInfo(ctx context.Context, portName string, baudRate int) (*m
 		BaudRate: baudRate,
 	}
 
-	mode := &serial.Mode{
-		BaudRate: baudRate,
-		Parity:   serial.NoParity,
-		DataBits: 8,
-		StopBits: serial.OneStopBit,
-	}
-
-	port, err := serial.Open(portName, mode)
+	sess, err := c.sessions.Device(portName, baudRate).Session(ctx)
 	if err != nil {
-		return info, fmt.Errorf("failed to open port: %w", err)
+		return info, err
 	}
-	defer port.Close()
 
 	info.Connected = true
 
 	// Get manufacturer
-	if manufacturer, err := c.sendATCommand(ctx, port, "AT+CGMI"); err == nil {
-		info.Manufacturer = strings.TrimSpace(manufacturer)
+	if resp, err := sess.Command(ctx, "AT+CGMI"); err == nil {
+		info.Manufacturer = resp.Value()
 	}
 
 	// Get model
-	if model, err := c.sendATCommand(ctx, port, "AT+CGMM"); err == nil {
-		info.Model = strings.TrimSpace(model)
+	if resp, err := sess.Command(ctx, "AT+CGMM"); err == nil {
+		info.Model = resp.Value()
 	}
 
 	// Get version
-	if version, err := c.sendATCommand(ctx, port, "AT+CGMR"); err == nil {
-		info.Version = strings.TrimSpace(version)
+	if resp, err := sess.Command(ctx, "AT+CGMR"); err == nil {
+		info.Version = resp.Value()
 	}
 
 	// Get IMEI
-	if imei, err := c.sendATCommand(ctx, port, "AT+CGSN"); err == nil {
-		info.IMEI = strings.TrimSpace(imei)
+	if resp, err := sess.Command(ctx, "AT+CGSN"); err == nil {
+		info.IMEI = resp.Value()
 	}
 
 	return info, nil
@@ -120,66 +110,80 @@ func (c *Client) GetInfo(ctx context.Context, portName string, baudRate int) (*m
 
 // ListPorts lists available serial ports
 func (c *Client) ListPorts() ([]string, error) {
-	return serial.GetPortsList()
+	return port.List()
 }
 
-// ListPortsWithInfo lists available serial ports with device information
-func (c *Client) ListPortsWithInfo() ([]model.PortInfo, error) {
-	ports, err := serial.GetPortsList()
+// ListPortsWithInfo lists available serial ports with device information.
+// The scan stops when ctx is done.
+func (c *Client) ListPortsWithInfo(ctx context.Context) ([]model.PortInfo, error) {
+	ports, err := port.List()
 	if err != nil {
 		return nil, err
 	}
 
 	var portInfos []model.PortInfo
-	
+
 	// Set overall timeout for the entire operation
-	overallCtx, overallCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	overallCtx, overallCancel := context.WithTimeout(ctx, 30*time.Second)
 	defer overallCancel()
-	
+
 	for _, port := range ports {
 		// Check if overall timeout has been reached
 		select {
 		case <-overallCtx.Done():
-			log.Printf("Overall timeout reached while scanning ports")
+			log.Printf("Port scan stopped: %v", overallCtx.Err())
 			goto done
 		default:
 		}
-		
+
 		info := model.PortInfo{Port: port}
 		info.DeviceName = c.getDeviceName(port)
 
-		// Try to open port with very short timeout
-		mode := &serial.Mode{
-			BaudRate: c.config.Modem.DefaultBaudRate,
-			Parity:   serial.NoParity,
-			DataBits: 8,
-			StopBits: serial.OneStopBit,
-		}
-		
 		// Use a very short timeout for port operations
 		portCtx, portCancel := context.WithTimeout(overallCtx, 3*time.Second)
-		
-		p, err := serial.Open(port, mode)
-		if err == nil {
+
+		device, sess, release, err := c.probe(portCtx, port)
+		var busy *session.BusyError
+		if errors.As(err, &busy) {
+			// Do not disturb a modem that is sending or being queried
 			info.Available = true
-			
+			info.InUse = true
+			info.InUseBy = busy.Lease.Owner
+			info.InUseSince = busy.Lease.Since.Format(time.RFC3339)
+			info.Description = "Modem in use"
+		} else if err == nil {
+			info.Available = true
+
 			// Only get basic info quickly
-			if desc := c.getBasicDeviceInfo(portCtx, p); desc != "" {
+			if desc := c.getBasicDeviceInfo(portCtx, sess); desc != "" {
 				info.Description = desc
+
+				// The balance needs a USSD round trip, so it gets its own
+				// timeout within the overall one
+				imsi, iccid := c.queryIMSI(portCtx, sess), c.queryICCID(portCtx, sess)
+				profile := c.detectProfile(portCtx, sess, imsi)
+				info.Operator = profile.Name
+				ussdCtx, ussdCancel := context.WithTimeout(overallCtx, 8*time.Second)
+				info.Balance = c.queryBalance(ussdCtx, device, sess, profile)
+				ussdCancel()
+				ussdCtx, ussdCancel = context.WithTimeout(overallCtx, 8*time.Second)
+				info.Packages = c.queryPackages(ussdCtx, device, sess, profile, info.Balance)
+				ussdCancel()
+				ussdCtx, ussdCancel = context.WithTimeout(overallCtx, 8*time.Second)
+				info.Msisdn, _ = c.resolveMsisdn(ussdCtx, device, sess, profile, iccid, imsi)
+				ussdCancel()
 			} else {
+				// Not a modem, or one that does not answer
 				info.Description = "USB Serial Device"
 			}
-			
-			// Skip MSISDN, balance, and packages for faster response
-			// These can be obtained via the detailed device info endpoint if needed
-			
-			p.Close()
+
+			release()
 		} else {
 			info.Available = false
 			info.Error = err.Error()
 			info.Description = "Port unavailable"
 		}
-		
+
 		portCancel()
 		portInfos = append(portInfos, info)
 	}
@@ -188,6 +192,30 @@ done:
 	return portInfos, nil
 }
 
+// probe returns a session for a listed port. Managed modems are reached
+// through their persistent session and device; other ports are opened only
+// for the duration of the probe so that non-modem serial devices are not
+// held, and have no device.
+func (c *Client) probe(ctx context.Context, portName string) (*session.Device, *at.Session, func(), error) {
+	if device, ok := c.sessions.Lookup(portName); ok {
+		release, err := device.TryAcquire("port scan")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		sess, err := device.Session(ctx)
+		if err != nil {
+			release()
+			return nil, nil, nil, err
+		}
+		return device, sess, release, nil
+	}
+	sess, err := at.Open(portName, c.config.Modem.DefaultBaudRate)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return nil, sess, func() { sess.Close() }, nil
+}
+
 // getDeviceName extracts device name from port path
 func (c *Client) getDeviceName(port string) string {
 	// For Linux: /dev/ttyUSB0 -> USB0
@@ -200,6 +228,11 @@ func (c *Client) getDeviceName(port string) string {
 		return port
 	}
 
+	// For simulated modems: sim://modem1 -> modem1
+	if simulator.IsSimulated(port) {
+		return strings.TrimPrefix(port, simulator.Scheme)
+	}
+
 	// For macOS: /dev/tty.usbserial-* -> usbserial-*
 	if strings.Contains(port, "usbserial") {
 		parts := strings.Split(port, "/")
@@ -211,158 +244,109 @@ func (c *Client) getDeviceName(port string) string {
 	return port
 }
 
-// getDeviceDescription gets device description based on port
-func (c *Client) getDeviceDescription(port string) string {
-	// Try to get modem info to determine device type
-	mode := &serial.Mode{
-		BaudRate: c.config.Modem.DefaultBaudRate,
-		Parity:   serial.NoParity,
-		DataBits: 8,
-		StopBits: serial.OneStopBit,
-	}
-
-	portHandle, err := serial.Open(port, mode)
+// getBasicDeviceInfo gets basic device info quickly with short timeout
+func (c *Client) getBasicDeviceInfo(ctx context.Context, sess *at.Session) string {
+	// Use very short timeout for basic info
+	resp, err := sess.CommandTimeout(ctx, "AT+CGMI", 1*time.Second)
 	if err != nil {
-		return "Unknown device"
+		return ""
 	}
-	defer portHandle.Close()
-
-	// Try to get manufacturer info
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-
-	if manufacturer, err := c.sendATCommand(ctx, portHandle, "AT+CGMI"); err == nil {
-		manufacturer = strings.TrimSpace(manufacturer)
-		if manufacturer != "" && !strings.Contains(manufacturer, "ERROR") {
-			return manufacturer + " Modem"
-		}
+	if m := resp.Value(); m != "" {
+		return m + " Modem"
 	}
+	return ""
+}
 
-	return "USB Serial Device"
+// queryIMSI reads the IMSI of the SIM, "" when there is none
+func (c *Client) queryIMSI(ctx context.Context, sess *at.Session) string {
+	if resp, err := sess.Command(ctx, "AT+CIMI"); err == nil {
+		return resp.Value()
+	}
+	return ""
 }
 
-// sendATCommand sends AT command and returns response
-func (c *Client) sendATCommand(ctx context.Context, port serial.Port, command string) (string, error) {
-	// Send command
-	_, err := port.Write([]byte(command + "\r\n"))
+// queryMsisdn reads the own number reported by AT+CNUM
+func (c *Client) queryMsisdn(ctx context.Context, sess *at.Session) (string, error) {
+	resp, err := sess.CommandTimeout(ctx, "AT+CNUM", 3*time.Second)
 	if err != nil {
 		return "", err
 	}
 
-	// Read response with timeout
-	timeout := time.Duration(5) * time.Second
-	return c.readResponse(ctx, port, timeout)
-}
-
-// readResponse reads response from modem
-func (c *Client) readResponse(ctx context.Context, port serial.Port, timeout time.Duration) (string, error) {
-	deadline := time.Now().Add(timeout)
-	var response strings.Builder
-
-	buffer := make([]byte, 1024)
-	for {
-		select {
-		case <-ctx.Done():
-			return response.String(), ctx.Err()
-		default:
-		}
-
-		if time.Now().After(deadline) {
-			return response.String(), fmt.Errorf("timeout")
-		}
-
-		port.SetReadTimeout(100 * time.Millisecond)
-		n, err := port.Read(buffer)
-		if err != nil {
-			continue
-		}
-
-		if n > 0 {
-			response.Write(buffer[:n])
-			resp := response.String()
-			if strings.Contains(resp, "OK") || strings.Contains(resp, "ERROR") {
-				return resp, nil
+	// Typical response: +CNUM: ,"+84123456789",145
+	for _, line := range resp.Prefixed("+CNUM:") {
+		// Find all quoted strings in the line
+		quotes := strings.Split(line, "\"")
+		for i, part := range quotes {
+			// Phone numbers typically start with + or digits
+			if i%2 == 1 && (strings.HasPrefix(part, "+") || strings.HasPrefix(part, "0") || strings.HasPrefix(part, "8")) {
+				// Validate it looks like a phone number
+				if len(part) >= 10 && len(part) <= 15 {
+					return strings.TrimSpace(part), nil
+				}
 			}
 		}
 	}
+	return "", nil
 }
 
-func (c *Client) getDeviceDescriptionWithPort(ctx context.Context, port serial.Port) string {
-	if manufacturer, err := c.sendATCommand(ctx, port, "AT+CGMI"); err == nil {
-		m := strings.TrimSpace(manufacturer)
-		if m != "" && !strings.Contains(m, "ERROR") {
-			return m + " Modem"
-		}
+// runUSSD sends a single USSD code and returns the answer. device is nil
+// for ports opened only for a probe. A menu left
+// open by the answer is cancelled so it does not hold the network session.
+func (c *Client) runUSSD(ctx context.Context, device *session.Device, sess *at.Session, code string) (*ussd.Response, error) {
+	// Ports probed outside the session manager keep their character set
+	charset := ""
+	if device != nil {
+		charset = ussd.PrepareCharset(ctx, device, c.config.Modem.USSDCharset)
 	}
-	return "USB Serial Device"
-}
-
-// getBasicDeviceInfo gets basic device info quickly with short timeout
-func (c *Client) getBasicDeviceInfo(ctx context.Context, port serial.Port) string {
-	// Use very short timeout for basic info
-	quickCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
-	defer cancel()
-	
-	if manufacturer, err := c.sendATCommand(quickCtx, port, "AT+CGMI"); err == nil {
-		m := c.cleanATResponse(manufacturer)
-		if m != "" && !strings.Contains(m, "ERROR") {
-			return m + " Modem"
+	resp, err := ussd.Send(ctx, sess, code, charset, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Open() {
+		if err := ussd.Cancel(ctx, sess); err != nil {
+			log.Printf("Modem Client: Failed to cancel USSD menu of %s: %v", code, err)
 		}
 	}
-	return ""
+	return resp, nil
 }
 
-func (c *Client) queryMsisdn(port serial.Port) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	resp, err := c.sendATCommand(ctx, port, "AT+CNUM")
-	if err != nil {
-		return "", err
+// queryBalance runs the balance code of a profile, nil when the profile
+// has none or the network does not answer
+func (c *Client) queryBalance(ctx context.Context, device *session.Device, sess *at.Session, profile Profile) *model.Balance {
+	if profile.BalanceUSSD == "" {
+		return nil
 	}
-	
-	// Clean the response
-	resp = c.cleanATResponse(resp)
-	
-	// Typical response: +CNUM: ,"+84123456789",145
-	for _, line := range strings.Split(resp, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "+CNUM") || strings.Contains(line, "+CNUM:") {
-			// Find all quoted strings in the line
-			quotes := strings.Split(line, "\"")
-			for i, part := range quotes {
-				// Phone numbers typically start with + or digits
-				if i%2 == 1 && (strings.HasPrefix(part, "+") || strings.HasPrefix(part, "0") || strings.HasPrefix(part, "8")) {
-					// Validate it looks like a phone number
-					if len(part) >= 10 && len(part) <= 15 {
-						return strings.TrimSpace(part), nil
-					}
-				}
-			}
-		}
+	resp, err := c.runUSSD(ctx, device, sess, profile.BalanceUSSD)
+	if err != nil || resp.Text == "" {
+		return nil
 	}
-	return "", nil
+	return c.parseBalance(profile.Name, resp)
 }
 
-func (c *Client) runUSSD(port serial.Port, code string) (string, error) {
-	// Enable USSD
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	if _, err := c.sendATCommand(ctx, port, "AT+CUSD=1"); err != nil {
-		return "", err
+// queryPackages runs the packages code of a profile and lists the active
+// subscriptions. Networks that answer balance and packages with the same
+// code are not asked twice; b is the balance already fetched, if any.
+func (c *Client) queryPackages(ctx context.Context, device *session.Device, sess *at.Session, profile Profile, b *model.Balance) []model.Package {
+	if profile.PackagesUSSD == "" {
+		return nil
 	}
-	// Send USSD
-	cmd := "AT+CUSD=1,\"" + code + "\",15"
-	if _, err := c.sendATCommand(ctx, port, cmd); err != nil {
-		return "", err
+	if b != nil && profile.PackagesUSSD == profile.BalanceUSSD {
+		return balance.ParsePackages(b.Text)
 	}
-	// Read response (may take a few seconds)
-	ctx2, cancel2 := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel2()
-	resp, err := c.readResponse(ctx2, port, 10*time.Second)
-	if err != nil {
-		return "", err
+	resp, err := c.runUSSD(ctx, device, sess, profile.PackagesUSSD)
+	if err != nil || resp.Text == "" {
+		return nil
 	}
-	return strings.TrimSpace(resp), nil
+	return balance.ParsePackages(resp.Text)
+}
+
+// parseBalance extracts the figures of a balance answer with the parser of
+// the operator, keeping the raw answer alongside
+func (c *Client) parseBalance(operator string, resp *ussd.Response) *model.Balance {
+	b := balance.Parse(operator, strings.TrimSpace(resp.Text))
+	b.Raw = resp.Raw
+	b.Encoding = resp.Encoding()
+	return b
 }
 
 // GetDeviceInfo gets comprehensive device information including SIM details
@@ -373,92 +357,78 @@ func (c *Client) GetDeviceInfo(ctx context.Context, portName string, baudRate in
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
-	mode := &serial.Mode{
-		BaudRate: baudRate,
-		Parity:   serial.NoParity,
-		DataBits: 8,
-		StopBits: serial.OneStopBit,
-	}
-
-	port, err := serial.Open(portName, mode)
+	device := c.sessions.Device(portName, baudRate)
+	sess, err := device.Session(ctx)
 	if err != nil {
-		info.Error = fmt.Sprintf("Failed to open port: %v", err)
+		info.Error = err.Error()
 		return info, nil
 	}
-	defer port.Close()
 
 	info.Connected = true
 
-	// Get phone number (MSISDN)
-	if phoneNumber, err := c.queryMsisdn(port); err == nil && phoneNumber != "" {
-		info.PhoneNumber = phoneNumber
-	}
-
 	// Get manufacturer
-	if manufacturer, err := c.sendATCommand(ctx, port, "AT+CGMI"); err == nil {
-		info.Manufacturer = c.cleanATResponse(manufacturer)
+	if resp, err := sess.Command(ctx, "AT+CGMI"); err == nil {
+		info.Manufacturer = resp.Value()
 	}
 
 	// Get model
-	if model, err := c.sendATCommand(ctx, port, "AT+CGMM"); err == nil {
-		info.Model = c.cleanATResponse(model)
+	if resp, err := sess.Command(ctx, "AT+CGMM"); err == nil {
+		info.Model = resp.Value()
 	}
 
 	// Get version
-	if version, err := c.sendATCommand(ctx, port, "AT+CGMR"); err == nil {
-		info.Version = c.cleanATResponse(version)
+	if resp, err := sess.Command(ctx, "AT+CGMR"); err == nil {
+		info.Version = resp.Value()
 	}
 
 	// Get IMEI
-	if imei, err := c.sendATCommand(ctx, port, "AT+CGSN"); err == nil {
-		info.IMEI = c.cleanATResponse(imei)
+	if resp, err := sess.Command(ctx, "AT+CGSN"); err == nil {
+		info.IMEI = resp.Value()
 	}
 
-	// Get IMSI
-	if imsi, err := c.sendATCommand(ctx, port, "AT+CIMI"); err == nil {
-		info.IMSI = c.cleanATResponse(imsi)
-	}
+	// Get IMSI and ICCID
+	info.IMSI = c.queryIMSI(ctx, sess)
+	info.ICCID = c.queryICCID(ctx, sess)
 
 	// Get operator information
-	if operator, err := c.sendATCommand(ctx, port, "AT+COPS?"); err == nil {
-		info.Operator = c.parseOperator(operator)
+	if resp, err := sess.Command(ctx, "AT+COPS?"); err == nil {
+		info.Operator = c.parseOperator(resp)
 	}
 
 	// Get network registration status and technology
-	if networkInfo, err := c.sendATCommand(ctx, port, "AT+CREG?"); err == nil {
-		info.NetworkType = c.parseNetworkType(networkInfo)
+	if resp, err := sess.Command(ctx, "AT+CREG?"); err == nil {
+		info.NetworkType = c.parseNetworkType(resp)
 	}
 
 	// Get signal strength
-	if signal, err := c.sendATCommand(ctx, port, "AT+CSQ"); err == nil {
-		info.SignalLevel = c.parseSignalStrength(signal)
+	if resp, err := sess.Command(ctx, "AT+CSQ"); err == nil {
+		info.SignalLevel = c.parseSignalStrength(resp)
 	}
 
-	// Get balance via USSD if configured
-	if ussd := strings.TrimSpace(c.config.Modem.BalanceUSSD); ussd != "" {
-		if balance, err := c.runUSSD(port, ussd); err == nil && balance != "" {
-			info.Balance = c.parseUSSDResponse(balance)
-		}
+	// Get balance and packages with the USSD codes of the SIM's network
+	profile := c.profileFor(info.IMSI, info.Operator)
+	if info.Operator == "" {
+		info.Operator = profile.Name
 	}
+	info.Balance = c.queryBalance(ctx, device, sess, profile)
+	info.Packages = c.queryPackages(ctx, device, sess, profile, info.Balance)
+
+	// Get phone number (MSISDN), which most SIMs do not report with AT+CNUM
+	info.PhoneNumber, info.NumberSource = c.resolveMsisdn(ctx, device, sess, profile, info.ICCID, info.IMSI)
 
 	return info, nil
 }
 
 // parseOperator extracts operator name from AT+COPS response
-func (c *Client) parseOperator(response string) string {
+func (c *Client) parseOperator(resp *at.Response) string {
 	// Example: +COPS: 0,0,"Viettel",2
-	response = c.cleanATResponse(response)
-	
-	for _, line := range strings.Split(response, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "+COPS:") {
-			parts := strings.Split(line, ",")
-			if len(parts) >= 3 {
-				// Remove quotes from operator name
-				operator := strings.Trim(parts[2], "\"")
-				if operator != "" {
-					return c.mapOperatorName(operator)
-				}
+	for _, line := range resp.Prefixed("+COPS:") {
+		parts := strings.Split(line, ",")
+		if len(parts) >= 3 {
+			// Remove quotes from operator name
+			operator := strings.Trim(parts[2], "\"")
+			if operator != "" {
+				return c.mapOperatorName(operator)
 			}
 		}
 	}
@@ -468,77 +438,56 @@ func (c *Client) parseOperator(response string) string {
 // mapOperatorName maps operator codes to friendly names
 func (c *Client) mapOperatorName(operator string) string {
 	operatorMap := map[string]string{
-		"45201": "Mobifone",
-		"45202": "Vinaphone", 
-		"45204": "Viettel",
-		"45205": "Vietnamobile",
-		"45207": "Gmobile",
-		"45208": "Itelecom",
-		"Viettel": "Viettel",
-		"Mobifone": "Mobifone",
-		"Vinaphone": "Vinaphone",
+		"45201":        "Mobifone",
+		"45202":        "Vinaphone",
+		"45204":        "Viettel",
+		"45205":        "Vietnamobile",
+		"45207":        "Gmobile",
+		"45208":        "Itelecom",
+		"Viettel":      "Viettel",
+		"Mobifone":     "Mobifone",
+		"Vinaphone":    "Vinaphone",
 		"Vietnamobile": "Vietnamobile",
 	}
-	
+
 	if friendlyName, exists := operatorMap[operator]; exists {
 		return friendlyName
 	}
-	
+
 	// If not found in map, return original but clean
 	return operator
 }
 
-// cleanATResponse removes AT command artifacts from response
-func (c *Client) cleanATResponse(response string) string {
-	// Remove carriage returns and extra whitespace
-	response = strings.ReplaceAll(response, "\r", "")
-	response = strings.ReplaceAll(response, "\n\n", "\n")
-	
-	// Remove OK and ERROR from end
-	response = strings.TrimSuffix(response, "\n\nOK")
-	response = strings.TrimSuffix(response, "\nOK")
-	response = strings.TrimSuffix(response, "OK")
-	response = strings.TrimSuffix(response, "\n\nERROR")
-	response = strings.TrimSuffix(response, "\nERROR")
-	response = strings.TrimSuffix(response, "ERROR")
-	
-	return strings.TrimSpace(response)
-}
-
 // parseNetworkType extracts network type from AT+CREG response
-func (c *Client) parseNetworkType(response string) string {
+func (c *Client) parseNetworkType(resp *at.Response) string {
 	// Example: +CREG: 0,1 or +CREG: 2,1,1A2B,C3D4,7
-	response = c.cleanATResponse(response)
-	for _, line := range strings.Split(response, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "+CREG:") {
-			parts := strings.Split(line, ",")
-			if len(parts) >= 5 {
-				// The last parameter indicates access technology
-				switch strings.TrimSpace(parts[4]) {
-				case "0":
-					return "GSM"
-				case "2":
-					return "UTRAN"
-				case "3":
-					return "GSM w/EGPRS"
-				case "4":
-					return "UTRAN w/HSDPA"
-				case "5":
-					return "UTRAN w/HSUPA"
-				case "6":
-					return "UTRAN w/HSDPA and HSUPA"
-				case "7":
-					return "E-UTRAN (LTE)"
-				default:
-					return "Unknown"
-				}
-			} else if len(parts) >= 2 {
-				// Basic registration status
-				status := strings.TrimSpace(parts[1])
-				if status == "1" || status == "5" {
-					return "GSM/GPRS"
-				}
+	for _, line := range resp.Prefixed("+CREG:") {
+		parts := strings.Split(line, ",")
+		if len(parts) >= 5 {
+			// The last parameter indicates access technology
+			switch strings.TrimSpace(parts[4]) {
+			case "0":
+				return "GSM"
+			case "2":
+				return "UTRAN"
+			case "3":
+				return "GSM w/EGPRS"
+			case "4":
+				return "UTRAN w/HSDPA"
+			case "5":
+				return "UTRAN w/HSUPA"
+			case "6":
+				return "UTRAN w/HSDPA and HSUPA"
+			case "7":
+				return "E-UTRAN (LTE)"
+			default:
+				return "Unknown"
+			}
+		} else if len(parts) >= 2 {
+			// Basic registration status
+			status := strings.TrimSpace(parts[1])
+			if status == "1" || status == "5" {
+				return "GSM/GPRS"
 			}
 		}
 	}
@@ -546,53 +495,19 @@ func (c *Client) parseNetworkType(response string) string {
 }
 
 // parseSignalStrength extracts signal strength from AT+CSQ response
-func (c *Client) parseSignalStrength(response string) int {
+func (c *Client) parseSignalStrength(resp *at.Response) int {
 	// Example: +CSQ: 31,99
-	response = c.cleanATResponse(response)
-	for _, line := range strings.Split(response, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "+CSQ:") {
-			parts := strings.Split(line, ",")
-			if len(parts) >= 1 {
-				rssiPart := strings.TrimSpace(strings.TrimPrefix(parts[0], "+CSQ:"))
-				if rssi := strings.TrimSpace(rssiPart); rssi != "99" && rssi != "" {
-					// Convert RSSI to dBm: -113 + (rssi * 2)
-					var signal int
-					if val, err := fmt.Sscanf(rssi, "%d", &signal); err == nil && val == 1 {
-						if signal >= 0 && signal <= 31 {
-							return -113 + (signal * 2)
-						}
-					}
+	for _, line := range resp.Prefixed("+CSQ:") {
+		parts := strings.Split(line, ",")
+		if rssi := strings.TrimSpace(parts[0]); rssi != "99" && rssi != "" {
+			// Convert RSSI to dBm: -113 + (rssi * 2)
+			var signal int
+			if val, err := fmt.Sscanf(rssi, "%d", &signal); err == nil && val == 1 {
+				if signal >= 0 && signal <= 31 {
+					return -113 + (signal * 2)
 				}
 			}
 		}
 	}
 	return 0
 }
-
-// parseUSSDResponse cleans up USSD response
-func (c *Client) parseUSSDResponse(response string) string {
-	// Remove AT command echoes and extract the actual response
-	response = strings.ReplaceAll(response, "\r", "")
-	lines := strings.Split(response, "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		// Look for USSD response pattern
-		if strings.Contains(line, "+CUSD:") {
-			// Extract the message between quotes
-			start := strings.Index(line, "\"")
-			end := strings.LastIndex(line, "\"")
-			if start >= 0 && end > start {
-				return strings.TrimSpace(line[start+1 : end])
-			}
-		}
-		// Sometimes the response is just plain text
-		if !strings.Contains(line, "AT+") && !strings.Contains(line, "OK") &&
-			!strings.Contains(line, "ERROR") && line != "" {
-			return line
-		}
-	}
-
-	return response
-}
@@ -0,0 +1,189 @@
+package modem
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/session"
+)
+
+// ErrNoSIMIdentity is returned when a SIM reports neither ICCID nor IMSI,
+// so no number can be recorded for it
+var ErrNoSIMIdentity = errors.New("SIM reports neither ICCID nor IMSI")
+
+// phoneNumberRe finds a Vietnamese number in an own-number answer such as
+// "So thue bao cua quy khach la 84912345678"
+var phoneNumberRe = regexp.MustCompile(`(?:\+?84|0)\d{9,10}\b`)
+
+// resolveMsisdn finds the own number of the SIM. A number assigned by hand
+// wins; otherwise AT+CNUM, a number discovered earlier, the own-number USSD
+// code of the profile and the "ON" phonebook are tried in turn. Discovered
+// numbers are saved under the SIM's ICCID and IMSI.
+func (c *Client) resolveMsisdn(ctx context.Context, device *session.Device, sess *at.Session, profile Profile, iccid, imsi string) (string, string) {
+	known, err := c.numbers.Get(iccid, imsi)
+	if err != nil {
+		known = nil
+	}
+	if known != nil && known.Source == model.NumberSourceManual {
+		return known.PhoneNumber, known.Source
+	}
+
+	if number, err := c.queryMsisdn(ctx, sess); err == nil && number != "" {
+		c.saveMsisdn(iccid, imsi, number, model.NumberSourceCNUM, known)
+		return number, model.NumberSourceCNUM
+	}
+	if known != nil {
+		return known.PhoneNumber, known.Source
+	}
+
+	// Without an identity the number could not be kept, so the USSD round
+	// trip would be repeated on every query
+	if iccid == "" && imsi == "" {
+		return "", ""
+	}
+	if number := c.msisdnFromUSSD(ctx, device, sess, profile); number != "" {
+		c.saveMsisdn(iccid, imsi, number, model.NumberSourceUSSD, nil)
+		return number, model.NumberSourceUSSD
+	}
+	if number := c.msisdnFromPhonebook(ctx, sess); number != "" {
+		c.saveMsisdn(iccid, imsi, number, model.NumberSourcePhonebook, nil)
+		return number, model.NumberSourcePhonebook
+	}
+	return "", ""
+}
+
+// saveMsisdn records a discovered number unless it is already known
+func (c *Client) saveMsisdn(iccid, imsi, number, source string, known *model.SIMNumber) {
+	if iccid == "" && imsi == "" {
+		return
+	}
+	if known != nil && known.PhoneNumber == number && known.Source == source {
+		return
+	}
+	n := &model.SIMNumber{ICCID: iccid, IMSI: imsi, PhoneNumber: number, Source: source, UpdatedAt: time.Now()}
+	if err := c.numbers.Save(n); err != nil {
+		log.Printf("Modem Client: Failed to save number of SIM %s: %v", iccid+imsi, err)
+	}
+}
+
+// msisdnFromUSSD runs the own-number code of the profile and picks the
+// number out of the answer
+func (c *Client) msisdnFromUSSD(ctx context.Context, device *session.Device, sess *at.Session, profile Profile) string {
+	if profile.MSISDNUSSD == "" {
+		return ""
+	}
+	resp, err := c.runUSSD(ctx, device, sess, profile.MSISDNUSSD)
+	if err != nil {
+		return ""
+	}
+	return phoneNumberRe.FindString(resp.Text)
+}
+
+// msisdnFromPhonebook reads the own-number ("ON") phonebook of the SIM and
+// restores the phonebook selected before
+func (c *Client) msisdnFromPhonebook(ctx context.Context, sess *at.Session) string {
+	previous := ""
+	if resp, err := sess.Command(ctx, "AT+CPBS?"); err == nil {
+		// Example: +CPBS: "SM",3,250
+		for _, line := range resp.Prefixed("+CPBS:") {
+			previous = strings.Trim(strings.Split(line, ",")[0], "\"")
+		}
+	}
+	if _, err := sess.Command(ctx, `AT+CPBS="ON"`); err != nil {
+		return ""
+	}
+	if previous != "" && previous != "ON" {
+		// Restore the phonebook even when the caller has given up
+		defer sess.Command(context.WithoutCancel(ctx), `AT+CPBS="`+previous+`"`)
+	}
+
+	// Example: +CPBR: (1-4),40,16
+	first, last := 1, 1
+	if resp, err := sess.Command(ctx, "AT+CPBR=?"); err == nil {
+		for _, line := range resp.Prefixed("+CPBR:") {
+			bounds := strings.Trim(strings.Split(line, ",")[0], "()")
+			lo, hi, _ := strings.Cut(bounds, "-")
+			if n, err := strconv.Atoi(lo); err == nil {
+				first, last = n, n
+			}
+			if n, err := strconv.Atoi(hi); err == nil {
+				last = n
+			}
+		}
+	}
+
+	// Example: +CPBR: 1,"+84912345678",145,"My number"
+	resp, err := sess.Command(ctx, "AT+CPBR="+strconv.Itoa(first)+","+strconv.Itoa(last))
+	if err != nil {
+		return ""
+	}
+	for _, line := range resp.Prefixed("+CPBR:") {
+		fields := strings.Split(line, ",")
+		if len(fields) < 2 {
+			continue
+		}
+		if number := strings.Trim(fields[1], "\" "); len(number) >= 9 {
+			return number
+		}
+	}
+	return ""
+}
+
+// queryICCID reads the serial number of the SIM card, which modems expose
+// as AT+CCID or AT+ICCID
+func (c *Client) queryICCID(ctx context.Context, sess *at.Session) string {
+	for _, cmd := range []string{"AT+CCID", "AT+ICCID"} {
+		resp, err := sess.Command(ctx, cmd)
+		if err != nil {
+			continue
+		}
+		// Example: +CCID: 8984040000000000001, or the bare number
+		value := resp.Value()
+		if _, rest, ok := strings.Cut(value, ":"); ok {
+			value = rest
+		}
+		if value = strings.Trim(value, "\" "); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// AssignMsisdn records a number by hand for the SIM in a port, or for the
+// SIM with the ICCID or IMSI of the request. Such numbers take precedence
+// over discovered ones.
+func (c *Client) AssignMsisdn(ctx context.Context, req *model.AssignNumberRequest) (*model.SIMNumber, error) {
+	n := &model.SIMNumber{
+		ICCID:       req.ICCID,
+		IMSI:        req.IMSI,
+		PhoneNumber: req.PhoneNumber,
+		Source:      model.NumberSourceManual,
+		UpdatedAt:   time.Now(),
+	}
+	if n.ICCID == "" && n.IMSI == "" {
+		sess, err := c.sessions.Device(req.Port, req.BaudRate).Session(ctx)
+		if err != nil {
+			return nil, err
+		}
+		n.ICCID, n.IMSI = c.queryICCID(ctx, sess), c.queryIMSI(ctx, sess)
+		if n.ICCID == "" && n.IMSI == "" {
+			return nil, ErrNoSIMIdentity
+		}
+	}
+	if err := c.numbers.Save(n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// ListNumbers returns the numbers known for every SIM
+func (c *Client) ListNumbers() ([]model.SIMNumber, error) {
+	return c.numbers.List()
+}
@@ -0,0 +1,123 @@
+package modem
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sms-gateway/src/pkg/at"
+)
+
+// Profile holds the USSD codes of one network. Name is the operator name
+// reported in device info and selects the balance parser.
+type Profile struct {
+	MCCMNC       string `json:"mcc_mnc"`
+	Name         string `json:"name"`
+	BalanceUSSD  string `json:"balance_ussd,omitempty"`
+	PackagesUSSD string `json:"packages_ussd,omitempty"`
+	MSISDNUSSD   string `json:"msisdn_ussd,omitempty"` // own-number lookup
+}
+
+// DefaultProfiles are the Vietnamese networks, keyed by MCC/MNC
+var DefaultProfiles = map[string]Profile{
+	"45201": {MCCMNC: "45201", Name: "Mobifone", BalanceUSSD: "*101#", PackagesUSSD: "*101#", MSISDNUSSD: "*0#"},
+	"45202": {MCCMNC: "45202", Name: "Vinaphone", BalanceUSSD: "*101#", PackagesUSSD: "*101#", MSISDNUSSD: "*110#"},
+	"45204": {MCCMNC: "45204", Name: "Viettel", BalanceUSSD: "*101#", PackagesUSSD: "*102#", MSISDNUSSD: "*098#"},
+	"45205": {MCCMNC: "45205", Name: "Vietnamobile", BalanceUSSD: "*101#", PackagesUSSD: "*102#"},
+	"45207": {MCCMNC: "45207", Name: "Gmobile", BalanceUSSD: "*101#"},
+}
+
+// ParseProfiles reads profile overrides of the form
+// "45204:balance=*101#;packages=*102#;msisdn=*098#;name=Viettel". Fields not
+// given keep the value of the base profile of that network.
+func ParseProfiles(base map[string]Profile, entries []string) (map[string]Profile, error) {
+	profiles := make(map[string]Profile, len(base))
+	for k, p := range base {
+		profiles[k] = p
+	}
+
+	for _, entry := range entries {
+		key, fields, ok := strings.Cut(entry, ":")
+		key = strings.TrimSpace(key)
+		if !ok || !isMCCMNC(key) {
+			return nil, fmt.Errorf("invalid USSD profile %q, expected <mcc><mnc>:<field>=<code>;...", entry)
+		}
+		p := profiles[key]
+		p.MCCMNC = key
+		for _, field := range strings.Split(fields, ";") {
+			if strings.TrimSpace(field) == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid field %q in USSD profile %s", field, key)
+			}
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "name":
+				p.Name = value
+			case "balance":
+				p.BalanceUSSD = value
+			case "packages":
+				p.PackagesUSSD = value
+			case "msisdn":
+				p.MSISDNUSSD = value
+			default:
+				return nil, fmt.Errorf("unknown field %q in USSD profile %s", name, key)
+			}
+		}
+		profiles[key] = p
+	}
+	return profiles, nil
+}
+
+// isMCCMNC reports whether s is a 3 digit MCC followed by a 2 or 3 digit MNC
+func isMCCMNC(s string) bool {
+	if len(s) != 5 && len(s) != 6 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// profileFor selects the profile of the SIM: by the MCC/MNC leading its
+// IMSI, or by the operator name from AT+COPS when the IMSI is unknown. SIMs
+// of other networks get the global codes of the configuration.
+func (c *Client) profileFor(imsi, operator string) Profile {
+	if len(imsi) >= 6 {
+		if p, ok := c.profiles[imsi[:6]]; ok {
+			return p
+		}
+	}
+	if len(imsi) >= 5 {
+		if p, ok := c.profiles[imsi[:5]]; ok {
+			return p
+		}
+	}
+	for _, p := range c.profiles {
+		if operator != "" && strings.EqualFold(p.Name, operator) {
+			return p
+		}
+	}
+	return Profile{
+		Name:         operator,
+		BalanceUSSD:  strings.TrimSpace(c.config.Modem.BalanceUSSD),
+		PackagesUSSD: strings.TrimSpace(c.config.Modem.PackagesUSSD),
+	}
+}
+
+// detectProfile selects the profile of the SIM with the given IMSI, asking
+// the modem for the operator when the IMSI is unknown
+func (c *Client) detectProfile(ctx context.Context, sess *at.Session, imsi string) Profile {
+	operator := ""
+	if len(imsi) < 5 {
+		if resp, err := sess.Command(ctx, "AT+COPS?"); err == nil {
+			operator = c.parseOperator(resp)
+		}
+	}
+	return c.profileFor(imsi, operator)
+}
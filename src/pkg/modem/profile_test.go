@@ -0,0 +1,52 @@
+package modem
+
+import (
+	"testing"
+
+	"sms-gateway/src/internal/config"
+)
+
+func TestParseProfiles(t *testing.T) {
+	profiles, err := ParseProfiles(DefaultProfiles, []string{
+		"45204:balance=*102#",
+		"310260:name=T-Mobile;balance=#999#;msisdn=#686#",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := profiles["45204"]; p.Name != "Viettel" || p.BalanceUSSD != "*102#" || p.MSISDNUSSD != "*098#" {
+		t.Errorf("overridden Viettel profile %+v", p)
+	}
+	if p := profiles["310260"]; p.Name != "T-Mobile" || p.BalanceUSSD != "#999#" || p.MSISDNUSSD != "#686#" {
+		t.Errorf("added profile %+v", p)
+	}
+	if DefaultProfiles["45204"].BalanceUSSD != "*101#" {
+		t.Error("defaults were modified")
+	}
+
+	for _, bad := range []string{"viettel:balance=*101#", "45204:balance", "45204:ussd=*101#"} {
+		if _, err := ParseProfiles(DefaultProfiles, []string{bad}); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestProfileFor(t *testing.T) {
+	cfg := &config.Config{Modem: config.ModemConfig{BalanceUSSD: "*100#"}}
+	cfg.Modem.USSDProfiles = []string{"310260:name=T-Mobile;balance=#999#"}
+	c := NewClient(cfg, nil, nil)
+
+	tests := []struct {
+		imsi, operator, name, balance string
+	}{
+		{"452040000000001", "", "Viettel", "*101#"},
+		{"310260000000001", "", "T-Mobile", "#999#"},
+		{"", "Vinaphone", "Vinaphone", "*101#"},
+		{"208150000000001", "Free", "Free", "*100#"},
+	}
+	for _, tt := range tests {
+		if p := c.profileFor(tt.imsi, tt.operator); p.Name != tt.name || p.BalanceUSSD != tt.balance {
+			t.Errorf("profileFor(%q, %q) = %+v", tt.imsi, tt.operator, p)
+		}
+	}
+}
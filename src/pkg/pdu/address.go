@@ -0,0 +1,135 @@
+package pdu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type of number / numbering plan octets
+const (
+	TypeInternational = 0x91
+	TypeNational      = 0x81
+	TypeAlphanumeric  = 0xD0
+	typeOfNumberMask  = 0x70
+	typeOfNumberIntl  = 0x10
+	typeOfNumberAlpha = 0x50
+)
+
+// EncodeAddress encodes a phone number as address length (in digits),
+// type of address and swapped semi-octets
+func EncodeAddress(number string) ([]byte, error) {
+	addrType := byte(TypeNational)
+	digits := number
+	if strings.HasPrefix(digits, "+") {
+		addrType = TypeInternational
+		digits = digits[1:]
+	}
+	if digits == "" {
+		return nil, fmt.Errorf("empty address")
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return nil, fmt.Errorf("invalid address %q", number)
+		}
+	}
+
+	encoded := []byte{byte(len(digits)), addrType}
+	return append(encoded, swapSemiOctets(digits)...), nil
+}
+
+// encodeSMSC encodes the SMSC field that leads a PDU, whose length counts
+// octets rather than digits. An empty number gives the single octet 00.
+func encodeSMSC(number string) ([]byte, error) {
+	if number == "" {
+		return []byte{0}, nil
+	}
+	address, err := EncodeAddress(number)
+	if err != nil {
+		return nil, fmt.Errorf("SMSC: %w", err)
+	}
+	return append([]byte{byte(len(address) - 1)}, address[1:]...), nil
+}
+
+// encodeOriginator encodes a sender, which is either a phone number or an
+// alphanumeric name of up to 11 GSM characters such as "Viettel"
+func encodeOriginator(sender string) ([]byte, error) {
+	if encoded, err := EncodeAddress(sender); err == nil {
+		return encoded, nil
+	}
+	return encodeAlphanumeric(sender)
+}
+
+// encodeAlphanumeric encodes a sender name in GSM 7-bit; the length counts
+// the semi-octets the packed name occupies
+func encodeAlphanumeric(name string) ([]byte, error) {
+	septets, err := EncodeGSM7(name)
+	if err != nil || len(septets) > 11 {
+		return nil, fmt.Errorf("invalid address %q", name)
+	}
+	packed := PackSeptets(septets, 0)
+	encoded := []byte{byte((len(septets)*7 + 3) / 4), TypeAlphanumeric}
+	return append(encoded, packed...), nil
+}
+
+// swapSemiOctets packs decimal digits two per octet, low nibble first,
+// padding an odd count with F
+func swapSemiOctets(digits string) []byte {
+	if len(digits)%2 == 1 {
+		digits += "F"
+	}
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		out = append(out, nibble(digits[i+1])<<4|nibble(digits[i]))
+	}
+	return out
+}
+
+func nibble(c byte) byte {
+	if c >= '0' && c <= '9' {
+		return c - '0'
+	}
+	return 0x0F
+}
+
+// DecodeAddress decodes an address field starting at data[0] and returns
+// the address and the number of octets consumed. International numbers get
+// a leading +; alphanumeric senders are decoded from GSM 7-bit.
+func DecodeAddress(data []byte) (string, int, error) {
+	if len(data) < 2 {
+		return "", 0, fmt.Errorf("address field truncated")
+	}
+	length, addrType := int(data[0]), data[1]
+	size := 2 + (length+1)/2
+	if len(data) < size {
+		return "", 0, fmt.Errorf("address field truncated")
+	}
+	value := data[2:size]
+
+	switch addrType & typeOfNumberMask {
+	case typeOfNumberAlpha:
+		septets := UnpackSeptets(value, length*4/7, 0)
+		return DecodeGSM7(septets), size, nil
+	case typeOfNumberIntl:
+		return "+" + semiOctetDigits(value, length), size, nil
+	default:
+		return semiOctetDigits(value, length), size, nil
+	}
+}
+
+// semiOctetDigits unpacks count swapped semi-octet digits. Values above 9
+// map to the telephony characters *, #, a, b and c.
+func semiOctetDigits(data []byte, count int) string {
+	const digits = "0123456789*#abc"
+	var b strings.Builder
+	for i := 0; i < count && i/2 < len(data); i++ {
+		v := data[i/2]
+		if i%2 == 1 {
+			v >>= 4
+		}
+		v &= 0x0F
+		if int(v) < len(digits) {
+			b.WriteByte(digits[v])
+		}
+	}
+	return b.String()
+}
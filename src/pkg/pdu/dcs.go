@@ -0,0 +1,102 @@
+package pdu
+
+import "fmt"
+
+// MessageClass is the class set by a TP-DCS. The zero value means no class.
+type MessageClass int
+
+// Message classes
+const (
+	ClassNone MessageClass = iota
+	Class0                 // flash message, shown and not stored
+	Class1                 // stored in the handset
+	Class2                 // stored on the SIM
+	Class3                 // passed to external equipment
+)
+
+// String returns "0" to "3", or "" for ClassNone
+func (c MessageClass) String() string {
+	if c < Class0 || c > Class3 {
+		return ""
+	}
+	return fmt.Sprint(int(c - Class0))
+}
+
+// ParseClass converts "0" to "3" to a MessageClass; "" is ClassNone
+func ParseClass(s string) (MessageClass, error) {
+	switch s {
+	case "":
+		return ClassNone, nil
+	case "0", "1", "2", "3":
+		return Class0 + MessageClass(s[0]-'0'), nil
+	}
+	return ClassNone, fmt.Errorf("invalid message class %q", s)
+}
+
+// DataCoding is a decoded TP-DCS octet
+type DataCoding struct {
+	Encoding   string // EncodingGSM7, EncodingUCS2 or Encoding8Bit
+	Class      MessageClass
+	Compressed bool
+	// MessageWaiting is set by the message waiting groups, in which the
+	// message only updates an indicator such as voicemail
+	MessageWaiting bool
+}
+
+// DecodeDCS decodes a TP-DCS octet. Reserved values are treated as GSM
+// 7-bit, as the specification requires.
+func DecodeDCS(dcs byte) DataCoding {
+	coding := DataCoding{Encoding: EncodingGSM7}
+	switch {
+	case dcs&0xC0 == 0x00, dcs&0xC0 == 0x40: // general data coding, 01 = automatic deletion
+		coding.Encoding = alphabet(dcs >> 2)
+		coding.Compressed = dcs&0x20 != 0
+		if dcs&0x10 != 0 {
+			coding.Class = Class0 + MessageClass(dcs&0x03)
+		}
+	case dcs&0xF0 == 0xC0, dcs&0xF0 == 0xD0: // message waiting, GSM 7-bit
+		coding.MessageWaiting = true
+	case dcs&0xF0 == 0xE0: // message waiting, UCS2
+		coding.Encoding = EncodingUCS2
+		coding.MessageWaiting = true
+	case dcs&0xF0 == 0xF0: // data coding/message class
+		if dcs&0x04 != 0 {
+			coding.Encoding = Encoding8Bit
+		}
+		coding.Class = Class0 + MessageClass(dcs&0x03)
+	}
+	return coding
+}
+
+// alphabet decodes the two alphabet bits of the general data coding groups
+func alphabet(bits byte) string {
+	switch bits & 0x03 {
+	case 0x01:
+		return Encoding8Bit
+	case 0x02:
+		return EncodingUCS2
+	}
+	return EncodingGSM7
+}
+
+// Alphabet returns the encoding selected by a TP-DCS octet
+func Alphabet(dcs byte) string {
+	return DecodeDCS(dcs).Encoding
+}
+
+// EncodeDCS returns the general data coding TP-DCS for an encoding and
+// class, e.g. 0x00 for GSM 7-bit without class and 0x18 for a UCS2 flash
+// message
+func EncodeDCS(encoding string, class MessageClass) byte {
+	var dcs byte
+	switch encoding {
+	case Encoding8Bit:
+		dcs = 0x04
+	case EncodingUCS2:
+		dcs = 0x08
+	}
+	if class >= Class0 && class <= Class3 {
+		dcs |= 0x10 | byte(class-Class0)
+	}
+	return dcs
+}
@@ -0,0 +1,50 @@
+package pdu
+
+import "fmt"
+
+// Message types reported by Decode
+const (
+	TypeDeliver      = "SMS-DELIVER"
+	TypeSubmit       = "SMS-SUBMIT"
+	TypeStatusReport = "SMS-STATUS-REPORT"
+)
+
+// Message is a PDU of any type; exactly one of the pointers is set
+type Message struct {
+	Type         string
+	Deliver      *Deliver
+	Submit       *Submit
+	StatusReport *StatusReport
+}
+
+// Decode decodes a PDU given as hex with its leading SMSC field, choosing
+// the decoder from the message type indicator. Malformed input returns an
+// error and never panics.
+func Decode(pduHex string) (*Message, error) {
+	_, tpdu, err := splitSMSC(pduHex)
+	if err != nil {
+		return nil, err
+	}
+	if len(tpdu) == 0 {
+		return nil, fmt.Errorf("message truncated")
+	}
+
+	msg := &Message{}
+	switch tpdu[0] & mtiMask {
+	case mtiDeliver:
+		msg.Type = TypeDeliver
+		msg.Deliver, err = DecodeDeliver(pduHex)
+	case mtiSubmit:
+		msg.Type = TypeSubmit
+		msg.Submit, err = DecodeSubmit(pduHex)
+	case mtiStatusReport:
+		msg.Type = TypeStatusReport
+		msg.StatusReport, err = DecodeStatusReport(pduHex)
+	default:
+		return nil, fmt.Errorf("reserved message type (first octet %02X)", tpdu[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
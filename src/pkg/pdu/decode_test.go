@@ -0,0 +1,133 @@
+package pdu
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		pdu  string
+		kind string
+	}{
+		{"07917283010010F5040BC87238880900F10000623092516195800AE8329BFD4697D9EC37", TypeDeliver},
+		{"0011000B916407281553F80000AA0AE8329BFD4697D9EC37", TypeSubmit},
+		{"0791448720003023062E0B914819325476F8620161710000826201617100508200", TypeStatusReport},
+	}
+	for _, tt := range tests {
+		msg, err := Decode(tt.pdu)
+		if err != nil {
+			t.Errorf("%s: %v", tt.kind, err)
+			continue
+		}
+		if msg.Type != tt.kind {
+			t.Errorf("%s: decoded as %s", tt.kind, msg.Type)
+		}
+	}
+
+	if _, err := Decode("0003"); err == nil {
+		t.Error("expected error for the reserved message type")
+	}
+}
+
+func TestDecodeSubmit(t *testing.T) {
+	msg, err := DecodeSubmit("0011000B916407281553F80000AA0AE8329BFD4697D9EC37")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Destination != "+46708251358" || msg.Text != "hellohello" || msg.ValidityPeriod != 4*24*time.Hour || msg.Encoding != EncodingGSM7 {
+		t.Errorf("unexpected submit %+v", msg)
+	}
+}
+
+func TestSubmitRoundTrip(t *testing.T) {
+	zone := time.FixedZone("", -5*3600)
+	tests := []Submit{
+		{SMSC: "+84980200030", Destination: "+84912345678", Text: "Flash!", Class: Class0, ValidityPeriod: time.Hour},
+		{Destination: "0912345678", Text: "Hạn dùng", ValidUntil: time.Date(2026, 12, 31, 23, 59, 0, 0, zone), StatusReport: true},
+		{Destination: "0912345678", Text: "0102FF", Encoding: Encoding8Bit, Class: Class1},
+		{Destination: "0912345678", Text: "part", Header: []InformationElement{ConcatElement(9, 2, 1, false)}, Reference: 42},
+	}
+	for _, in := range tests {
+		encoded, length, err := in.Encode()
+		if err != nil {
+			t.Fatalf("%q: %v", in.Text, err)
+		}
+		if raw, _ := hex.DecodeString(encoded); length != len(raw)-1-int(raw[0]) {
+			t.Errorf("%q: length %d does not exclude the SMSC", in.Text, length)
+		}
+		out, err := DecodeSubmit(encoded)
+		if err != nil {
+			t.Fatalf("%q: %v", in.Text, err)
+		}
+		if out.SMSC != in.SMSC || out.Destination != in.Destination || out.Text != in.Text || out.Class != in.Class ||
+			out.StatusReport != in.StatusReport || out.Reference != in.Reference || len(out.Header) != len(in.Header) {
+			t.Errorf("round trip of %+v gave %+v", in, out)
+		}
+		if in.ValidityPeriod > 0 && out.ValidityPeriod != in.ValidityPeriod {
+			t.Errorf("%q: validity %v, want %v", in.Text, out.ValidityPeriod, in.ValidityPeriod)
+		}
+		if !in.ValidUntil.IsZero() && !out.ValidUntil.Equal(in.ValidUntil) {
+			t.Errorf("%q: valid until %v, want %v", in.Text, out.ValidUntil, in.ValidUntil)
+		}
+	}
+
+	if _, _, err := (&Submit{Destination: "0912345678", Text: "ệ", Encoding: EncodingGSM7}).Encode(); err == nil {
+		t.Error("expected error forcing GSM 7-bit for a non-GSM character")
+	}
+}
+
+func TestDecodeDCS(t *testing.T) {
+	tests := []struct {
+		dcs    byte
+		coding DataCoding
+	}{
+		{0x00, DataCoding{Encoding: EncodingGSM7}},
+		{0x10, DataCoding{Encoding: EncodingGSM7, Class: Class0}},
+		{0x19, DataCoding{Encoding: EncodingUCS2, Class: Class1}},
+		{0x16, DataCoding{Encoding: Encoding8Bit, Class: Class2}},
+		{0x28, DataCoding{Encoding: EncodingUCS2, Compressed: true}},
+		{0xC8, DataCoding{Encoding: EncodingGSM7, MessageWaiting: true}},
+		{0xE0, DataCoding{Encoding: EncodingUCS2, MessageWaiting: true}},
+		{0xF3, DataCoding{Encoding: EncodingGSM7, Class: Class3}},
+	}
+	for _, tt := range tests {
+		if got := DecodeDCS(tt.dcs); got != tt.coding {
+			t.Errorf("DecodeDCS(%02X) = %+v, want %+v", tt.dcs, got, tt.coding)
+		}
+		if !tt.coding.Compressed && !tt.coding.MessageWaiting && tt.dcs < 0xC0 {
+			if dcs := EncodeDCS(tt.coding.Encoding, tt.coding.Class); dcs != tt.dcs {
+				t.Errorf("EncodeDCS(%+v) = %02X, want %02X", tt.coding, dcs, tt.dcs)
+			}
+		}
+	}
+}
+
+func TestRelativeDuration(t *testing.T) {
+	for _, d := range []time.Duration{time.Hour, 12 * time.Hour, 24 * time.Hour, 4 * 24 * time.Hour, 5 * 7 * 24 * time.Hour} {
+		if got := RelativeDuration(RelativeValidity(d)); got != d {
+			t.Errorf("RelativeDuration(RelativeValidity(%v)) = %v", d, got)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range []string{
+		"07917283010010F5040BC87238880900F10000623092516195800AE8329BFD4697D9EC37",
+		"0011000B916407281553F80000AA0AE8329BFD4697D9EC37",
+		"0051000B914819325476F80000A70B050003420201E8329BFD06",
+		"0791448720003023062E0B914819325476F8620161710000826201617100508200",
+		"00040DD0D6B2BC3CA7B3D96200006201618000008204C3B3FB0D",
+		"0019000B914819325476F80004AA0101",
+	} {
+		data, _ := hex.DecodeString(seed)
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := Decode(hex.EncodeToString(data))
+		if err == nil && msg.Deliver == nil && msg.Submit == nil && msg.StatusReport == nil {
+			t.Errorf("no message decoded from %X", data)
+		}
+	})
+}
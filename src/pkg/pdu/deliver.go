@@ -0,0 +1,149 @@
+package pdu
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// mtiDeliver is the message type indicator of an SMS-DELIVER
+const mtiDeliver = 0x00
+
+// Deliver is a decoded SMS-DELIVER, a message received by the modem
+type Deliver struct {
+	SMSC      string
+	Sender    string    // TP-OA, a number or an alphanumeric name
+	Timestamp time.Time // TP-SCTS, when the SMSC received the message
+	// Encoding is EncodingGSM7, EncodingUCS2 or Encoding8Bit; empty selects
+	// GSM 7-bit or UCS2 from the text when encoding
+	Encoding string
+	Class    MessageClass
+	Header   []InformationElement
+	// Text is the decoded message; 8-bit data is given as upper case hex
+	Text string
+}
+
+// DecodeDeliver decodes an SMS-DELIVER given as hex with its leading SMSC
+// field, as listed by AT+CMGL and AT+CMGR in PDU mode
+func DecodeDeliver(pduHex string) (*Deliver, error) {
+	smsc, tpdu, err := splitSMSC(pduHex)
+	if err != nil {
+		return nil, err
+	}
+	if len(tpdu) < 1 {
+		return nil, fmt.Errorf("message truncated")
+	}
+	firstOctet := tpdu[0]
+	if firstOctet&mtiMask != mtiDeliver {
+		return nil, fmt.Errorf("not an SMS-DELIVER (first octet %02X)", firstOctet)
+	}
+
+	msg := &Deliver{SMSC: smsc}
+	pos := 1
+	sender, n, err := DecodeAddress(tpdu[pos:])
+	if err != nil {
+		return nil, fmt.Errorf("sender: %w", err)
+	}
+	msg.Sender = sender
+	pos += n
+
+	// TP-PID, TP-DCS, TP-SCTS, TP-UDL
+	if len(tpdu) < pos+2+timestampLen+1 {
+		return nil, fmt.Errorf("message truncated")
+	}
+	coding := DecodeDCS(tpdu[pos+1])
+	msg.Encoding, msg.Class = coding.Encoding, coding.Class
+	pos += 2
+	if msg.Timestamp, err = DecodeTimestamp(tpdu[pos:]); err != nil {
+		return nil, err
+	}
+	pos += timestampLen
+	udl := int(tpdu[pos])
+	pos++
+
+	msg.Header, msg.Text, err = decodeUserData(msg.Encoding, firstOctet&udhi != 0, udl, tpdu[pos:])
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// decodeUserData splits user data into its header and decoded text. udl
+// counts septets, header included, for GSM 7-bit and octets otherwise.
+func decodeUserData(encoding string, hasHeader bool, udl int, ud []byte) ([]InformationElement, string, error) {
+	headerLen := 0
+	var header []InformationElement
+	if hasHeader {
+		if len(ud) < 1 || len(ud) < 1+int(ud[0]) {
+			return nil, "", fmt.Errorf("user data header truncated")
+		}
+		headerLen = 1 + int(ud[0])
+		var err error
+		if header, err = decodeHeader(ud[1:headerLen]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if encoding == EncodingGSM7 {
+		headerBits := headerLen * 8
+		fill := (7 - headerBits%7) % 7
+		count := udl - (headerBits+fill)/7
+		if count < 0 || len(ud) < (udl*7+7)/8 {
+			return nil, "", fmt.Errorf("user data truncated")
+		}
+		return header, DecodeGSM7(UnpackSeptets(ud[headerLen:], count, fill)), nil
+	}
+
+	if udl > len(ud) || udl < headerLen {
+		return nil, "", fmt.Errorf("user data truncated")
+	}
+	data := ud[headerLen:udl]
+	if encoding == EncodingUCS2 {
+		return header, DecodeUCS2(data), nil
+	}
+	return header, strings.ToUpper(hex.EncodeToString(data)), nil
+}
+
+// decodeHeader parses the information elements of a user data header,
+// without its length octet
+func decodeHeader(data []byte) ([]InformationElement, error) {
+	var elements []InformationElement
+	for pos := 0; pos < len(data); {
+		if pos+2 > len(data) || pos+2+int(data[pos+1]) > len(data) {
+			return nil, fmt.Errorf("malformed user data header")
+		}
+		length := int(data[pos+1])
+		elements = append(elements, InformationElement{
+			ID:   data[pos],
+			Data: append([]byte(nil), data[pos+2:pos+2+length]...),
+		})
+		pos += 2 + length
+	}
+	return elements, nil
+}
+
+// Encode returns the message as hex with an empty SMSC field
+func (d *Deliver) Encode() (string, error) {
+	header := encodeHeader(d.Header)
+	encoding, udl, ud, err := encodeUserData(d.Text, d.Encoding, header)
+	if err != nil {
+		return "", err
+	}
+	sender, err := encodeOriginator(d.Sender)
+	if err != nil {
+		return "", err
+	}
+
+	firstOctet := byte(mtiDeliver)
+	if header != nil {
+		firstOctet |= udhi
+	}
+	tpdu := []byte{firstOctet}
+	tpdu = append(tpdu, sender...)
+	tpdu = append(tpdu, 0x00, EncodeDCS(encoding, d.Class)) // TP-PID, TP-DCS
+	tpdu = append(tpdu, EncodeTimestamp(d.Timestamp)...)
+	tpdu = append(tpdu, udl)
+	tpdu = append(tpdu, ud...)
+	return "00" + strings.ToUpper(hex.EncodeToString(tpdu)), nil
+}
@@ -0,0 +1,101 @@
+package pdu
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeDeliver(t *testing.T) {
+	// SMSC +27381000015, sender 27838890001, 2026-03-29 15:16:59 +02:00
+	msg, err := DecodeDeliver("07917283010010F5040BC87238880900F10000623092516195800AE8329BFD4697D9EC37")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.SMSC != "+27381000015" || msg.Sender != "27838890001" || msg.Text != "hellohello" || msg.Encoding != EncodingGSM7 {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if want := time.Date(2026, 3, 29, 15, 16, 59, 0, time.FixedZone("", 2*3600)); !msg.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", msg.Timestamp, want)
+	}
+
+	if _, err := DecodeDeliver("0011000B914819325476F80000A70568656C6C6F"); err == nil {
+		t.Error("expected error for an SMS-SUBMIT")
+	}
+	if _, err := DecodeDeliver("07917283010010F5040BC87238880900F10000623092516195800AE8329B"); err == nil {
+		t.Error("expected error for truncated user data")
+	}
+}
+
+func TestDeliverRoundTrip(t *testing.T) {
+	zone := time.FixedZone("", 7*3600)
+	tests := []struct {
+		text     string
+		header   []InformationElement
+		encoding string
+	}{
+		{"Hello {world}", nil, EncodingGSM7},
+		{"Tài khoản của bạn", nil, EncodingUCS2},
+		{"part two", []InformationElement{ConcatElement(0x42, 2, 2, false)}, EncodingGSM7},
+		{"phần hai", []InformationElement{ConcatElement(0x1234, 3, 2, true)}, EncodingUCS2},
+	}
+	for _, tt := range tests {
+		in := &Deliver{Sender: "+84912345678", Timestamp: time.Date(2026, 10, 16, 8, 30, 0, 0, zone), Text: tt.text, Header: tt.header}
+		encoded, err := in.Encode()
+		if err != nil {
+			t.Fatalf("%q: %v", tt.text, err)
+		}
+		out, err := DecodeDeliver(encoded)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.text, err)
+		}
+		if out.Text != tt.text || out.Encoding != tt.encoding || out.Sender != in.Sender || !out.Timestamp.Equal(in.Timestamp) {
+			t.Errorf("%q: round trip gave %+v", tt.text, out)
+		}
+		if len(out.Header) != len(tt.header) || (len(tt.header) > 0 && string(out.Header[0].Data) != string(tt.header[0].Data)) {
+			t.Errorf("%q: header %+v, want %+v", tt.text, out.Header, tt.header)
+		}
+	}
+}
+
+func TestDecodeDeliver8Bit(t *testing.T) {
+	// DCS F4: message class 0, 8-bit data
+	msg, err := DecodeDeliver("00040B914819325476F800F462016180000082040102FEFF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Encoding != Encoding8Bit || msg.Text != "0102FEFF" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+}
+
+func TestAlphabet(t *testing.T) {
+	tests := map[byte]string{
+		0x00: EncodingGSM7,
+		0x04: Encoding8Bit,
+		0x08: EncodingUCS2,
+		0x18: EncodingUCS2,
+		0xE0: EncodingUCS2,
+		0xF0: EncodingGSM7,
+		0xF5: Encoding8Bit,
+	}
+	for dcs, want := range tests {
+		if got := Alphabet(dcs); got != want {
+			t.Errorf("Alphabet(%02X) = %s, want %s", dcs, got, want)
+		}
+	}
+}
+
+func TestAlphanumericSender(t *testing.T) {
+	in := &Deliver{Sender: "Viettel", Timestamp: time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC), Text: "KM 50%"}
+	encoded, err := in.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecodeDeliver(encoded)
+	if err != nil || out.Sender != "Viettel" {
+		t.Errorf("sender = %q, %v", out.Sender, err)
+	}
+	if _, err := (&Deliver{Sender: "TooLongSenderName", Text: "x"}).Encode(); err == nil {
+		t.Error("expected error for a sender name over 11 characters")
+	}
+}
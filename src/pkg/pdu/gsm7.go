@@ -0,0 +1,155 @@
+package pdu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// escape introduces a character from the GSM 03.38 extension table
+const escape = 0x1B
+
+// gsm7Basic is the GSM 03.38 default alphabet indexed by septet value.
+// 0x1B is the escape to the extension table and decodes as a space.
+var gsm7Basic = [128]rune{
+	'@', '£', '$', '¥', 'è', 'é', 'ù', 'ì', 'ò', 'Ç', '\n', 'Ø', 'ø', '\r', 'Å', 'å',
+	'Δ', '_', 'Φ', 'Γ', 'Λ', 'Ω', 'Π', 'Ψ', 'Σ', 'Θ', 'Ξ', ' ', 'Æ', 'æ', 'ß', 'É',
+	' ', '!', '"', '#', '¤', '%', '&', '\'', '(', ')', '*', '+', ',', '-', '.', '/',
+	'0', '1', '2', '3', '4', '5', '6', '7', '8', '9', ':', ';', '<', '=', '>', '?',
+	'¡', 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O',
+	'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z', 'Ä', 'Ö', 'Ñ', 'Ü', '§',
+	'¿', 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm', 'n', 'o',
+	'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z', 'ä', 'ö', 'ñ', 'ü', 'à',
+}
+
+// gsm7Extension maps the septet following an escape to its character
+var gsm7Extension = map[byte]rune{
+	0x0A: '\f',
+	0x14: '^',
+	0x28: '{',
+	0x29: '}',
+	0x2F: '\\',
+	0x3C: '[',
+	0x3D: '~',
+	0x3E: ']',
+	0x40: '|',
+	0x65: '€',
+}
+
+var (
+	basicIndex     = map[rune]byte{}
+	extensionIndex = map[rune]byte{}
+)
+
+func init() {
+	for i, r := range gsm7Basic {
+		if i == escape {
+			continue
+		}
+		if _, ok := basicIndex[r]; !ok {
+			basicIndex[r] = byte(i)
+		}
+	}
+	for septet, r := range gsm7Extension {
+		extensionIndex[r] = septet
+	}
+}
+
+// IsGSM7 reports whether every character of s is in the GSM 7-bit default
+// alphabet or its extension table
+func IsGSM7(s string) bool {
+	_, ok := SeptetLen(s)
+	return ok
+}
+
+// SeptetLen returns the number of septets s occupies in GSM 7-bit encoding;
+// extension characters count twice. ok is false if s needs another alphabet.
+func SeptetLen(s string) (n int, ok bool) {
+	for _, r := range s {
+		if _, found := basicIndex[r]; found {
+			n++
+			continue
+		}
+		if _, found := extensionIndex[r]; found {
+			n += 2
+			continue
+		}
+		return 0, false
+	}
+	return n, true
+}
+
+// EncodeGSM7 converts s to unpacked septets
+func EncodeGSM7(s string) ([]byte, error) {
+	septets := make([]byte, 0, len(s))
+	for _, r := range s {
+		if septet, ok := basicIndex[r]; ok {
+			septets = append(septets, septet)
+			continue
+		}
+		if septet, ok := extensionIndex[r]; ok {
+			septets = append(septets, escape, septet)
+			continue
+		}
+		return nil, fmt.Errorf("character %q is not in the GSM 7-bit alphabet", r)
+	}
+	return septets, nil
+}
+
+// DecodeGSM7 converts unpacked septets to text. Unknown extension codes
+// decode as their basic table character, as the specification requires.
+func DecodeGSM7(septets []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(septets); i++ {
+		septet := septets[i] & 0x7F
+		if septet == escape && i+1 < len(septets) {
+			i++
+			next := septets[i] & 0x7F
+			if r, ok := gsm7Extension[next]; ok {
+				b.WriteRune(r)
+			} else {
+				b.WriteRune(gsm7Basic[next])
+			}
+			continue
+		}
+		b.WriteRune(gsm7Basic[septet])
+	}
+	return b.String()
+}
+
+// PackSeptets packs septets into octets, least significant bit first.
+// fill is the number of padding bits placed before the first septet so that
+// it starts on a septet boundary after a user data header.
+func PackSeptets(septets []byte, fill int) []byte {
+	totalBits := fill + len(septets)*7
+	packed := make([]byte, (totalBits+7)/8)
+	bit := fill
+	for _, septet := range septets {
+		septet &= 0x7F
+		idx, shift := bit/8, uint(bit%8)
+		packed[idx] |= septet << shift
+		if shift > 1 {
+			packed[idx+1] |= septet >> (8 - shift)
+		}
+		bit += 7
+	}
+	return packed
+}
+
+// UnpackSeptets extracts count septets from packed data after fill padding bits
+func UnpackSeptets(data []byte, count, fill int) []byte {
+	septets := make([]byte, 0, count)
+	bit := fill
+	for i := 0; i < count; i++ {
+		idx, shift := bit/8, uint(bit%8)
+		if idx >= len(data) {
+			break
+		}
+		value := data[idx] >> shift
+		if shift > 1 && idx+1 < len(data) {
+			value |= data[idx+1] << (8 - shift)
+		}
+		septets = append(septets, value&0x7F)
+		bit += 7
+	}
+	return septets
+}
@@ -0,0 +1,146 @@
+package pdu
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPackGSM7(t *testing.T) {
+	tests := []struct {
+		text   string
+		packed string
+	}{
+		{"hellohello", "E8329BFD4697D9EC37"},
+		{"How are you?", "C8F71D14969741F977FD07"},
+		{"@", "00"},
+		{"€", "9B32"},
+		{"[", "1B1E"},
+	}
+
+	for _, tt := range tests {
+		septets, err := EncodeGSM7(tt.text)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.text, err)
+		}
+		got := strings.ToUpper(hex.EncodeToString(PackSeptets(septets, 0)))
+		if got != tt.packed {
+			t.Errorf("%q packed to %s, want %s", tt.text, got, tt.packed)
+		}
+	}
+}
+
+func TestGSM7RoundTrip(t *testing.T) {
+	texts := []string{
+		"hellohello",
+		"12345678",
+		"Gia: 100€ {ok} [x] \\ ^ ~ |",
+		"@£$¥èéùìòÇØøÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ!\"#¤%&'()*+,-./",
+	}
+
+	for _, text := range texts {
+		septets, err := EncodeGSM7(text)
+		if err != nil {
+			t.Fatalf("%q: %v", text, err)
+		}
+		for fill := 0; fill < 7; fill++ {
+			packed := PackSeptets(septets, fill)
+			got := DecodeGSM7(UnpackSeptets(packed, len(septets), fill))
+			if got != text {
+				t.Errorf("fill %d: round trip of %q gave %q", fill, text, got)
+			}
+		}
+	}
+}
+
+func TestSeptetLen(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		ok   bool
+	}{
+		{"hello", 5, true},
+		{"{}", 4, true},
+		{"a€b", 4, true},
+		{"Xin chào", 8, true},
+		{"Tiếng Việt", 0, false},
+	}
+
+	for _, tt := range tests {
+		n, ok := SeptetLen(tt.text)
+		if n != tt.n || ok != tt.ok {
+			t.Errorf("SeptetLen(%q) = %d, %v; want %d, %v", tt.text, n, ok, tt.n, tt.ok)
+		}
+	}
+}
+
+func TestEncodeSubmit(t *testing.T) {
+	tests := []struct {
+		name   string
+		submit Submit
+		pdu    string
+	}{
+		{
+			name:   "international with validity",
+			submit: Submit{Destination: "+46708251358", Text: "hellohello", ValidityPeriod: 4 * 24 * time.Hour},
+			pdu:    "0011000B916407281553F80000AA0AE8329BFD4697D9EC37",
+		},
+		{
+			name:   "national without validity",
+			submit: Submit{Destination: "0912345678", Text: "€"},
+			pdu:    "0001000A8190214365870000029B32",
+		},
+		{
+			name:   "udl counts escape septets",
+			submit: Submit{Destination: "+84912345678", Text: "[a]"},
+			pdu:    "0001000B914819325476F80000051B5E78E303",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdu, length, err := tt.submit.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pdu != tt.pdu {
+				t.Errorf("pdu = %s, want %s", pdu, tt.pdu)
+			}
+			if want := len(tt.pdu)/2 - 1; length != want {
+				t.Errorf("length = %d, want %d", length, want)
+			}
+		})
+	}
+}
+
+func TestEncodeSubmitRejects(t *testing.T) {
+	if _, _, err := (&Submit{Destination: "0912345678", Text: strings.Repeat("€", 81)}).Encode(); err == nil {
+		t.Error("expected error for 162 septets")
+	}
+	if _, _, err := (&Submit{Destination: "09x", Text: "hi"}).Encode(); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
+
+func TestRelativeValidity(t *testing.T) {
+	tests := []struct {
+		d  time.Duration
+		vp byte
+	}{
+		{time.Hour, 11},
+		{12 * time.Hour, 143},
+		{24 * time.Hour, 167},
+		{4 * 24 * time.Hour, 170},
+		{30 * 24 * time.Hour, 196},
+		{5 * 7 * 24 * time.Hour, 197},
+		{52 * 7 * 24 * time.Hour, 244},
+		{500 * 24 * time.Hour, 255},
+	}
+
+	for _, tt := range tests {
+		if got := RelativeValidity(tt.d); got != tt.vp {
+			t.Errorf("RelativeValidity(%v) = %d, want %d", tt.d, got, tt.vp)
+		}
+	}
+}
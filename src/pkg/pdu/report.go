@@ -0,0 +1,132 @@
+package pdu
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message type indicator values of the first octet
+const (
+	mtiMask         = 0x03
+	mtiStatusReport = 0x02
+)
+
+// Delivery states derived from TP-Status
+const (
+	ReportDelivered = "delivered"
+	ReportPending   = "pending"
+	ReportFailed    = "failed"
+	ReportExpired   = "expired"
+)
+
+// statusValidityExpired is the TP-Status for "SM validity period expired"
+const statusValidityExpired = 0x46
+
+// StatusReport is a decoded SMS-STATUS-REPORT
+type StatusReport struct {
+	SMSC              string
+	Reference         int
+	Recipient         string
+	ServiceCentreTime time.Time // when the SMSC received the message
+	DischargeTime     time.Time // when the final status was reached
+	Status            byte      // TP-Status
+}
+
+// State classifies the TP-Status as delivered, pending (the SMSC is still
+// trying), expired or failed
+func (r *StatusReport) State() string {
+	switch {
+	case r.Status <= 0x1F:
+		return ReportDelivered
+	case r.Status <= 0x3F:
+		return ReportPending
+	case r.Status == statusValidityExpired:
+		return ReportExpired
+	default:
+		return ReportFailed
+	}
+}
+
+// DecodeStatusReport decodes an SMS-STATUS-REPORT given as hex with its
+// leading SMSC field, as the modem reports it in +CDS and +CMGR
+func DecodeStatusReport(pduHex string) (*StatusReport, error) {
+	smsc, tpdu, err := splitSMSC(pduHex)
+	if err != nil {
+		return nil, err
+	}
+	if len(tpdu) < 2 {
+		return nil, fmt.Errorf("status report truncated")
+	}
+	if tpdu[0]&mtiMask != mtiStatusReport {
+		return nil, fmt.Errorf("not a status report (first octet %02X)", tpdu[0])
+	}
+
+	report := &StatusReport{SMSC: smsc, Reference: int(tpdu[1])}
+	pos := 2
+
+	recipient, n, err := DecodeAddress(tpdu[pos:])
+	if err != nil {
+		return nil, fmt.Errorf("recipient: %w", err)
+	}
+	report.Recipient = recipient
+	pos += n
+
+	if len(tpdu) < pos+2*timestampLen+1 {
+		return nil, fmt.Errorf("status report truncated")
+	}
+	if report.ServiceCentreTime, err = DecodeTimestamp(tpdu[pos:]); err != nil {
+		return nil, err
+	}
+	pos += timestampLen
+	if report.DischargeTime, err = DecodeTimestamp(tpdu[pos:]); err != nil {
+		return nil, err
+	}
+	pos += timestampLen
+	report.Status = tpdu[pos]
+
+	return report, nil
+}
+
+// Encode returns the status report as hex with an empty SMSC field
+func (r *StatusReport) Encode() (string, error) {
+	recipient, err := EncodeAddress(r.Recipient)
+	if err != nil {
+		return "", err
+	}
+
+	tpdu := []byte{mtiStatusReport, byte(r.Reference)}
+	tpdu = append(tpdu, recipient...)
+	tpdu = append(tpdu, EncodeTimestamp(r.ServiceCentreTime)...)
+	tpdu = append(tpdu, EncodeTimestamp(r.DischargeTime)...)
+	tpdu = append(tpdu, r.Status)
+	return "00" + strings.ToUpper(hex.EncodeToString(tpdu)), nil
+}
+
+// splitSMSC separates the SMSC field that leads a PDU received from the
+// modem and returns the SMSC number and the TPDU octets
+func splitSMSC(pduHex string) (string, []byte, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(pduHex))
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid PDU hex: %w", err)
+	}
+	if len(data) == 0 {
+		return "", nil, fmt.Errorf("empty PDU")
+	}
+
+	smscLen := int(data[0])
+	if len(data) < 1+smscLen {
+		return "", nil, fmt.Errorf("SMSC field truncated")
+	}
+	smsc := ""
+	if smscLen > 1 {
+		// An odd digit count is padded with F, which semiOctetDigits skips
+		digits := semiOctetDigits(data[2:1+smscLen], (smscLen-1)*2)
+		if data[1]&typeOfNumberMask == typeOfNumberIntl {
+			digits = "+" + digits
+		}
+		smsc = digits
+	}
+	return smsc, data[1+smscLen:], nil
+}
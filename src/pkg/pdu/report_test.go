@@ -0,0 +1,93 @@
+package pdu
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeStatusReport(t *testing.T) {
+	// SMSC +447802000332, MR 46, recipient +84912345678, received
+	// 2026-10-16 17:00:00 +07:00, discharged 5 seconds later, status 00
+	report, err := DecodeStatusReport("0791448720003023062E0B914819325476F8620161710000826201617100508200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zone := time.FixedZone("", 7*3600)
+	if report.SMSC != "+447802000332" || report.Reference != 46 || report.Recipient != "+84912345678" {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if want := time.Date(2026, 10, 16, 17, 0, 0, 0, zone); !report.ServiceCentreTime.Equal(want) {
+		t.Errorf("service centre time = %v, want %v", report.ServiceCentreTime, want)
+	}
+	if want := time.Date(2026, 10, 16, 17, 0, 5, 0, zone); !report.DischargeTime.Equal(want) {
+		t.Errorf("discharge time = %v, want %v", report.DischargeTime, want)
+	}
+	if report.State() != ReportDelivered {
+		t.Errorf("state = %s", report.State())
+	}
+
+	if _, err := DecodeStatusReport("0011000B914819325476F80000A70568656C6C6F"); err == nil {
+		t.Error("expected error for an SMS-SUBMIT")
+	}
+	if _, err := DecodeStatusReport("00062E0B9148"); err == nil {
+		t.Error("expected error for a truncated report")
+	}
+}
+
+func TestStatusReportRoundTrip(t *testing.T) {
+	zone := time.FixedZone("", -3*3600-30*60)
+	report := &StatusReport{
+		Reference:         200,
+		Recipient:         "0912345678",
+		ServiceCentreTime: time.Date(2026, 1, 2, 3, 4, 5, 0, zone),
+		DischargeTime:     time.Date(2026, 1, 2, 3, 5, 0, 0, zone),
+		Status:            statusValidityExpired,
+	}
+	encoded, err := report.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeStatusReport(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Reference != 200 || decoded.Recipient != "0912345678" || decoded.State() != ReportExpired {
+		t.Errorf("unexpected round trip %+v", decoded)
+	}
+	if !decoded.DischargeTime.Equal(report.DischargeTime) {
+		t.Errorf("discharge time = %v, want %v", decoded.DischargeTime, report.DischargeTime)
+	}
+	if _, offset := decoded.DischargeTime.Zone(); offset != -3*3600-30*60 {
+		t.Errorf("zone offset = %d", offset)
+	}
+}
+
+func TestStatusReportState(t *testing.T) {
+	tests := []struct {
+		status byte
+		state  string
+	}{
+		{0x00, ReportDelivered},
+		{0x01, ReportDelivered},
+		{0x20, ReportPending},
+		{0x30, ReportPending},
+		{0x41, ReportFailed},
+		{0x46, ReportExpired},
+		{0x62, ReportFailed},
+	}
+	for _, tt := range tests {
+		if got := (&StatusReport{Status: tt.status}).State(); got != tt.state {
+			t.Errorf("status %02X: state %s, want %s", tt.status, got, tt.state)
+		}
+	}
+}
+
+func TestDecodeAlphanumericAddress(t *testing.T) {
+	septets, _ := EncodeGSM7("Viettel")
+	data := append([]byte{13, TypeAlphanumeric}, PackSeptets(septets, 0)...)
+	address, n, err := DecodeAddress(data)
+	if err != nil || address != "Viettel" || n != len(data) {
+		t.Errorf("DecodeAddress = %q, %d, %v", address, n, err)
+	}
+}
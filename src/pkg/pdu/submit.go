@@ -0,0 +1,192 @@
+package pdu
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Data coding schemes
+const (
+	DCSGSM7 = 0x00
+	DCSUCS2 = 0x08
+)
+
+// SMS-SUBMIT first octet bits
+const (
+	mtiSubmit = 0x01
+	srr       = 0x20
+	udhi      = 0x40
+)
+
+// MaxGSM7Septets is the user data capacity of a single GSM 7-bit message
+const MaxGSM7Septets = 160
+
+// Submit is an outgoing SMS-SUBMIT message
+type Submit struct {
+	// SMSC is the service centre number; empty lets the modem use its default
+	SMSC string
+	// Destination is the recipient number; a leading + marks it international
+	Destination string
+	// Text is the message body, sent as GSM 7-bit when possible and as
+	// UCS2 otherwise. With Encoding8Bit it holds the data as hex.
+	Text string
+	// Encoding forces EncodingGSM7, EncodingUCS2 or Encoding8Bit; empty
+	// selects GSM 7-bit or UCS2 from the text
+	Encoding string
+	// Class sets the message class, e.g. Class0 for a flash message
+	Class MessageClass
+	// ValidityPeriod is how long the SMSC keeps trying; zero omits the field
+	ValidityPeriod time.Duration
+	// ValidUntil is an absolute expiry, used instead of ValidityPeriod when set
+	ValidUntil time.Time
+	// Reference is the TP-MR value, normally left 0 for the modem to assign
+	Reference byte
+	// Header holds user data header elements such as ConcatElement
+	Header []InformationElement
+	// StatusReport asks the SMSC for a delivery report (TP-SRR)
+	StatusReport bool
+}
+
+// Encode returns the PDU as hex, prefixed with the SMSC field, empty unless
+// SMSC is set, and the TPDU length to pass to AT+CMGS, which excludes the
+// SMSC field
+func (s *Submit) Encode() (string, int, error) {
+	header := encodeHeader(s.Header)
+	encoding, udl, ud, err := encodeUserData(s.Text, s.Encoding, header)
+	if err != nil {
+		return "", 0, err
+	}
+
+	address, err := EncodeAddress(s.Destination)
+	if err != nil {
+		return "", 0, err
+	}
+	smsc, err := encodeSMSC(s.SMSC)
+	if err != nil {
+		return "", 0, err
+	}
+
+	firstOctet := byte(mtiSubmit)
+	switch {
+	case !s.ValidUntil.IsZero():
+		firstOctet |= vpfAbsolute
+	case s.ValidityPeriod > 0:
+		firstOctet |= vpfRelative
+	}
+	if header != nil {
+		firstOctet |= udhi
+	}
+	if s.StatusReport {
+		firstOctet |= srr
+	}
+
+	tpdu := []byte{firstOctet, s.Reference}
+	tpdu = append(tpdu, address...)
+	tpdu = append(tpdu, 0x00, EncodeDCS(encoding, s.Class)) // TP-PID, TP-DCS
+	switch {
+	case !s.ValidUntil.IsZero():
+		tpdu = append(tpdu, EncodeTimestamp(s.ValidUntil)...)
+	case s.ValidityPeriod > 0:
+		tpdu = append(tpdu, RelativeValidity(s.ValidityPeriod))
+	}
+	tpdu = append(tpdu, udl)
+	tpdu = append(tpdu, ud...)
+
+	return strings.ToUpper(hex.EncodeToString(append(smsc, tpdu...))), len(tpdu), nil
+}
+
+// DecodeSubmit decodes an SMS-SUBMIT given as hex with its leading SMSC
+// field, e.g. one captured from AT+CMGS or listed from the outbox. The
+// decoded Encoding is always set.
+func DecodeSubmit(pduHex string) (*Submit, error) {
+	smsc, tpdu, err := splitSMSC(pduHex)
+	if err != nil {
+		return nil, err
+	}
+	if len(tpdu) < 2 {
+		return nil, fmt.Errorf("message truncated")
+	}
+	firstOctet := tpdu[0]
+	if firstOctet&mtiMask != mtiSubmit {
+		return nil, fmt.Errorf("not an SMS-SUBMIT (first octet %02X)", firstOctet)
+	}
+
+	msg := &Submit{SMSC: smsc, Reference: tpdu[1], StatusReport: firstOctet&srr != 0}
+	pos := 2
+	destination, n, err := DecodeAddress(tpdu[pos:])
+	if err != nil {
+		return nil, fmt.Errorf("destination: %w", err)
+	}
+	msg.Destination = destination
+	pos += n
+
+	// TP-PID, TP-DCS
+	if len(tpdu) < pos+2 {
+		return nil, fmt.Errorf("message truncated")
+	}
+	coding := DecodeDCS(tpdu[pos+1])
+	msg.Encoding, msg.Class = coding.Encoding, coding.Class
+	pos += 2
+
+	msg.ValidityPeriod, msg.ValidUntil, n, err = decodeValidity(firstOctet&vpfMask, tpdu[pos:])
+	if err != nil {
+		return nil, err
+	}
+	pos += n
+
+	if len(tpdu) < pos+1 {
+		return nil, fmt.Errorf("message truncated")
+	}
+	udl := int(tpdu[pos])
+	msg.Header, msg.Text, err = decodeUserData(msg.Encoding, firstOctet&udhi != 0, udl, tpdu[pos+1:])
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// encodeUserData returns the encoding used, user data length and user data
+// for text behind an optional header. UDL counts septets, header included,
+// for GSM 7-bit and octets otherwise. An empty encoding selects GSM 7-bit
+// or UCS2 from the text.
+func encodeUserData(text, encoding string, header []byte) (string, byte, []byte, error) {
+	if encoding == "" {
+		encoding = SelectEncoding(text)
+	}
+
+	switch encoding {
+	case EncodingGSM7:
+		septets, err := EncodeGSM7(text)
+		if err != nil {
+			return "", 0, nil, err
+		}
+		// Pad the header to a septet boundary
+		headerBits := len(header) * 8
+		fill := (7 - headerBits%7) % 7
+		total := (headerBits+fill)/7 + len(septets)
+		if total > MaxGSM7Septets {
+			return "", 0, nil, fmt.Errorf("message needs %d septets, maximum is %d", total, MaxGSM7Septets)
+		}
+		ud := append(append([]byte{}, header...), PackSeptets(septets, fill)...)
+		return encoding, byte(total), ud, nil
+	case EncodingUCS2:
+		ud := append(append([]byte{}, header...), EncodeUCS2(text)...)
+		if len(ud) > maxUserData {
+			return "", 0, nil, fmt.Errorf("message needs %d UCS2 characters, maximum is %d", (len(ud)-len(header))/2, (maxUserData-len(header))/2)
+		}
+		return encoding, byte(len(ud)), ud, nil
+	case Encoding8Bit:
+		data, err := hex.DecodeString(text)
+		if err != nil {
+			return "", 0, nil, fmt.Errorf("8-bit data must be hex: %w", err)
+		}
+		ud := append(append([]byte{}, header...), data...)
+		if len(ud) > maxUserData {
+			return "", 0, nil, fmt.Errorf("message needs %d octets, maximum is %d", len(data), maxUserData-len(header))
+		}
+		return encoding, byte(len(ud)), ud, nil
+	}
+	return "", 0, nil, fmt.Errorf("unknown encoding %q", encoding)
+}
@@ -0,0 +1,63 @@
+package pdu
+
+import (
+	"fmt"
+	"time"
+)
+
+// timestampLen is the size of a service centre time stamp
+const timestampLen = 7
+
+// DecodeTimestamp decodes a 7-octet service centre time stamp: year, month,
+// day, hour, minute and second as swapped BCD, then the offset from UTC in
+// quarter hours with the sign in bit 3
+func DecodeTimestamp(data []byte) (time.Time, error) {
+	if len(data) < timestampLen {
+		return time.Time{}, fmt.Errorf("time stamp truncated")
+	}
+
+	var fields [6]int
+	for i := range fields {
+		fields[i] = swappedBCD(data[i])
+	}
+
+	tz := data[6]
+	quarters := int(tz&0x07)*10 + int(tz>>4)
+	offset := quarters * 15 * 60
+	if tz&0x08 != 0 {
+		offset = -offset
+	}
+
+	loc := time.FixedZone("", offset)
+	return time.Date(2000+fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, loc), nil
+}
+
+// EncodeTimestamp encodes t as a service centre time stamp in t's zone
+func EncodeTimestamp(t time.Time) []byte {
+	_, offset := t.Zone()
+	var sign byte
+	if offset < 0 {
+		sign = 0x08
+		offset = -offset
+	}
+	quarters := offset / (15 * 60)
+
+	return []byte{
+		toSwappedBCD(t.Year() % 100),
+		toSwappedBCD(int(t.Month())),
+		toSwappedBCD(t.Day()),
+		toSwappedBCD(t.Hour()),
+		toSwappedBCD(t.Minute()),
+		toSwappedBCD(t.Second()),
+		toSwappedBCD(quarters) | sign,
+	}
+}
+
+// swappedBCD decodes an octet holding two decimal digits, low nibble first
+func swappedBCD(b byte) int {
+	return int(b&0x0F)*10 + int(b>>4)
+}
+
+func toSwappedBCD(v int) byte {
+	return byte(v%10)<<4 | byte(v/10)
+}
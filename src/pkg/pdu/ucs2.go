@@ -0,0 +1,63 @@
+package pdu
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+// Message encodings
+const (
+	EncodingGSM7 = "gsm7"
+	EncodingUCS2 = "ucs2"
+	Encoding8Bit = "8bit" // binary data, only received
+)
+
+// MaxUCS2Chars is the user data capacity of a single UCS2 message
+const MaxUCS2Chars = 70
+
+// SelectEncoding returns EncodingGSM7 when every character of text is in
+// the GSM alphabet and EncodingUCS2 otherwise, e.g. for Vietnamese
+// diacritics
+func SelectEncoding(text string) string {
+	if IsGSM7(text) {
+		return EncodingGSM7
+	}
+	return EncodingUCS2
+}
+
+// MessageLength returns the length of text in units of its encoding:
+// septets for GSM 7-bit, UTF-16 code units for UCS2
+func MessageLength(text string) (int, string) {
+	if n, ok := SeptetLen(text); ok {
+		return n, EncodingGSM7
+	}
+	return len(utf16.Encode([]rune(text))), EncodingUCS2
+}
+
+// MaxLength returns the single-message capacity of an encoding
+func MaxLength(encoding string) int {
+	if encoding == EncodingUCS2 {
+		return MaxUCS2Chars
+	}
+	return MaxGSM7Septets
+}
+
+// EncodeUCS2 converts s to big-endian UTF-16. Characters outside the BMP
+// become surrogate pairs, which handsets display correctly.
+func EncodeUCS2(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	out := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.BigEndian.PutUint16(out[i*2:], u)
+	}
+	return out
+}
+
+// DecodeUCS2 converts big-endian UTF-16 to text; a trailing odd byte is ignored
+func DecodeUCS2(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
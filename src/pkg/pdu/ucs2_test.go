@@ -0,0 +1,60 @@
+package pdu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectEncoding(t *testing.T) {
+	tests := []struct {
+		text     string
+		encoding string
+		length   int
+	}{
+		{"Xin chao", EncodingGSM7, 8},
+		{"Giá 100€", EncodingUCS2, 8},
+		{"Tiếng Việt", EncodingUCS2, 10},
+		{"OK 👍", EncodingUCS2, 5},
+	}
+
+	for _, tt := range tests {
+		if got := SelectEncoding(tt.text); got != tt.encoding {
+			t.Errorf("SelectEncoding(%q) = %s, want %s", tt.text, got, tt.encoding)
+		}
+		if n, encoding := MessageLength(tt.text); n != tt.length || encoding != tt.encoding {
+			t.Errorf("MessageLength(%q) = %d, %s; want %d, %s", tt.text, n, encoding, tt.length, tt.encoding)
+		}
+	}
+}
+
+func TestUCS2RoundTrip(t *testing.T) {
+	for _, text := range []string{"Tiếng Việt có dấu", "Đặt hàng thành công 👍"} {
+		if got := DecodeUCS2(EncodeUCS2(text)); got != text {
+			t.Errorf("round trip of %q gave %q", text, got)
+		}
+	}
+}
+
+func TestEncodeSubmitUCS2(t *testing.T) {
+	submit := Submit{Destination: "0912345678", Text: "Tiếng Việt"}
+	pdu, length, err := submit.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tiếng Việt: 10 UTF-16 units, UDL 0x14 octets, DCS 08
+	want := "0001000A819021436587000814005400691EBF006E00670020005600691EC70074"
+	if pdu != want {
+		t.Errorf("pdu = %s, want %s", pdu, want)
+	}
+	if length != len(want)/2-1 {
+		t.Errorf("length = %d, want %d", length, len(want)/2-1)
+	}
+
+	if _, _, err := (&Submit{Destination: "0912345678", Text: strings.Repeat("ệ", 71)}).Encode(); err == nil {
+		t.Error("expected error for 71 UCS2 characters")
+	}
+	if _, _, err := (&Submit{Destination: "0912345678", Text: strings.Repeat("ệ", 70)}).Encode(); err != nil {
+		t.Errorf("70 UCS2 characters must fit: %v", err)
+	}
+}
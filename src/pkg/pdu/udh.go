@@ -0,0 +1,136 @@
+package pdu
+
+import "fmt"
+
+// Information element identifiers
+const (
+	IEIConcat8  = 0x00 // concatenated message, 8-bit reference
+	IEIConcat16 = 0x08 // concatenated message, 16-bit reference
+)
+
+// maxUserData is the user data capacity of one message in octets
+const maxUserData = 140
+
+// InformationElement is one element of a user data header
+type InformationElement struct {
+	ID   byte
+	Data []byte
+}
+
+// ConcatElement returns the element marking part seq (1-based) of total
+// parts sharing ref. wide selects the 16-bit reference form.
+func ConcatElement(ref uint16, total, seq int, wide bool) InformationElement {
+	if wide {
+		return InformationElement{ID: IEIConcat16, Data: []byte{byte(ref >> 8), byte(ref), byte(total), byte(seq)}}
+	}
+	return InformationElement{ID: IEIConcat8, Data: []byte{byte(ref), byte(total), byte(seq)}}
+}
+
+// FindConcat returns the reference, part count and 1-based part number of
+// the concatenation element among elements, if there is one
+func FindConcat(elements []InformationElement) (ref, total, seq int, ok bool) {
+	for _, ie := range elements {
+		switch {
+		case ie.ID == IEIConcat8 && len(ie.Data) == 3:
+			return int(ie.Data[0]), int(ie.Data[1]), int(ie.Data[2]), true
+		case ie.ID == IEIConcat16 && len(ie.Data) == 4:
+			return int(ie.Data[0])<<8 | int(ie.Data[1]), int(ie.Data[2]), int(ie.Data[3]), true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// encodeHeader returns the user data header including its length octet,
+// or nil when there are no elements
+func encodeHeader(elements []InformationElement) []byte {
+	if len(elements) == 0 {
+		return nil
+	}
+	header := []byte{0}
+	for _, ie := range elements {
+		header = append(header, ie.ID, byte(len(ie.Data)))
+		header = append(header, ie.Data...)
+	}
+	header[0] = byte(len(header) - 1)
+	return header
+}
+
+// concatHeaderLen is the header size, length octet included, of a
+// concatenated part
+func concatHeaderLen(wide bool) int {
+	if wide {
+		return 7
+	}
+	return 6
+}
+
+// PartCapacity returns how many characters fit in one part of a
+// concatenated message: 153 GSM 7-bit septets or 67 UCS2 characters with
+// an 8-bit reference, one septet or character less with a 16-bit one
+func PartCapacity(encoding string, wide bool) int {
+	free := maxUserData - concatHeaderLen(wide)
+	if encoding == EncodingUCS2 {
+		return free / 2
+	}
+	return free * 8 / 7
+}
+
+// Split cuts text into the parts of a concatenated message. Text that fits
+// in a single message is returned as one part. Escape sequences and UTF-16
+// surrogate pairs are never split across parts.
+func Split(text string, wide bool) ([]string, string) {
+	length, encoding := MessageLength(text)
+	if length <= MaxLength(encoding) {
+		return []string{text}, encoding
+	}
+
+	capacity := PartCapacity(encoding, wide)
+	var parts []string
+	start, used := 0, 0
+	for i, r := range text {
+		size := runeSize(r, encoding)
+		if used+size > capacity {
+			parts = append(parts, text[start:i])
+			start, used = i, 0
+		}
+		used += size
+	}
+	parts = append(parts, text[start:])
+	return parts, encoding
+}
+
+// runeSize returns the units r takes in encoding
+func runeSize(r rune, encoding string) int {
+	if encoding == EncodingUCS2 {
+		if r > 0xFFFF {
+			return 2 // surrogate pair
+		}
+		return 1
+	}
+	if _, ok := basicIndex[r]; ok {
+		return 1
+	}
+	return 2
+}
+
+// SplitSubmit splits a message into SMS-SUBMITs carrying a concatenation
+// header with reference ref. A message that fits in one SMS is returned as a
+// single submit without header.
+func SplitSubmit(base Submit, ref uint16, wide bool) ([]Submit, error) {
+	parts, _ := Split(base.Text, wide)
+	if len(parts) > 255 {
+		return nil, fmt.Errorf("message needs %d parts, maximum is 255", len(parts))
+	}
+	if len(parts) == 1 {
+		return []Submit{base}, nil
+	}
+
+	submits := make([]Submit, len(parts))
+	for i, part := range parts {
+		submit := base
+		submit.Text = part
+		submit.Header = append([]InformationElement{ConcatElement(ref, len(parts), i+1, wide)}, base.Header...)
+		submits[i] = submit
+	}
+	return submits, nil
+}
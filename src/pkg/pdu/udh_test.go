@@ -0,0 +1,128 @@
+package pdu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		wide     bool
+		lengths  []int
+		encoding string
+	}{
+		{"single gsm", strings.Repeat("a", 160), false, []int{160}, EncodingGSM7},
+		{"two gsm parts", strings.Repeat("a", 161), false, []int{153, 8}, EncodingGSM7},
+		{"16-bit reference", strings.Repeat("a", 161), true, []int{152, 9}, EncodingGSM7},
+		{"single ucs2", strings.Repeat("ệ", 70), false, []int{70}, EncodingUCS2},
+		{"two ucs2 parts", strings.Repeat("ệ", 71), false, []int{67, 4}, EncodingUCS2},
+		{"three ucs2 parts", strings.Repeat("ệ", 140), true, []int{66, 66, 8}, EncodingUCS2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, encoding := Split(tt.text, tt.wide)
+			if encoding != tt.encoding {
+				t.Errorf("encoding = %s, want %s", encoding, tt.encoding)
+			}
+			if len(parts) != len(tt.lengths) {
+				t.Fatalf("got %d parts, want %d", len(parts), len(tt.lengths))
+			}
+			for i, part := range parts {
+				if n, _ := MessageLength(part); n != tt.lengths[i] {
+					t.Errorf("part %d has length %d, want %d", i+1, n, tt.lengths[i])
+				}
+			}
+			if strings.Join(parts, "") != tt.text {
+				t.Error("parts do not add up to the text")
+			}
+		})
+	}
+}
+
+func TestSplitKeepsEscapeAndSurrogates(t *testing.T) {
+	parts, _ := Split(strings.Repeat("a", 152)+"€"+strings.Repeat("b", 10), false)
+	if len(parts) != 2 || parts[0] != strings.Repeat("a", 152) || !strings.HasPrefix(parts[1], "€") {
+		t.Errorf("escape sequence split across parts: %q", parts)
+	}
+
+	parts, _ = Split(strings.Repeat("ệ", 66)+"👍"+strings.Repeat("ệ", 10), false)
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], "👍") {
+		t.Errorf("surrogate pair split across parts: %q", parts)
+	}
+}
+
+func TestEncodeConcatenatedPart(t *testing.T) {
+	submit := Submit{
+		Destination: "+84912345678",
+		Text:        "hello",
+		Header:      []InformationElement{ConcatElement(0xCC, 2, 1, false)},
+	}
+	pdu, _, err := submit.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// UDHI set, UDL 12 septets: 7 for the 6-octet header plus fill bit, 5 for the text
+	want := "0041000B914819325476F800000C" + "050003CC0201" + "D06536FB0D"
+	if pdu != want {
+		t.Errorf("pdu = %s, want %s", pdu, want)
+	}
+
+	submit.Header = []InformationElement{ConcatElement(0x1234, 2, 1, true)}
+	submit.Text = strings.Repeat("ệ", 66)
+	pdu, _, err = submit.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(pdu, "0041000B914819325476F800088B"+"06080412340201") {
+		t.Errorf("unexpected 16-bit reference part %s", pdu)
+	}
+
+	submit.Text = strings.Repeat("ệ", 67)
+	if _, _, err := submit.Encode(); err == nil {
+		t.Error("expected error for 67 UCS2 characters behind a 7-octet header")
+	}
+}
+
+func TestSplitSubmit(t *testing.T) {
+	submits, err := SplitSubmit(Submit{Destination: "0912345678", Text: strings.Repeat("x", 300)}, 7, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(submits) != 2 {
+		t.Fatalf("got %d parts, want 2", len(submits))
+	}
+	for i, submit := range submits {
+		ie := submit.Header[0]
+		if ie.ID != IEIConcat8 || ie.Data[0] != 7 || ie.Data[1] != 2 || int(ie.Data[2]) != i+1 {
+			t.Errorf("part %d has header %+v", i+1, ie)
+		}
+		if _, _, err := submit.Encode(); err != nil {
+			t.Errorf("part %d: %v", i+1, err)
+		}
+	}
+
+	single, err := SplitSubmit(Submit{Destination: "0912345678", Text: "short"}, 7, false)
+	if err != nil || len(single) != 1 || single[0].Header != nil {
+		t.Errorf("short message should stay a single submit without header: %+v, %v", single, err)
+	}
+}
+
+func TestFindConcat(t *testing.T) {
+	for _, wide := range []bool{false, true} {
+		elements := []InformationElement{{ID: 0x24, Data: []byte{1}}, ConcatElement(0x1234, 3, 2, wide)}
+		ref, total, seq, ok := FindConcat(elements)
+		want := 0x1234
+		if !wide {
+			want = 0x34
+		}
+		if !ok || ref != want || total != 3 || seq != 2 {
+			t.Errorf("wide=%v: FindConcat = %X, %d, %d, %v", wide, ref, total, seq, ok)
+		}
+	}
+	if _, _, _, ok := FindConcat([]InformationElement{{ID: IEIConcat8, Data: []byte{1, 2}}}); ok {
+		t.Error("malformed element accepted")
+	}
+}
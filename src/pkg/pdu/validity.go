@@ -0,0 +1,83 @@
+package pdu
+
+import (
+	"fmt"
+	"time"
+)
+
+// Validity period formats, TP-VPF in bits 3-4 of the first octet
+const (
+	vpfMask     = 0x18
+	vpfEnhanced = 0x08
+	vpfRelative = 0x10
+	vpfAbsolute = 0x18
+)
+
+// RelativeValidity converts a duration to a TP-VP relative octet, rounding
+// down to the nearest representable period
+func RelativeValidity(d time.Duration) byte {
+	minutes := int(d / time.Minute)
+	switch {
+	case minutes <= 5:
+		return 0
+	case minutes <= 12*60:
+		return byte(minutes/5 - 1)
+	case minutes <= 24*60:
+		return byte(143 + (minutes-12*60)/30)
+	case minutes <= 30*24*60:
+		return byte(166 + minutes/(24*60))
+	case minutes <= 63*7*24*60:
+		return byte(192 + minutes/(7*24*60))
+	default:
+		return 255
+	}
+}
+
+// RelativeDuration converts a TP-VP relative octet to the period it denotes
+func RelativeDuration(vp byte) time.Duration {
+	v := time.Duration(vp)
+	switch {
+	case vp <= 143:
+		return (v + 1) * 5 * time.Minute
+	case vp <= 167:
+		return 12*time.Hour + (v-143)*30*time.Minute
+	case vp <= 196:
+		return (v - 166) * 24 * time.Hour
+	default:
+		return (v - 192) * 7 * 24 * time.Hour
+	}
+}
+
+// decodeValidity decodes the TP-VP field of an SMS-SUBMIT in format vpf and
+// returns either a relative period or an absolute expiry, and the octets
+// consumed
+func decodeValidity(vpf byte, data []byte) (time.Duration, time.Time, int, error) {
+	switch vpf {
+	case vpfRelative:
+		if len(data) < 1 {
+			return 0, time.Time{}, 0, fmt.Errorf("validity period truncated")
+		}
+		return RelativeDuration(data[0]), time.Time{}, 1, nil
+	case vpfAbsolute:
+		expiry, err := DecodeTimestamp(data)
+		return 0, expiry, timestampLen, err
+	case vpfEnhanced:
+		if len(data) < 7 {
+			return 0, time.Time{}, 0, fmt.Errorf("validity period truncated")
+		}
+		// Bits 0-2 of the functionality indicator select the format
+		switch data[0] & 0x07 {
+		case 1:
+			return RelativeDuration(data[1]), time.Time{}, 7, nil
+		case 2:
+			return time.Duration(data[1]) * time.Second, time.Time{}, 7, nil
+		case 3:
+			d := time.Duration(swappedBCD(data[1]))*time.Hour +
+				time.Duration(swappedBCD(data[2]))*time.Minute +
+				time.Duration(swappedBCD(data[3]))*time.Second
+			return d, time.Time{}, 7, nil
+		}
+		return 0, time.Time{}, 7, nil
+	}
+	return 0, time.Time{}, 0, nil
+}
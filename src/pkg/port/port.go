@@ -0,0 +1,58 @@
+package port
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"sms-gateway/src/pkg/simulator"
+
+	serial "go.bug.st/serial"
+)
+
+// Port is a byte stream to a modem: a USB serial port or a simulated device
+type Port interface {
+	io.ReadWriteCloser
+}
+
+// ErrOpen wraps the error of a port that cannot be opened, such as an
+// unplugged dongle
+var ErrOpen = errors.New("failed to open port")
+
+// Open opens a modem port. Names starting with "sim://" connect to an
+// in-process simulated modem, anything else is opened as a serial port
+// with the usual 8N1 settings.
+func Open(name string, baudRate int) (Port, error) {
+	if simulator.IsSimulated(name) {
+		return simulator.Open(name)
+	}
+
+	mode := &serial.Mode{
+		BaudRate: baudRate,
+		Parity:   serial.NoParity,
+		DataBits: 8,
+		StopBits: serial.OneStopBit,
+	}
+
+	p, err := serial.Open(name, mode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOpen, err)
+	}
+	return p, nil
+}
+
+// List returns the serial ports present on the system followed by the
+// registered simulated modems
+func List() ([]string, error) {
+	ports, err := serial.GetPortsList()
+	if err != nil {
+		return nil, err
+	}
+	return append(ports, simulator.Names()...), nil
+}
+
+// IsModemCandidate reports whether a port is likely to be a USB modem
+func IsModemCandidate(name string) bool {
+	return simulator.IsSimulated(name) || strings.Contains(name, "ttyUSB")
+}
@@ -0,0 +1,274 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"sms-gateway/src/pkg/at"
+)
+
+// Device states
+const (
+	StateDisconnected = "disconnected"
+	StateReady        = "ready"
+	StateFailed       = "failed"
+)
+
+// Message formats selected with AT+CMGF
+const (
+	FormatUnknown = -1
+	FormatPDU     = 0
+	FormatText    = 1
+)
+
+// initCommands are sent once after the port has been opened
+var initCommands = []string{
+	"AT",        // modem alive
+	"ATE0",      // disable echo
+	"AT+CMEE=1", // numeric +CME/+CMS errors
+	"AT+CREG?",  // network registration
+}
+
+// Health is a snapshot of a device's connection state
+type Health struct {
+	Port        string
+	State       string
+	LastError   string
+	ConnectedAt time.Time
+	LastCheck   time.Time
+	Reconnects  int
+}
+
+// Device is a long-lived connection to one modem. The port is opened and
+// initialized on first use and again after the connection is lost.
+type Device struct {
+	port     string
+	baudRate int
+	manager  *Manager
+
+	lease *lease
+
+	// connecting is held while the port is opened and initialized, so that
+	// d.mu is never held during I/O and health reads do not wait on it
+	connecting chan struct{}
+
+	mu            sync.Mutex
+	session       *at.Session
+	messageFormat int
+	charset       string
+	health        Health
+}
+
+func newDevice(port string, baudRate int, manager *Manager) *Device {
+	return &Device{
+		port:          port,
+		baudRate:      baudRate,
+		manager:       manager,
+		lease:         newLease(),
+		connecting:    make(chan struct{}, 1),
+		messageFormat: FormatUnknown,
+		health:        Health{Port: port, State: StateDisconnected},
+	}
+}
+
+// Port returns the port name of the device
+func (d *Device) Port() string {
+	return d.port
+}
+
+// Session returns the initialized AT session, (re)connecting if needed
+func (d *Device) Session(ctx context.Context) (*at.Session, error) {
+	// Callers arriving during a connection wait for it, or for ctx
+	select {
+	case d.connecting <- struct{}{}:
+		defer func() { <-d.connecting }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	d.mu.Lock()
+	if d.session != nil {
+		select {
+		case <-d.session.Done():
+			log.Printf("Session [%s]: connection lost: %v", d.port, d.session.Err())
+			d.dropLocked(fmt.Errorf("connection lost: %v", d.session.Err()))
+		default:
+			sess := d.session
+			d.mu.Unlock()
+			return sess, nil
+		}
+	}
+	wasConnected := !d.health.ConnectedAt.IsZero()
+	d.mu.Unlock()
+
+	sess, err := d.connect(ctx)
+	if err != nil {
+		d.mu.Lock()
+		d.failLocked(err)
+		d.mu.Unlock()
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.session = sess
+	d.messageFormat = FormatUnknown
+	d.charset = ""
+	d.health.State = StateReady
+	d.health.LastError = ""
+	d.health.ConnectedAt = time.Now()
+	d.health.LastCheck = d.health.ConnectedAt
+	if wasConnected {
+		d.health.Reconnects++
+	}
+	d.mu.Unlock()
+	log.Printf("Session [%s]: modem initialized", d.port)
+
+	for _, fn := range d.manager.connectHooks() {
+		fn(d, sess)
+	}
+	return sess, nil
+}
+
+// connect opens the port and initializes the modem
+func (d *Device) connect(ctx context.Context) (*at.Session, error) {
+	sess, err := at.Open(d.port, d.baudRate)
+	if err != nil {
+		return nil, err
+	}
+	for _, cmd := range initCommands {
+		if _, err := sess.Command(ctx, cmd); err != nil {
+			sess.Close()
+			return nil, fmt.Errorf("modem initialization failed at %s: %w", cmd, err)
+		}
+	}
+	return sess, nil
+}
+
+// SetMessageFormat selects PDU or text mode, skipping AT+CMGF when the
+// modem is already in the requested mode
+func (d *Device) SetMessageFormat(ctx context.Context, format int) error {
+	sess, err := d.Session(ctx)
+	if err != nil {
+		return err
+	}
+	if d.MessageFormat() == format {
+		return nil
+	}
+	_, err = sess.Command(ctx, fmt.Sprintf("AT+CMGF=%d", format))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// A reconnection in the meantime has reset the format
+	if d.session != sess {
+		return err
+	}
+	if err != nil {
+		d.messageFormat = FormatUnknown
+		return err
+	}
+	d.messageFormat = format
+	return nil
+}
+
+// MessageFormat returns the last format set with SetMessageFormat
+func (d *Device) MessageFormat() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.messageFormat
+}
+
+// SetCharacterSet selects the TE character set with AT+CSCS, skipping the
+// command when the modem already uses it
+func (d *Device) SetCharacterSet(ctx context.Context, charset string) error {
+	sess, err := d.Session(ctx)
+	if err != nil {
+		return err
+	}
+	if d.CharacterSet() == charset {
+		return nil
+	}
+	_, err = sess.Command(ctx, fmt.Sprintf("AT+CSCS=\"%s\"", charset))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// A reconnection in the meantime has reset the character set
+	if d.session != sess {
+		return err
+	}
+	if err != nil {
+		d.charset = ""
+		return err
+	}
+	d.charset = charset
+	return nil
+}
+
+// CharacterSet returns the last character set set with SetCharacterSet,
+// "" until one has been selected on the current connection
+func (d *Device) CharacterSet() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.charset
+}
+
+// Check pings the modem and drops the connection when it does not answer,
+// so that the next use reconnects and re-initializes it. Callers hold the
+// lease; like every exchange with the modem, the ping runs without d.mu.
+func (d *Device) Check(ctx context.Context) error {
+	sess, err := d.Session(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = sess.Command(ctx, "AT")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		log.Printf("Session [%s]: health check failed: %v", d.port, err)
+		if d.session == sess {
+			d.dropLocked(err)
+		}
+		return err
+	}
+	d.health.LastCheck = time.Now()
+	return nil
+}
+
+// Health returns the current health snapshot
+func (d *Device) Health() Health {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.health
+}
+
+// Close closes the connection; the device reconnects on next use
+func (d *Device) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dropLocked(nil)
+}
+
+// dropLocked closes the session and records why. Callers must hold d.mu.
+func (d *Device) dropLocked(reason error) {
+	if d.session != nil {
+		d.session.Close()
+		d.session = nil
+	}
+	d.messageFormat = FormatUnknown
+	d.charset = ""
+	if reason != nil {
+		d.failLocked(reason)
+		return
+	}
+	d.health.State = StateDisconnected
+}
+
+// failLocked records a failure. Callers must hold d.mu.
+func (d *Device) failLocked(err error) {
+	d.health.State = StateFailed
+	d.health.LastError = err.Error()
+	d.health.LastCheck = time.Now()
+}
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBusy is returned by TryAcquire when another caller holds the port
+var ErrBusy = errors.New("port is busy")
+
+// Lease describes who currently holds a port
+type Lease struct {
+	Owner string
+	Since time.Time
+}
+
+// lease serializes access to one physical port. Different ports have
+// independent leases so modems can work in parallel.
+type lease struct {
+	slot chan struct{}
+
+	mu     sync.Mutex
+	holder *Lease
+}
+
+func newLease() *lease {
+	return &lease{slot: make(chan struct{}, 1)}
+}
+
+// BusyError reports the holder of a port that could not be acquired
+type BusyError struct {
+	Port  string
+	Lease Lease
+}
+
+// Error implements the error interface
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("%s is in use by %s since %s", e.Port, e.Lease.Owner, e.Lease.Since.Format(time.RFC3339))
+}
+
+// Unwrap lets errors.Is(err, ErrBusy) match
+func (e *BusyError) Unwrap() error {
+	return ErrBusy
+}
+
+// Acquire waits until the port is free, takes it on behalf of owner and
+// returns the function that releases it
+func (d *Device) Acquire(ctx context.Context, owner string) (func(), error) {
+	select {
+	case d.lease.slot <- struct{}{}:
+		return d.hold(owner), nil
+	case <-ctx.Done():
+		if holder, busy := d.Holder(); busy {
+			return nil, &BusyError{Port: d.port, Lease: holder}
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// TryAcquire takes the port only if it is free right now
+func (d *Device) TryAcquire(owner string) (func(), error) {
+	select {
+	case d.lease.slot <- struct{}{}:
+		return d.hold(owner), nil
+	default:
+		holder, _ := d.Holder()
+		return nil, &BusyError{Port: d.port, Lease: holder}
+	}
+}
+
+// Holder returns the current lease, if any
+func (d *Device) Holder() (Lease, bool) {
+	d.lease.mu.Lock()
+	defer d.lease.mu.Unlock()
+	if d.lease.holder == nil {
+		return Lease{}, false
+	}
+	return *d.lease.holder, true
+}
+
+// hold records owner as holder and returns the idempotent release function
+func (d *Device) hold(owner string) func() {
+	d.lease.mu.Lock()
+	d.lease.holder = &Lease{Owner: owner, Since: time.Now()}
+	d.lease.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.lease.mu.Lock()
+			d.lease.holder = nil
+			d.lease.mu.Unlock()
+			<-d.lease.slot
+		})
+	}
+}
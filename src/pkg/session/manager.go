@@ -0,0 +1,163 @@
+package session
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"sms-gateway/src/pkg/at"
+)
+
+// ConnectFunc is called each time a device has opened and initialized its
+// session, e.g. to enable message indications and subscribe to URCs. It runs
+// while the device is locked, so it must use sess directly rather than
+// Device methods, and hand longer work to a goroutine.
+type ConnectFunc func(d *Device, sess *at.Session)
+
+// Manager keeps one long-lived Device per modem port
+type Manager struct {
+	baudRate      int
+	checkInterval time.Duration
+
+	mu        sync.Mutex
+	devices   map[string]*Device
+	onConnect []ConnectFunc
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewManager creates a manager. baudRate is used for ports opened without
+// an explicit rate; checkInterval is the period of the health check loop.
+func NewManager(baudRate int, checkInterval time.Duration) *Manager {
+	return &Manager{
+		baudRate:      baudRate,
+		checkInterval: checkInterval,
+		devices:       make(map[string]*Device),
+		stop:          make(chan struct{}),
+	}
+}
+
+// Device returns the device for a port, registering it on first use.
+// The port is opened lazily by Device.Session.
+func (m *Manager) Device(port string, baudRate int) *Device {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if d, ok := m.devices[port]; ok {
+		return d
+	}
+	if baudRate == 0 {
+		baudRate = m.baudRate
+	}
+	d := newDevice(port, baudRate, m)
+	m.devices[port] = d
+	return d
+}
+
+// OnConnect registers fn to run after every (re)connection of any device
+func (m *Manager) OnConnect(fn ConnectFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onConnect = append(m.onConnect, fn)
+}
+
+// connectHooks returns the registered ConnectFuncs
+func (m *Manager) connectHooks() []ConnectFunc {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ConnectFunc(nil), m.onConnect...)
+}
+
+// Lookup returns the device for a port if it is already managed
+func (m *Manager) Lookup(port string) (*Device, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.devices[port]
+	return d, ok
+}
+
+// Devices returns all managed devices ordered by port name
+func (m *Manager) Devices() []*Device {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	devices := make([]*Device, 0, len(m.devices))
+	for _, d := range m.devices {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].port < devices[j].port
+	})
+	return devices
+}
+
+// Health returns the health of every managed device
+func (m *Manager) Health() []Health {
+	var health []Health
+	for _, d := range m.Devices() {
+		health = append(health, d.Health())
+	}
+	return health
+}
+
+// Start registers the given ports and runs the health check loop, which
+// opens them right away and reconnects modems that went away
+func (m *Manager) Start(ports []string) {
+	for _, port := range ports {
+		m.Device(port, 0)
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.checkAll()
+		if m.checkInterval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(m.checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.checkAll()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// checkAll pings every managed device. A device in use is skipped: its
+// holder is talking to the modem already, and a ping queued behind a long
+// send would time out and tear down a healthy session.
+func (m *Manager) checkAll() {
+	for _, d := range m.Devices() {
+		release, err := d.TryAcquire("health check")
+		if err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := d.Check(ctx); err != nil {
+			log.Printf("Session [%s]: not ready: %v", d.port, err)
+		}
+		cancel()
+		release()
+	}
+}
+
+// Close stops the health check loop and closes every device
+func (m *Manager) Close() {
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+	m.wg.Wait()
+
+	for _, d := range m.Devices() {
+		d.Close()
+	}
+}
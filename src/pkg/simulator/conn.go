@@ -0,0 +1,576 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sms-gateway/src/pkg/pdu"
+)
+
+const (
+	ctrlZ = 0x1A
+	esc   = 0x1B
+)
+
+// Conn is a host-side connection to a simulated modem. It implements
+// io.ReadWriteCloser like a serial port.
+type Conn struct {
+	modem *Modem
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	out    bytes.Buffer
+	closed bool
+
+	line    []byte
+	prompt  string // pending AT+CMGS command while the "> " prompt is open
+	payload []byte
+}
+
+func newConn(m *Modem) *Conn {
+	c := &Conn{modem: m}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Read returns modem output, blocking until some is available
+func (c *Conn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.out.Len() == 0 && !c.closed {
+		c.cond.Wait()
+	}
+	if c.out.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.out.Read(p)
+}
+
+// Write feeds host input to the modem
+func (c *Conn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	c.mu.Unlock()
+
+	for _, b := range p {
+		c.input(b)
+	}
+	return len(p), nil
+}
+
+// Close disconnects from the modem
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	m := c.modem
+	m.mu.Lock()
+	for i, other := range m.conns {
+		if other == c {
+			m.conns = append(m.conns[:i], m.conns[i+1:]...)
+			break
+		}
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// emit queues modem output for the host
+func (c *Conn) emit(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.out.WriteString(s)
+	c.cond.Broadcast()
+}
+
+// emitLater queues modem output after a delay, like a network round trip
+func (c *Conn) emitLater(delay time.Duration, s string) {
+	if delay <= 0 {
+		c.emit(s)
+		return
+	}
+	time.AfterFunc(delay, func() { c.emit(s) })
+}
+
+func (c *Conn) input(b byte) {
+	if c.prompt != "" {
+		switch b {
+		case ctrlZ:
+			command, payload := c.prompt, string(c.payload)
+			c.prompt, c.payload = "", nil
+			c.submit(command, payload)
+		case esc:
+			c.prompt, c.payload = "", nil
+			c.emit("\r\nOK\r\n")
+		default:
+			c.payload = append(c.payload, b)
+		}
+		return
+	}
+
+	switch b {
+	case '\r':
+		line := strings.TrimSpace(string(c.line))
+		c.line = c.line[:0]
+		if line != "" {
+			c.command(line)
+		}
+	case '\n', esc:
+		// ESC outside a prompt is ignored, as modems do
+	default:
+		c.line = append(c.line, b)
+	}
+}
+
+// command answers a single command line
+func (c *Conn) command(line string) {
+	m := c.modem
+	m.mu.Lock()
+	echo := m.echo
+	failure, failed := m.takeFailure(line)
+	m.mu.Unlock()
+
+	if echo {
+		c.emit(line + "\r")
+	}
+	if failed {
+		c.emit("\r\n" + failure + "\r\n")
+		return
+	}
+
+	upper := strings.ToUpper(line)
+	switch {
+	case upper == "AT":
+		c.ok()
+	case upper == "ATE0" || upper == "ATE1":
+		m.mu.Lock()
+		m.echo = upper == "ATE1"
+		m.mu.Unlock()
+		c.ok()
+	case upper == "AT+CGMI":
+		c.reply(m.Manufacturer)
+	case upper == "AT+CGMM":
+		c.reply(m.Model)
+	case upper == "AT+CGMR":
+		c.reply(m.Revision)
+	case upper == "AT+CGSN":
+		c.reply(m.IMEI)
+	case upper == "AT+CIMI":
+		c.reply(m.IMSI)
+	case upper == "AT+CCID" || upper == "AT+ICCID":
+		c.reply("+CCID: " + m.ICCID)
+	case upper == "AT+CPIN?":
+		c.reply("+CPIN: READY")
+	case upper == "AT+COPS?":
+		c.reply(fmt.Sprintf("+COPS: 0,0,\"%s\",7", m.Operator))
+	case upper == "AT+CREG?":
+		c.reply(fmt.Sprintf("+CREG: 0,%d", m.Registration))
+	case upper == "AT+CSQ":
+		c.reply(fmt.Sprintf("+CSQ: %d,99", m.Signal))
+	case upper == "AT+CNUM":
+		if m.MSISDN == "" {
+			c.ok()
+			return
+		}
+		c.reply(fmt.Sprintf("+CNUM: ,\"%s\",145", m.MSISDN))
+	case upper == "AT+CMGF?":
+		m.mu.Lock()
+		mode := 0
+		if m.textMode {
+			mode = 1
+		}
+		m.mu.Unlock()
+		c.reply(fmt.Sprintf("+CMGF: %d", mode))
+	case upper == "AT+CMGF=0" || upper == "AT+CMGF=1":
+		m.mu.Lock()
+		m.textMode = upper == "AT+CMGF=1"
+		m.mu.Unlock()
+		c.ok()
+	case strings.HasPrefix(upper, "AT+CMGS="):
+		c.prompt = line
+		c.emit("\r\n> ")
+	case strings.HasPrefix(upper, "AT+CMGL"):
+		c.listMessages(upper)
+	case strings.HasPrefix(upper, "AT+CMGR="):
+		c.readMessage(strings.TrimPrefix(upper, "AT+CMGR="))
+	case strings.HasPrefix(upper, "AT+CMGD="):
+		c.deleteMessage(strings.TrimPrefix(upper, "AT+CMGD="))
+	case strings.HasPrefix(upper, "AT+CUSD="):
+		c.ussd(line)
+	case upper == "AT+CSCS?":
+		m.mu.Lock()
+		charset := m.charset
+		m.mu.Unlock()
+		c.reply(fmt.Sprintf("+CSCS: \"%s\"", charset))
+	case strings.HasPrefix(upper, "AT+CSCS="):
+		m.mu.Lock()
+		m.charset = strings.Trim(upper[len("AT+CSCS="):], "\"")
+		m.mu.Unlock()
+		c.ok()
+	case strings.HasPrefix(upper, "AT+CNMI="):
+		m.mu.Lock()
+		m.setIndications(upper[len("AT+CNMI="):])
+		m.mu.Unlock()
+		c.ok()
+	case strings.HasPrefix(upper, "AT+CSMP="):
+		m.mu.Lock()
+		params := strings.Split(upper[len("AT+CSMP="):], ",")
+		if fo, err := strconv.Atoi(params[0]); err == nil {
+			m.textFO = fo
+		}
+		if len(params) > 3 {
+			if dcs, err := strconv.Atoi(params[3]); err == nil {
+				m.textDCS = dcs
+			}
+		}
+		m.mu.Unlock()
+		c.ok()
+	case upper == "AT+CPMS?":
+		m.mu.Lock()
+		memory := m.memory
+		m.mu.Unlock()
+		c.reply(fmt.Sprintf("+CPMS: \"%s\",0,30,\"SM\",0,30,\"SM\",0,30", memory))
+	case strings.HasPrefix(upper, "AT+CPMS="):
+		m.mu.Lock()
+		m.memory = strings.Trim(strings.Split(upper[len("AT+CPMS="):], ",")[0], "\"")
+		m.mu.Unlock()
+		c.reply("+CPMS: 0,30,0,30,0,30")
+	case upper == "AT+CPBS?":
+		m.mu.Lock()
+		phonebook, used := m.phonebook, 0
+		if phonebook == "ON" {
+			used = len(m.OwnNumbers)
+		}
+		m.mu.Unlock()
+		c.reply(fmt.Sprintf("+CPBS: \"%s\",%d,%d", phonebook, used, ownNumberSlots))
+	case strings.HasPrefix(upper, "AT+CPBS="):
+		m.mu.Lock()
+		m.phonebook = strings.Trim(upper[len("AT+CPBS="):], "\"")
+		m.mu.Unlock()
+		c.ok()
+	case upper == "AT+CPBR=?":
+		c.reply(fmt.Sprintf("+CPBR: (1-%d),40,16", ownNumberSlots))
+	case strings.HasPrefix(upper, "AT+CPBR="):
+		c.readPhonebook(upper[len("AT+CPBR="):])
+	case strings.HasPrefix(upper, "AT+CMEE="),
+		strings.HasPrefix(upper, "AT+CMMS="):
+		c.ok()
+	default:
+		c.emit("\r\nERROR\r\n")
+	}
+}
+
+// ownNumberSlots is the size of the "ON" phonebook
+const ownNumberSlots = 4
+
+// readPhonebook answers AT+CPBR=<first>[,<last>]. Only the "ON" phonebook
+// has entries.
+func (c *Conn) readPhonebook(args string) {
+	m := c.modem
+	bounds := strings.Split(args, ",")
+	first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		c.emit("\r\nERROR\r\n")
+		return
+	}
+	last := first
+	if len(bounds) > 1 {
+		if last, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+			c.emit("\r\nERROR\r\n")
+			return
+		}
+	}
+
+	m.mu.Lock()
+	var lines strings.Builder
+	if m.phonebook == "ON" {
+		for i, number := range m.OwnNumbers {
+			if index := i + 1; index >= first && index <= last {
+				fmt.Fprintf(&lines, "\r\n+CPBR: %d,\"%s\",145,\"Own number\"", index, number)
+			}
+		}
+	}
+	m.mu.Unlock()
+	c.emit(lines.String() + "\r\n\r\nOK\r\n")
+}
+
+func (c *Conn) ok() {
+	c.emit("\r\nOK\r\n")
+}
+
+// reply answers with an information line followed by OK
+func (c *Conn) reply(line string) {
+	c.emit("\r\n" + line + "\r\n\r\nOK\r\n")
+}
+
+// submit completes AT+CMGS once the payload has been terminated
+func (c *Conn) submit(command, payload string) {
+	m := c.modem
+	m.mu.Lock()
+	sent := Sent{
+		Reference: m.nextRef,
+		Payload:   payload,
+		Time:      time.Now(),
+	}
+	arg := command[len("AT+CMGS="):]
+	if m.textMode {
+		sent.Mode = "text"
+		sent.DCS = m.textDCS
+		sent.To = strings.Trim(arg, "\"")
+		if m.charset == "UCS2" {
+			if raw, err := hex.DecodeString(sent.To); err == nil {
+				sent.To = pdu.DecodeUCS2(raw)
+			}
+		}
+	} else {
+		sent.Mode = "pdu"
+	}
+	valid := true
+	if !m.textMode {
+		length, err := strconv.Atoi(arg)
+		raw, hexErr := hex.DecodeString(payload)
+		// The length excludes the SMSC part that leads the PDU
+		valid = err == nil && hexErr == nil && len(raw) > 0 && length == len(raw)-1-int(raw[0])
+	}
+	var report bool
+	var recipient string
+	if valid {
+		m.nextRef = (m.nextRef + 1) % 256
+		m.sent = append(m.sent, sent)
+		report, recipient = m.statusReportRequested(sent)
+	}
+	delay := m.SendDelay
+	reportDelay := m.ReportDelay
+	m.mu.Unlock()
+
+	if !valid {
+		c.emitLater(delay, "\r\n+CMS ERROR: 304\r\n")
+		return
+	}
+	c.emitLater(delay, fmt.Sprintf("\r\n+CMGS: %d\r\n\r\nOK\r\n", sent.Reference))
+	if report {
+		c.reportDelivery(sent, recipient, delay+reportDelay)
+	}
+}
+
+// listMessages answers AT+CMGL in text and PDU mode
+func (c *Conn) listMessages(command string) {
+	m := c.modem
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	filter := "ALL"
+	if !m.textMode {
+		filter = "4"
+	}
+	if i := strings.Index(command, "="); i >= 0 {
+		filter = strings.Trim(command[i+1:], "\"")
+	}
+
+	var b strings.Builder
+	for _, msg := range m.inbox {
+		if m.memory != m.MessageMemory {
+			break
+		}
+		if m.textMode {
+			if filter != "ALL" && filter != msg.Status {
+				continue
+			}
+			fmt.Fprintf(&b, "\r\n+CMGL: %d,\"%s\",\"%s\",,\"%s\"\r\n%s", msg.Index, msg.Status, msg.From, timestamp(msg.Time), msg.Text)
+		} else {
+			stat := pduStat(msg.Status)
+			if filter != "4" && filter != stat {
+				continue
+			}
+			encoded, err := msg.encode()
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(&b, "\r\n+CMGL: %d,%s,,%d\r\n%s", msg.Index, stat, len(encoded)/2-1, encoded)
+		}
+		msg.Status = "REC READ"
+	}
+	c.emit(b.String() + "\r\n\r\nOK\r\n")
+}
+
+// readMessage answers AT+CMGR in text and PDU mode
+func (c *Conn) readMessage(arg string) {
+	m := c.modem
+	index, err := strconv.Atoi(strings.TrimSpace(arg))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		c.emit("\r\n+CMS ERROR: 321\r\n")
+		return
+	}
+	if m.memory == "SR" {
+		c.readReport(index)
+		return
+	}
+	for _, msg := range m.inbox {
+		if m.memory != m.MessageMemory || msg.Index != index {
+			continue
+		}
+		if m.textMode {
+			c.emit(fmt.Sprintf("\r\n+CMGR: \"%s\",\"%s\",,\"%s\"\r\n%s\r\n\r\nOK\r\n", msg.Status, msg.From, timestamp(msg.Time), msg.Text))
+		} else {
+			encoded, err := msg.encode()
+			if err != nil {
+				c.emit("\r\n+CMS ERROR: 500\r\n")
+				return
+			}
+			c.emit(fmt.Sprintf("\r\n+CMGR: %s,,%d\r\n%s\r\n\r\nOK\r\n", pduStat(msg.Status), len(encoded)/2-1, encoded))
+		}
+		msg.Status = "REC READ"
+		return
+	}
+	// Reading an empty slot succeeds without data on most modems
+	c.emit("\r\nOK\r\n")
+}
+
+// deleteMessage answers AT+CMGD
+func (c *Conn) deleteMessage(arg string) {
+	m := c.modem
+	parts := strings.Split(arg, ",")
+	index, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		c.emit("\r\n+CMS ERROR: 321\r\n")
+		return
+	}
+	flag := 0
+	if len(parts) > 1 {
+		flag, _ = strconv.Atoi(strings.TrimSpace(parts[1]))
+	}
+
+	m.mu.Lock()
+	if m.memory == "SR" {
+		m.deleteReport(index)
+		m.mu.Unlock()
+		c.ok()
+		return
+	}
+	if m.memory != m.MessageMemory {
+		// Nothing is stored there, deleting an empty slot succeeds
+		m.mu.Unlock()
+		c.ok()
+		return
+	}
+	var kept []*Message
+	for _, msg := range m.inbox {
+		remove := msg.Index == index
+		switch flag {
+		case 1:
+			remove = remove || msg.Status == "REC READ"
+		case 4:
+			remove = true
+		}
+		if !remove {
+			kept = append(kept, msg)
+		}
+	}
+	m.inbox = kept
+	m.mu.Unlock()
+	c.ok()
+}
+
+// ussd answers AT+CUSD: OK first, then the +CUSD URC after USSDDelay.
+// While a menu is open the string is a reply to it, AT+CUSD=2 closes it.
+func (c *Conn) ussd(line string) {
+	m := c.modem
+	args := strings.SplitN(line[len("AT+CUSD="):], ",", 3)
+	if strings.TrimSpace(args[0]) == "2" {
+		m.mu.Lock()
+		m.ussdPath = ""
+		m.mu.Unlock()
+		c.ok()
+		return
+	}
+	if len(args) < 2 {
+		c.ok()
+		return
+	}
+	path := strings.Trim(args[1], "\"")
+
+	m.mu.Lock()
+	if m.charset == "UCS2" {
+		if raw, err := hex.DecodeString(path); err == nil {
+			path = pdu.DecodeUCS2(raw)
+		}
+	}
+	if m.ussdPath != "" {
+		path = m.ussdPath + ">" + path
+	}
+	menu, isMenu := m.ussdMenus[path]
+	reply, ok := m.ussd[path]
+	delay := m.USSDDelay
+	m.ussdPath = ""
+	if isMenu {
+		m.ussdPath = path
+	}
+	m.mu.Unlock()
+
+	c.ok()
+	switch {
+	case isMenu:
+		c.emitLater(delay, m.ussdAnswer(1, menu))
+	case ok:
+		c.emitLater(delay, m.ussdAnswer(0, reply))
+	default:
+		c.emitLater(delay, "\r\n+CUSD: 4\r\n")
+	}
+}
+
+// ussdAnswer formats a +CUSD URC the way USSDEncoding and the character
+// set ask for: UCS2 answers are hex with DCS 72, GSM 7-bit answers plain or
+// packed as hex with DCS 15, and in the UCS2 character set every answer is
+// presented as hex UCS2
+func (m *Modem) ussdAnswer(status int, text string) string {
+	m.mu.Lock()
+	encoding, charset := m.USSDEncoding, m.charset
+	m.mu.Unlock()
+
+	dcs := 15
+	switch {
+	case encoding == "ucs2":
+		dcs = 72
+		text = strings.ToUpper(hex.EncodeToString(pdu.EncodeUCS2(text)))
+	case charset == "UCS2":
+		text = strings.ToUpper(hex.EncodeToString(pdu.EncodeUCS2(text)))
+	case encoding == "gsm7":
+		septets, _ := pdu.EncodeGSM7(text)
+		// Seven spare bits would read as '@', so they are padded with CR
+		if len(septets)%8 == 7 {
+			septets = append(septets, '\r')
+		}
+		text = strings.ToUpper(hex.EncodeToString(pdu.PackSeptets(septets, 0)))
+	}
+	return fmt.Sprintf("\r\n+CUSD: %d,\"%s\",%d\r\n", status, text, dcs)
+}
+
+// timestamp formats a time like a text-mode SCTS, e.g. "26/10/16,10:00:00+28"
+func timestamp(t time.Time) string {
+	_, offset := t.Zone()
+	quarters := offset / (15 * 60)
+	sign := "+"
+	if quarters < 0 {
+		sign = "-"
+		quarters = -quarters
+	}
+	return fmt.Sprintf("%s%s%02d", t.Format("06/01/02,15:04:05"), sign, quarters)
+}
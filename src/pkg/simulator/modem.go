@@ -0,0 +1,339 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sms-gateway/src/pkg/pdu"
+)
+
+// Scheme prefixes the port names of simulated modems, e.g. "sim://modem1"
+const Scheme = "sim://"
+
+// Message is an SMS held in the simulated SIM storage
+type Message struct {
+	Index  int
+	From   string
+	Text   string
+	Time   time.Time
+	Status string // "REC UNREAD" or "REC READ"
+	Header []pdu.InformationElement
+}
+
+// encode returns the message as an SMS-DELIVER PDU
+func (msg *Message) encode() (string, error) {
+	return (&pdu.Deliver{Sender: msg.From, Timestamp: msg.Time, Text: msg.Text, Header: msg.Header}).Encode()
+}
+
+// pduStat converts a text mode <stat> to its PDU mode number
+func pduStat(status string) string {
+	if status == "REC UNREAD" {
+		return "0"
+	}
+	return "1"
+}
+
+// Sent is an SMS submitted through AT+CMGS
+type Sent struct {
+	Reference int
+	Mode      string // "text" or "pdu"
+	To        string // destination in text mode
+	Payload   string // message text or PDU hex as written by the host
+	DCS       int    // data coding scheme set with AT+CSMP, text mode only
+	Time      time.Time
+}
+
+// Modem is a scriptable virtual GSM modem. Its exported fields describe
+// what the modem reports and may be changed before or between commands.
+type Modem struct {
+	Name         string
+	Manufacturer string
+	Model        string
+	Revision     string
+	IMEI         string
+	IMSI         string
+	ICCID        string
+	Operator     string   // name reported by AT+COPS?
+	MSISDN       string   // number reported by AT+CNUM, empty like most SIMs
+	OwnNumbers   []string // entries of the "ON" phonebook read with AT+CPBR
+	Signal       int      // RSSI reported by AT+CSQ (0-31, 99 unknown)
+	Registration int      // <stat> reported by AT+CREG?
+
+	USSDDelay    time.Duration // delay before a +CUSD answer
+	USSDEncoding string        // "" answers in plain text, "ucs2" and "gsm7" (packed) as hex
+	SendDelay    time.Duration // delay before the +CMGS answer
+
+	DeliveryStatus byte          // TP-Status of generated status reports, 0 = delivered
+	ReportDelay    time.Duration // delay between +CMGS and the status report
+	StoreReports   bool          // report with +CDSI even when CNMI asks for +CDS
+
+	MessageMemory string // storage holding received messages, announced by +CMTI
+
+	mu        sync.Mutex
+	ussd      map[string]string
+	ussdMenus map[string]string
+	ussdPath  string // code and replies of the open USSD menu, "" when none
+	failures  []failure
+	conns     []*Conn
+	echo      bool
+	textMode  bool
+	charset   string
+	memory    string // <mem1> selected with AT+CPMS
+	phonebook string // storage selected with AT+CPBS
+	textFO    int    // <fo> set with AT+CSMP
+	textDCS   int    // <dcs> set with AT+CSMP
+	nextRef   int
+	nextIndex int
+	inbox     []*Message
+	sent      []Sent
+
+	nextConcatRef int // reference of the next InjectConcatenatedSMS
+
+	messageMode     int // <mt> set with AT+CNMI
+	reportMode      int // <ds> set with AT+CNMI
+	reports         []*storedReport
+	nextReportIndex int
+}
+
+// failure is a scripted error returned to the next matching command
+type failure struct {
+	prefix string
+	result string
+}
+
+// New creates a modem that reports a registered Viettel SIM
+func New(name string) *Modem {
+	return &Modem{
+		Name:          name,
+		Manufacturer:  "SIMCOM",
+		Model:         "SIM800C Virtual",
+		Revision:      "Revision:1418B05SIM800C24",
+		IMEI:          "860000000000001",
+		IMSI:          "452040000000001",
+		ICCID:         "8984040000000000001",
+		Operator:      "Viettel",
+		Signal:        20,
+		Registration:  1,
+		USSDDelay:     50 * time.Millisecond,
+		SendDelay:     50 * time.Millisecond,
+		ReportDelay:   100 * time.Millisecond,
+		MessageMemory: "SM",
+		ussd:          map[string]string{},
+		ussdMenus:     map[string]string{},
+		echo:          true,
+		charset:       "GSM",
+		memory:        "SM",
+		phonebook:     "SM",
+		textFO:        17,
+		nextRef:       1,
+		nextIndex:     1,
+
+		nextReportIndex: 1,
+	}
+}
+
+// SetUSSD scripts the answer returned for a USSD code
+func (m *Modem) SetUSSD(code, reply string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ussd[code] = reply
+}
+
+// SetUSSDMenu scripts a menu that waits for a reply (+CUSD: 1). path is
+// the code followed by the replies leading to the menu, separated by ">",
+// e.g. "*098#" for the first level and "*098#>1" for the menu shown after
+// answering 1. The final answers of a menu are scripted with SetUSSD using
+// the same paths.
+func (m *Modem) SetUSSDMenu(path, prompt string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ussdMenus[path] = prompt
+}
+
+// USSDSession returns the path of the open USSD menu, "" when none is open
+func (m *Modem) USSDSession() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ussdPath
+}
+
+// FailNext makes the next command starting with prefix (e.g. "AT+CMGS")
+// finish with result (e.g. "+CMS ERROR: 500") instead of its normal answer
+func (m *Modem) FailNext(prefix, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures = append(m.failures, failure{prefix: strings.ToUpper(prefix), result: result})
+}
+
+// InjectSMS receives an inbound message. It is stored and reported with
+// +CMTI, or routed to the host with +CMT when AT+CNMI set <mt> to 2, in
+// which case the returned index is 0.
+func (m *Modem) InjectSMS(from, text string) int {
+	return m.receive(&Message{From: from, Text: text})
+}
+
+// InjectConcatenatedSMS receives text as a concatenated message, one
+// SMS-DELIVER per part, leaving out the part numbers in skip. It returns
+// the storage index of every part received.
+func (m *Modem) InjectConcatenatedSMS(from, text string, skip ...int) []int {
+	m.mu.Lock()
+	ref := m.nextConcatRef
+	m.nextConcatRef = (m.nextConcatRef + 1) % 256
+	m.mu.Unlock()
+
+	parts, _ := pdu.Split(text, false)
+	var indexes []int
+	for i, part := range parts {
+		skipped := false
+		for _, n := range skip {
+			skipped = skipped || n == i+1
+		}
+		if skipped {
+			continue
+		}
+		header := []pdu.InformationElement{pdu.ConcatElement(uint16(ref), len(parts), i+1, false)}
+		indexes = append(indexes, m.receive(&Message{From: from, Text: part, Header: header}))
+	}
+	return indexes
+}
+
+// receive stores or routes an inbound message as InjectSMS describes
+func (m *Modem) receive(msg *Message) int {
+	m.mu.Lock()
+	msg.Time = time.Now()
+	msg.Status = "REC UNREAD"
+	if m.messageMode == 2 {
+		textMode := m.textMode
+		m.mu.Unlock()
+		if textMode {
+			m.Unsolicited(fmt.Sprintf("+CMT: \"%s\",,\"%s\"\r\n%s", msg.From, timestamp(msg.Time), msg.Text))
+			return 0
+		}
+		encoded, err := msg.encode()
+		if err == nil {
+			m.Unsolicited(fmt.Sprintf("+CMT: ,%d\r\n%s", len(encoded)/2-1, encoded))
+		}
+		return 0
+	}
+	msg.Index = m.nextIndex
+	m.nextIndex++
+	m.inbox = append(m.inbox, msg)
+	memory := m.MessageMemory
+	m.mu.Unlock()
+
+	m.Unsolicited(fmt.Sprintf("+CMTI: \"%s\",%d", memory, msg.Index))
+	return msg.Index
+}
+
+// Unsolicited writes a URC line to every open connection
+func (m *Modem) Unsolicited(line string) {
+	m.mu.Lock()
+	conns := append([]*Conn(nil), m.conns...)
+	m.mu.Unlock()
+
+	for _, c := range conns {
+		c.emit("\r\n" + line + "\r\n")
+	}
+}
+
+// Disconnect closes every open connection, as if the modem were unplugged
+func (m *Modem) Disconnect() {
+	m.mu.Lock()
+	conns := append([]*Conn(nil), m.conns...)
+	m.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// Sent returns the messages submitted so far
+func (m *Modem) Sent() []Sent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Sent(nil), m.sent...)
+}
+
+// Inbox returns the messages held in MessageMemory
+func (m *Modem) Inbox() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var msgs []Message
+	for _, msg := range m.inbox {
+		msgs = append(msgs, *msg)
+	}
+	return msgs
+}
+
+// Memory returns the storage selected with AT+CPMS
+func (m *Modem) Memory() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.memory
+}
+
+// takeFailure pops the scripted failure matching command. Callers must hold m.mu.
+func (m *Modem) takeFailure(command string) (string, bool) {
+	upper := strings.ToUpper(command)
+	for i, f := range m.failures {
+		if strings.HasPrefix(upper, f.prefix) {
+			m.failures = append(m.failures[:i], m.failures[i+1:]...)
+			return f.result, true
+		}
+	}
+	return "", false
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Modem{}
+)
+
+// IsSimulated reports whether a port name refers to a simulated modem
+func IsSimulated(portName string) bool {
+	return strings.HasPrefix(portName, Scheme)
+}
+
+// Get returns the simulated modem registered under portName, creating it
+// on first use
+func Get(portName string) *Modem {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	m, ok := registry[portName]
+	if !ok {
+		m = New(strings.TrimPrefix(portName, Scheme))
+		registry[portName] = m
+	}
+	return m
+}
+
+// Names lists the port names of all registered simulated modems
+func Names() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open connects to the simulated modem registered under portName
+func Open(portName string) (*Conn, error) {
+	if !IsSimulated(portName) {
+		return nil, fmt.Errorf("%s is not a simulated port", portName)
+	}
+	m := Get(portName)
+	c := newConn(m)
+
+	m.mu.Lock()
+	m.conns = append(m.conns, c)
+	m.mu.Unlock()
+
+	return c, nil
+}
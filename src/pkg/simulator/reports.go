@@ -0,0 +1,124 @@
+package simulator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sms-gateway/src/pkg/pdu"
+)
+
+// storedReport is a status report kept in "SR" memory
+type storedReport struct {
+	index int
+	pdu   string
+}
+
+// statusReportRequested reports whether a submission asked for a delivery
+// report and returns its recipient. Callers must hold m.mu.
+func (m *Modem) statusReportRequested(sent Sent) (bool, string) {
+	if sent.Mode == "text" {
+		return m.textFO&0x20 != 0, sent.To
+	}
+
+	raw, err := hex.DecodeString(sent.Payload)
+	if err != nil || len(raw) == 0 || len(raw) < 3+int(raw[0]) {
+		return false, ""
+	}
+	tpdu := raw[1+int(raw[0]):]
+	to, _, err := pdu.DecodeAddress(tpdu[2:])
+	if err != nil {
+		return false, ""
+	}
+	return tpdu[0]&0x20 != 0, to
+}
+
+// reportDelivery sends the status report for a submission once
+// ReportDelay has passed, as +CDS or, when storing, as +CDSI
+func (c *Conn) reportDelivery(sent Sent, recipient string, delay time.Duration) {
+	m := c.modem
+	time.AfterFunc(delay, func() {
+		report := &pdu.StatusReport{
+			Reference:         sent.Reference,
+			Recipient:         recipient,
+			ServiceCentreTime: sent.Time,
+			DischargeTime:     time.Now(),
+		}
+
+		m.mu.Lock()
+		report.Status = m.DeliveryStatus
+		mode, store, textMode := m.reportMode, m.StoreReports, m.textMode
+		encoded, err := report.Encode()
+		if err != nil || (mode == 0 && !store) {
+			m.mu.Unlock()
+			return
+		}
+		if store || mode == 2 {
+			index := m.nextReportIndex
+			m.nextReportIndex++
+			m.reports = append(m.reports, &storedReport{index: index, pdu: encoded})
+			m.mu.Unlock()
+			c.emit(fmt.Sprintf("\r\n+CDSI: \"SR\",%d\r\n", index))
+			return
+		}
+		m.mu.Unlock()
+
+		if textMode {
+			toa := pdu.TypeNational
+			if strings.HasPrefix(recipient, "+") {
+				toa = pdu.TypeInternational
+			}
+			c.emit(fmt.Sprintf("\r\n+CDS: 6,%d,\"%s\",%d,\"%s\",\"%s\",%d\r\n",
+				report.Reference, recipient, toa, timestamp(report.ServiceCentreTime), timestamp(report.DischargeTime), report.Status))
+			return
+		}
+		c.emit(fmt.Sprintf("\r\n+CDS: %d\r\n%s\r\n", len(encoded)/2-1, encoded))
+	})
+}
+
+// readReport answers AT+CMGR for "SR" memory. Callers must hold m.mu.
+func (c *Conn) readReport(index int) {
+	m := c.modem
+	if m.textMode {
+		c.emit("\r\n+CMS ERROR: 303\r\n")
+		return
+	}
+	for _, r := range m.reports {
+		if r.index == index {
+			c.emit(fmt.Sprintf("\r\n+CMGR: 1,,%d\r\n%s\r\n\r\nOK\r\n", len(r.pdu)/2-1, r.pdu))
+			return
+		}
+	}
+	c.emit("\r\nOK\r\n")
+}
+
+// deleteReport answers AT+CMGD for "SR" memory. Callers must hold m.mu.
+func (m *Modem) deleteReport(index int) {
+	for i, r := range m.reports {
+		if r.index == index {
+			m.reports = append(m.reports[:i], m.reports[i+1:]...)
+			return
+		}
+	}
+}
+
+// Reports returns the number of status reports held in "SR" memory
+func (m *Modem) Reports() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.reports)
+}
+
+// setIndications records the <mt> and <ds> parameters of
+// AT+CNMI=<mode>,<mt>,<bm>,<ds>,<bfr>
+func (m *Modem) setIndications(args string) {
+	params := strings.Split(args, ",")
+	if len(params) > 1 {
+		m.messageMode, _ = strconv.Atoi(strings.TrimSpace(params[1]))
+	}
+	if len(params) > 3 {
+		m.reportMode, _ = strconv.Atoi(strings.TrimSpace(params[3]))
+	}
+}
@@ -1,424 +1,330 @@
 package sms
 
 import (
-	"bufio"
 	"context"
+	"encoding/hex"
 	"fmt"
 	"log"
-	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"sms-gateway/src/internal/config"
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/pdu"
+	"sms-gateway/src/pkg/session"
+)
+
+// defaultValidity is how long the SMSC keeps retrying delivery
+const defaultValidity = 24 * time.Hour
 
-	serial "go.bug.st/serial"
+// Character sets selected with AT+CSCS
+const (
+	CharsetIRA  = "IRA"
+	CharsetUCS2 = "UCS2"
 )
 
 // Client handles SMS operations
 type Client struct {
-	config *config.Config
+	config    *config.Config
+	sessions  *session.Manager
+	concatRef uint32
 }
 
-// NewClient creates a new SMS client
-func NewClient(cfg *config.Config) *Client {
-	return &Client{
-		config: cfg,
-	}
+// Result describes a send attempt
+type Result struct {
+	Steps    []string
+	Mode     string // "text" or "pdu", as actually used
+	Encoding string // pdu.EncodingGSM7 or pdu.EncodingUCS2
+	Segments []model.SMSSegment
 }
 
-// SendViaPDU sends SMS using PDU mode
-func (c *Client) SendViaPDU(ctx context.Context, portName string, baudRate int, to, message string) ([]string, string, error) {
-	log.Printf("SMS Client: Starting SendViaPDU - Port: %s, BaudRate: %d, To: %s", portName, baudRate, to)
-	var steps []string
+// Progress is told how far a send got, so that a caller recording it can
+// tell after a crash whether a part may already have reached the network.
+// Both functions are optional.
+type Progress struct {
+	Submitting func(part, total int)          // before the part is handed to the modem
+	Submitted  func(segment model.SMSSegment) // once the network accepted the part
+}
 
-	// Check if port exists
-	if _, err := os.Stat(portName); os.IsNotExist(err) {
-		log.Printf("SMS Client: Port %s does not exist", portName)
-		return steps, "", fmt.Errorf("port %s does not exist", portName)
+func (p *Progress) submitting(part, total int) {
+	if p != nil && p.Submitting != nil {
+		p.Submitting(part, total)
 	}
+}
 
-	steps = append(steps, fmt.Sprintf("Opening port %s at %d baud", portName, baudRate))
+func (p *Progress) submitted(segment model.SMSSegment) {
+	if p != nil && p.Submitted != nil {
+		p.Submitted(segment)
+	}
+}
 
-	mode := &serial.Mode{
-		BaudRate: baudRate,
-		Parity:   serial.NoParity,
-		DataBits: 8,
-		StopBits: serial.OneStopBit,
+// NewClient creates a new SMS client on top of the shared modem sessions
+func NewClient(cfg *config.Config, sessions *session.Manager) *Client {
+	return &Client{
+		config:    cfg,
+		sessions:  sessions,
+		concatRef: uint32(time.Now().UnixNano()),
 	}
+}
 
-	port, err := serial.Open(portName, mode)
-	if err != nil {
-		log.Printf("SMS Client: Failed to open port %s: %v", portName, err)
-		return steps, "", fmt.Errorf("failed to open port: %w", err)
+// nextConcatRef returns the reference shared by the parts of the next
+// concatenated message
+func (c *Client) nextConcatRef(wide bool) uint16 {
+	ref := atomic.AddUint32(&c.concatRef, 1)
+	if wide {
+		return uint16(ref)
 	}
-	defer port.Close()
+	return uint16(ref & 0xFF)
+}
 
-	steps = append(steps, "Port opened successfully")
-	log.Printf("SMS Client: Port opened successfully")
+// SendViaPDU sends SMS using PDU mode. Messages longer than one SMS are
+// sent as concatenated parts over the same session. progress may be nil.
+func (c *Client) SendViaPDU(ctx context.Context, portName string, baudRate int, to, message string, progress *Progress) (*Result, error) {
+	log.Printf("SMS Client: Starting SendViaPDU - Port: %s, BaudRate: %d, To: %s", portName, baudRate, to)
+	result := &Result{Mode: "pdu", Encoding: pdu.SelectEncoding(message)}
 
-	// Initialize modem
-	log.Printf("SMS Client: Initializing modem...")
-	if err := c.initializeModem(ctx, port, &steps); err != nil {
-		log.Printf("SMS Client: Modem initialization failed: %v", err)
-		return steps, "", err
+	device := c.sessions.Device(portName, baudRate)
+	result.Steps = append(result.Steps, fmt.Sprintf("Using modem session on %s", portName))
+
+	sess, err := device.Session(ctx)
+	if err != nil {
+		log.Printf("SMS Client: Modem on %s not ready: %v", portName, err)
+		return result, err
 	}
 
 	// Set SMS mode to PDU
-	steps = append(steps, "Setting SMS mode to PDU")
+	result.Steps = append(result.Steps, "Setting SMS mode to PDU")
 	log.Printf("SMS Client: Setting SMS mode to PDU...")
-	if err := c.sendATCommand(ctx, port, "AT+CMGF=0", "OK"); err != nil {
+	if err := device.SetMessageFormat(ctx, session.FormatPDU); err != nil {
 		log.Printf("SMS Client: Failed to set PDU mode: %v", err)
-		return steps, "", fmt.Errorf("failed to set PDU mode: %w", err)
+		return result, fmt.Errorf("failed to set PDU mode: %w", err)
 	}
 
-	// Generate PDU
-	log.Printf("SMS Client: Generating PDU...")
-	pdu, err := c.generatePDU(to, message)
+	// Split into concatenated parts if needed
+	wide := c.config.SMS.ConcatRef16
+	submits, err := pdu.SplitSubmit(pdu.Submit{
+		Destination:    formatPhoneNumber(to),
+		Text:           message,
+		ValidityPeriod: defaultValidity,
+		StatusReport:   c.config.SMS.DeliveryReports,
+	}, c.nextConcatRef(wide), wide)
 	if err != nil {
-		log.Printf("SMS Client: Failed to generate PDU: %v", err)
-		return steps, "", fmt.Errorf("failed to generate PDU: %w", err)
+		log.Printf("SMS Client: Failed to split message: %v", err)
+		return result, fmt.Errorf("failed to generate PDU: %w", err)
 	}
 
-	steps = append(steps, fmt.Sprintf("Generated PDU: %s", pdu))
-	log.Printf("SMS Client: PDU generated: %s", pdu)
+	if len(submits) > 1 {
+		result.Steps = append(result.Steps, fmt.Sprintf("Splitting message into %d parts", len(submits)))
+		log.Printf("SMS Client: Sending %d concatenated parts", len(submits))
 
-	// Calculate PDU length
-	pduLength := (len(pdu) - 2) / 2 // Subtract 2 for SMSC length and divide by 2 for hex
+		// Keep the link to the SMSC open between parts
+		if _, err := sess.Command(ctx, "AT+CMMS=1"); err != nil {
+			log.Printf("SMS Client: AT+CMMS not supported, sending parts anyway: %v", err)
+		} else {
+			defer sess.Command(context.Background(), "AT+CMMS=0")
+		}
+	}
 
-	// Send SMS
-	steps = append(steps, fmt.Sprintf("Sending SMS command with length %d", pduLength))
-	command := fmt.Sprintf("AT+CMGS=%d", pduLength)
-	log.Printf("SMS Client: Sending command: %s", command)
+	for i, submit := range submits {
+		part := i + 1
 
-	if err := c.sendATCommand(ctx, port, command, ">"); err != nil {
-		log.Printf("SMS Client: Failed to initiate SMS send: %v", err)
-		return steps, "", fmt.Errorf("failed to initiate SMS send: %w", err)
-	}
+		// Generate PDU
+		log.Printf("SMS Client: Generating %s PDU for part %d/%d...", result.Encoding, part, len(submits))
+		pduHex, pduLength, err := submit.Encode()
+		if err != nil {
+			log.Printf("SMS Client: Failed to generate PDU: %v", err)
+			return result, fmt.Errorf("failed to generate PDU: %w", err)
+		}
 
-	steps = append(steps, "Sending PDU data")
-	log.Printf("SMS Client: Sending PDU data...")
+		result.Steps = append(result.Steps, fmt.Sprintf("Generated %s PDU: %s", result.Encoding, pduHex))
+		log.Printf("SMS Client: PDU generated: %s", pduHex)
 
-	// For PDU mode, also use the special SMS sender
-	if err := c.sendSMSPDU(ctx, port, pdu); err != nil {
-		log.Printf("SMS Client: Failed to send SMS: %v", err)
-		return steps, "", fmt.Errorf("failed to send SMS: %w", err)
+		// Send SMS
+		result.Steps = append(result.Steps, fmt.Sprintf("Sending SMS command with length %d", pduLength))
+		command := fmt.Sprintf("AT+CMGS=%d", pduLength)
+		log.Printf("SMS Client: Sending command: %s", command)
+
+		progress.submitting(part, len(submits))
+		reference, err := c.sendPayload(ctx, sess, command, pduHex)
+		if err != nil {
+			log.Printf("SMS Client: Failed to send part %d/%d: %v", part, len(submits), err)
+			if len(submits) > 1 {
+				return result, fmt.Errorf("failed to send SMS part %d of %d: %w", part, len(submits), err)
+			}
+			return result, fmt.Errorf("failed to send SMS: %w", err)
+		}
+		segment := model.SMSSegment{Part: part, Reference: reference}
+		result.Segments = append(result.Segments, segment)
+		progress.submitted(segment)
+		result.Steps = append(result.Steps, fmt.Sprintf("Part %d/%d accepted with reference %d", part, len(submits), reference))
 	}
 
-	steps = append(steps, "SMS sent successfully")
-	messageID := fmt.Sprintf("SMS_%d", time.Now().Unix())
-	log.Printf("SMS Client: SMS sent successfully - MessageID: %s", messageID)
+	result.Steps = append(result.Steps, "SMS sent successfully")
+	log.Printf("SMS Client: SMS sent successfully - References: %v", references(result.Segments))
 
-	return steps, messageID, nil
+	return result, nil
 }
 
-// SendViaText sends SMS using text mode (easier than PDU mode)
-func (c *Client) SendViaText(ctx context.Context, portName string, baudRate int, to, message string) ([]string, string, error) {
+// SendViaText sends SMS using text mode (easier than PDU mode). Text in the
+// GSM alphabet goes out as GSM 7-bit and anything else, such as Vietnamese
+// diacritics, as UCS2, like in PDU mode. Plain ASCII is written to the modem
+// in the IRA character set and other text as UCS2 hex. Text mode cannot carry
+// a concatenation header, so messages longer than one SMS are sent in PDU
+// mode. progress may be nil.
+func (c *Client) SendViaText(ctx context.Context, portName string, baudRate int, to, message string, progress *Progress) (*Result, error) {
 	log.Printf("SMS Client: Starting SendViaText - Port: %s, BaudRate: %d, To: %s", portName, baudRate, to)
-	var steps []string
-
-	// Check if port exists
-	if _, err := os.Stat(portName); os.IsNotExist(err) {
-		log.Printf("SMS Client: Port %s does not exist", portName)
-		return steps, "", fmt.Errorf("port %s does not exist", portName)
+	if !fitsTextMode(message) {
+		log.Printf("SMS Client: Message does not fit in one text mode SMS, switching to PDU mode")
+		result, err := c.SendViaPDU(ctx, portName, baudRate, to, message, progress)
+		result.Steps = append([]string{"Message too long for one text mode SMS, using PDU mode"}, result.Steps...)
+		return result, err
 	}
+	result := &Result{Mode: "text", Encoding: pdu.EncodingGSM7}
 
-	steps = append(steps, fmt.Sprintf("Opening port %s at %d baud", portName, baudRate))
-
-	mode := &serial.Mode{
-		BaudRate: baudRate,
-		Parity:   serial.NoParity,
-		DataBits: 8,
-		StopBits: serial.OneStopBit,
-	}
+	device := c.sessions.Device(portName, baudRate)
+	result.Steps = append(result.Steps, fmt.Sprintf("Using modem session on %s", portName))
 
-	port, err := serial.Open(portName, mode)
+	sess, err := device.Session(ctx)
 	if err != nil {
-		log.Printf("SMS Client: Failed to open port %s: %v", portName, err)
-		return steps, "", fmt.Errorf("failed to open port: %w", err)
-	}
-	defer port.Close()
-
-	steps = append(steps, "Port opened successfully")
-	log.Printf("SMS Client: Port opened successfully")
-
-	// Initialize modem
-	log.Printf("SMS Client: Initializing modem...")
-	if err := c.initializeModem(ctx, port, &steps); err != nil {
-		log.Printf("SMS Client: Modem initialization failed: %v", err)
-		return steps, "", err
+		log.Printf("SMS Client: Modem on %s not ready: %v", portName, err)
+		return result, err
 	}
 
 	// Set SMS mode to text
-	steps = append(steps, "Setting SMS mode to text")
+	result.Steps = append(result.Steps, "Setting SMS mode to text")
 	log.Printf("SMS Client: Setting SMS mode to text...")
-	if err := c.sendATCommand(ctx, port, "AT+CMGF=1", "OK"); err != nil {
+	if err := device.SetMessageFormat(ctx, session.FormatText); err != nil {
 		log.Printf("SMS Client: Failed to set text mode: %v", err)
-		return steps, "", fmt.Errorf("failed to set text mode: %w", err)
+		return result, fmt.Errorf("failed to set text mode: %w", err)
 	}
 
 	// Format phone number with international prefix if needed
-	formattedTo := to
-	if !strings.HasPrefix(to, "+") {
-		formattedTo = "+84" + strings.TrimPrefix(to, "0")
+	formattedTo := formatPhoneNumber(to)
+	if formattedTo != to {
 		log.Printf("SMS Client: Formatted phone number: %s -> %s", to, formattedTo)
 	}
 
-	// Send SMS
-	steps = append(steps, fmt.Sprintf("Sending SMS to %s", formattedTo))
-	command := fmt.Sprintf("AT+CMGS=\"%s\"", formattedTo)
-	log.Printf("SMS Client: Sending command: %s", command)
-
-	if err := c.sendATCommand(ctx, port, command, ">"); err != nil {
-		log.Printf("SMS Client: Failed to initiate SMS send: %v", err)
-		return steps, "", fmt.Errorf("failed to initiate SMS send: %w", err)
-	}
-
-	steps = append(steps, "Sending message text")
-	log.Printf("SMS Client: Sending message text...")
-
-	// For SMS sending, we might get different responses like "+CMGS: <id>" followed by "OK"
-	// or just "OK", so let's create a special handler for SMS sending
-	if err := c.sendSMSMessage(ctx, port, message); err != nil {
-		log.Printf("SMS Client: Failed to send SMS: %v", err)
-		return steps, "", fmt.Errorf("failed to send SMS: %w", err)
+	// The character set only says how the text is written to the modem; the
+	// data coding scheme of AT+CSMP picks the encoding on air, so "£" or "é"
+	// written as UCS2 hex still goes out as GSM 7-bit. With the UCS2 coding
+	// the modem takes the text as UCS2 hex, so it is always written that way,
+	// even when it is plain ASCII outside the GSM alphabet such as "`".
+	result.Encoding = pdu.SelectEncoding(message)
+	charset, dcs := CharsetIRA, pdu.DCSGSM7
+	destination, payload := formattedTo, message
+	if result.Encoding == pdu.EncodingUCS2 {
+		dcs = pdu.DCSUCS2
 	}
-
-	steps = append(steps, "SMS sent successfully")
-	messageID := fmt.Sprintf("SMS_%d", time.Now().Unix())
-	log.Printf("SMS Client: SMS sent successfully - MessageID: %s", messageID)
-
-	return steps, messageID, nil
-}
-
-// initializeModem initializes the modem
-func (c *Client) initializeModem(ctx context.Context, port serial.Port, steps *[]string) error {
-	// Test AT command
-	*steps = append(*steps, "Testing modem with AT command")
-	log.Printf("SMS Client: Testing modem with AT command...")
-	if err := c.sendATCommand(ctx, port, "AT", "OK"); err != nil {
-		log.Printf("SMS Client: Modem not responding to AT: %v", err)
-		return fmt.Errorf("modem not responding: %w", err)
+	if result.Encoding == pdu.EncodingUCS2 || !isASCII(message) {
+		charset = CharsetUCS2
+		destination = strings.ToUpper(hex.EncodeToString(pdu.EncodeUCS2(formattedTo)))
+		payload = strings.ToUpper(hex.EncodeToString(pdu.EncodeUCS2(message)))
 	}
 
-	// Disable echo
-	*steps = append(*steps, "Disabling echo")
-	log.Printf("SMS Client: Disabling echo...")
-	if err := c.sendATCommand(ctx, port, "ATE0", "OK"); err != nil {
-		log.Printf("SMS Client: Failed to disable echo: %v", err)
-		return fmt.Errorf("failed to disable echo: %w", err)
+	result.Steps = append(result.Steps, fmt.Sprintf("Setting character set to %s", charset))
+	log.Printf("SMS Client: Setting character set to %s...", charset)
+	if err := device.SetCharacterSet(ctx, charset); err != nil {
+		log.Printf("SMS Client: Failed to set character set: %v", err)
+		return result, fmt.Errorf("failed to set character set: %w", err)
 	}
-
-	// Check network registration
-	*steps = append(*steps, "Checking network registration")
-	log.Printf("SMS Client: Checking network registration...")
-	if err := c.sendATCommand(ctx, port, "AT+CREG?", "OK"); err != nil {
-		log.Printf("SMS Client: Failed to check network: %v", err)
-		return fmt.Errorf("failed to check network: %w", err)
+	// First octet: SMS-SUBMIT with relative validity, plus TP-SRR when
+	// delivery reports are wanted
+	firstOctet := 17
+	if c.config.SMS.DeliveryReports {
+		firstOctet |= 0x20
 	}
-
-	return nil
-}
-
-// sendATCommand sends AT command and waits for expected response
-func (c *Client) sendATCommand(ctx context.Context, port serial.Port, command, expected string) error {
-	log.Printf("SMS Client: Sending AT command: %s, expecting: %s", command, expected)
-
-	// Send command
-	_, err := port.Write([]byte(command + "\r\n"))
-	if err != nil {
-		log.Printf("SMS Client: Failed to write command: %v", err)
-		return err
+	if _, err := sess.Command(ctx, fmt.Sprintf("AT+CSMP=%d,%d,0,%d", firstOctet, pdu.RelativeValidity(defaultValidity), dcs)); err != nil {
+		log.Printf("SMS Client: Failed to set text mode parameters: %v", err)
+		return result, fmt.Errorf("failed to set text mode parameters: %w", err)
 	}
 
-	// Use different timeouts based on command type
-	timeout := time.Duration(5) * time.Second
+	// Send SMS
+	result.Steps = append(result.Steps, fmt.Sprintf("Sending SMS to %s", formattedTo))
+	command := fmt.Sprintf("AT+CMGS=\"%s\"", destination)
+	log.Printf("SMS Client: Sending command: %s", command)
 
-	// For SMS sending commands, use longer timeout
-	if strings.Contains(command, "\x1A") {
-		timeout = time.Duration(30) * time.Second // 30 seconds for SMS sending
-		log.Printf("SMS Client: Using extended timeout for SMS sending: %v", timeout)
-	} else if strings.Contains(command, "AT+CMGS") {
-		timeout = time.Duration(10) * time.Second // 10 seconds for SMS command setup
-		log.Printf("SMS Client: Using extended timeout for SMS command: %v", timeout)
-	}
+	result.Steps = append(result.Steps, "Sending message text")
+	log.Printf("SMS Client: Sending message text...")
 
-	log.Printf("SMS Client: Waiting for response with timeout: %v", timeout)
-	response, err := c.readUntil(ctx, port, timeout, expected)
+	progress.submitting(1, 1)
+	reference, err := c.sendPayload(ctx, sess, command, payload)
 	if err != nil {
-		log.Printf("SMS Client: Failed to read response: %v", err)
-		log.Printf("SMS Client: Partial response received: %s", response)
-		return err
-	}
-
-	log.Printf("SMS Client: Received response: %s", response)
-	if !strings.Contains(strings.ToUpper(response), strings.ToUpper(expected)) {
-		log.Printf("SMS Client: Unexpected response: %s", response)
-		return fmt.Errorf("unexpected response: %s", response)
+		log.Printf("SMS Client: Failed to send SMS: %v", err)
+		return result, fmt.Errorf("failed to send SMS: %w", err)
 	}
+	segment := model.SMSSegment{Part: 1, Reference: reference}
+	result.Segments = append(result.Segments, segment)
+	progress.submitted(segment)
 
-	log.Printf("SMS Client: Command successful")
-	return nil
-}
-
-// readUntil reads from port until expected string or timeout
-func (c *Client) readUntil(ctx context.Context, port serial.Port, timeout time.Duration, expected string) (string, error) {
-	deadline := time.Now().Add(timeout)
-	var response strings.Builder
-	reader := bufio.NewReader(port)
-
-	log.Printf("SMS Client: Starting readUntil, timeout: %v, expected: %s", timeout, expected)
-
-	for {
-		select {
-		case <-ctx.Done():
-			log.Printf("SMS Client: Context cancelled: %v", ctx.Err())
-			return response.String(), ctx.Err()
-		default:
-		}
-
-		if time.Now().After(deadline) {
-			log.Printf("SMS Client: Timeout waiting for: %s", expected)
-			return response.String(), fmt.Errorf("timeout waiting for: %s", expected)
-		}
-
-		port.SetReadTimeout(100 * time.Millisecond)
-		b, err := reader.ReadByte()
-		if err != nil {
-			// This is expected when no data is available
-			continue
-		}
-
-		response.WriteByte(b)
-		text := response.String()
+	result.Steps = append(result.Steps, "SMS sent successfully")
+	log.Printf("SMS Client: SMS sent successfully - References: %v", references(result.Segments))
 
-		if strings.Contains(strings.ToUpper(text), strings.ToUpper(expected)) {
-			log.Printf("SMS Client: Found expected response: %s", expected)
-			return text, nil
-		}
-	}
+	return result, nil
 }
 
-// sendSMSMessage sends the SMS message and handles various response patterns
-func (c *Client) sendSMSMessage(ctx context.Context, port serial.Port, message string) error {
-	log.Printf("SMS Client: Sending SMS message with Ctrl+Z...")
-
-	// Send message with Ctrl+Z terminator
-	_, err := port.Write([]byte(message + "\x1A"))
-	if err != nil {
-		log.Printf("SMS Client: Failed to write message: %v", err)
-		return err
-	}
-
+// sendPayload runs AT+CMGS and writes the message text or PDU once the
+// modem prompts for it. Success is only reported on the final OK, which
+// carries the message reference in "+CMGS: <mr>".
+func (c *Client) sendPayload(ctx context.Context, sess *at.Session, command, payload string) (int, error) {
 	// Wait for SMS response with extended timeout (30 seconds)
 	timeout := time.Duration(30) * time.Second
 	log.Printf("SMS Client: Waiting for SMS response with timeout: %v", timeout)
 
-	response, err := c.readUntil(ctx, port, timeout, "OK")
+	resp, err := sess.SendPayload(ctx, command, payload, timeout)
 	if err != nil {
-		log.Printf("SMS Client: Failed to get OK response: %v", err)
-
-		// Check if we got a partial response with message ID
-		if strings.Contains(response, "+CMGS:") {
-			log.Printf("SMS Client: Got CMGS response but no OK: %s", response)
-			// Sometimes the OK comes separately, try to read it
-			additionalResponse, additionalErr := c.readUntil(ctx, port, time.Duration(5)*time.Second, "OK")
-			if additionalErr == nil {
-				log.Printf("SMS Client: Got delayed OK response: %s", additionalResponse)
-				return nil
-			}
-			log.Printf("SMS Client: No additional OK received: %v", additionalErr)
-		}
-
-		return err
+		return 0, err
 	}
 
-	log.Printf("SMS Client: SMS sent successfully, response: %s", response)
-	return nil
+	log.Printf("SMS Client: SMS accepted by modem, response: %s", resp.Text())
+	return parseMessageReference(resp), nil
 }
 
-// sendSMSPDU sends the PDU data and handles various response patterns
-func (c *Client) sendSMSPDU(ctx context.Context, port serial.Port, pdu string) error {
-	log.Printf("SMS Client: Sending PDU data with Ctrl+Z...")
-
-	// Send PDU with Ctrl+Z terminator
-	_, err := port.Write([]byte(pdu + "\x1A"))
-	if err != nil {
-		log.Printf("SMS Client: Failed to write PDU: %v", err)
-		return err
-	}
-
-	// Wait for SMS response with extended timeout (30 seconds)
-	timeout := time.Duration(30) * time.Second
-	log.Printf("SMS Client: Waiting for PDU SMS response with timeout: %v", timeout)
-
-	response, err := c.readUntil(ctx, port, timeout, "OK")
-	if err != nil {
-		log.Printf("SMS Client: Failed to get OK response: %v", err)
-
-		// Check if we got a partial response with message ID
-		if strings.Contains(response, "+CMGS:") {
-			log.Printf("SMS Client: Got CMGS response but no OK: %s", response)
-			// Sometimes the OK comes separately, try to read it
-			additionalResponse, additionalErr := c.readUntil(ctx, port, time.Duration(5)*time.Second, "OK")
-			if additionalErr == nil {
-				log.Printf("SMS Client: Got delayed OK response: %s", additionalResponse)
-				return nil
-			}
-			log.Printf("SMS Client: No additional OK received: %v", additionalErr)
+// parseMessageReference extracts <mr> from a +CMGS response, -1 if absent
+func parseMessageReference(resp *at.Response) int {
+	for _, value := range resp.Prefixed("+CMGS:") {
+		if i := strings.Index(value, ","); i >= 0 {
+			value = value[:i]
+		}
+		if mr, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return mr
 		}
-
-		return err
 	}
-
-	log.Printf("SMS Client: PDU SMS sent successfully, response: %s", response)
-	return nil
+	return -1
 }
 
-// generatePDU generates PDU for SMS
-func (c *Client) generatePDU(to, message string) (string, error) {
-	// Simple PDU generation (this is a basic implementation)
-	// For production, you might want to use a more robust PDU library
-
-	// SMSC length (empty)
-	pdu := "00"
-
-	// SMS-SUBMIT type
-	pdu += "11"
-
-	// Message reference
-	pdu += "00"
-
-	// Destination address length
-	pdu += fmt.Sprintf("%02X", len(to))
-
-	// Destination address type (international)
-	pdu += "91"
-
-	// Destination address (reverse pairs and pad)
-	paddedTo := to
-	if len(paddedTo)%2 == 1 {
-		paddedTo += "F"
+// references lists the message references of the sent segments
+func references(segments []model.SMSSegment) []int {
+	refs := make([]int, len(segments))
+	for i, seg := range segments {
+		refs[i] = seg.Reference
 	}
+	return refs
+}
 
-	for i := 0; i < len(paddedTo); i += 2 {
-		if i+1 < len(paddedTo) {
-			pdu += string(paddedTo[i+1]) + string(paddedTo[i])
-		}
+// formatPhoneNumber adds the Vietnamese country code to national numbers
+func formatPhoneNumber(to string) string {
+	if strings.HasPrefix(to, "+") {
+		return to
 	}
+	return "+84" + strings.TrimPrefix(to, "0")
+}
 
-	// Protocol identifier
-	pdu += "00"
-
-	// Data coding scheme
-	pdu += "00"
-
-	// User data length
-	pdu += fmt.Sprintf("%02X", len(message))
+// fitsTextMode reports whether message can be sent as a single text mode SMS
+func fitsTextMode(message string) bool {
+	parts, _ := pdu.Split(message, false)
+	return len(parts) == 1
+}
 
-	// User data (convert to hex)
-	for _, char := range message {
-		pdu += fmt.Sprintf("%02X", int(char))
+// isASCII reports whether text can be written to the modem in the IRA
+// character set
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= 0x80 {
+			return false
+		}
 	}
-
-	return pdu, nil
+	return true
 }
@@ -0,0 +1,54 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/pdu"
+)
+
+// EnableIndications asks the modem to announce new messages with +CMTI,
+// keeping them in storage until read, and to forward status reports as
+// +CDS when reports is set. It runs on a freshly opened session, before
+// anything else uses it.
+func (c *Client) EnableIndications(ctx context.Context, sess *at.Session, reports bool) error {
+	ds := 0
+	if reports {
+		ds = 1
+	}
+	_, err := sess.Command(ctx, fmt.Sprintf("AT+CNMI=2,1,0,%d,0", ds))
+	return err
+}
+
+// ParseMessage decodes a message routed directly to the host with +CMT.
+// The PDU mode form "+CMT: [<alpha>],<length>" is followed by the PDU, the
+// text mode form "+CMT: <oa>,[<alpha>],<scts>" by the text.
+func ParseMessage(portName string, urc at.URC) (*model.SMS, error) {
+	fields := splitFields(urc.Params)
+	if len(fields) == 2 {
+		if _, err := strconv.Atoi(fields[1]); err == nil {
+			return decodeStored(portName, 0, strconv.Itoa(ListUnread), urc.Data)
+		}
+	}
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("malformed %s", urc.Line)
+	}
+
+	msg := &model.SMS{
+		From:      fields[0],
+		Message:   urc.Data,
+		Status:    model.StatusUnread,
+		Port:      portName,
+		Encoding:  pdu.EncodingGSM7,
+		CreatedAt: time.Now(),
+	}
+	if received, err := parseTextTimestamp(fields[2]); err == nil {
+		msg.ReceivedAt = &received
+		msg.CreatedAt = received
+	}
+	return msg, nil
+}
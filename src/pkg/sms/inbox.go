@@ -0,0 +1,195 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"sms-gateway/src/internal/model"
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/pdu"
+	"sms-gateway/src/pkg/session"
+)
+
+// ErrNoMessage is returned when a storage index holds no message
+var ErrNoMessage = errors.New("no message at this index")
+
+// <stat> values of AT+CMGL in PDU mode
+const (
+	ListUnread = 0
+	ListRead   = 1
+	ListAll    = 4
+)
+
+// ListMessages lists the received messages in modem storage with AT+CMGL.
+// Listing marks unread messages as read. Stored outgoing messages are skipped.
+func (c *Client) ListMessages(ctx context.Context, portName string, baudRate int, stat int) ([]model.SMS, error) {
+	device := c.sessions.Device(portName, baudRate)
+	sess, err := device.Session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := device.SetMessageFormat(ctx, session.FormatPDU); err != nil {
+		return nil, fmt.Errorf("failed to set PDU mode: %w", err)
+	}
+
+	resp, err := sess.Command(ctx, fmt.Sprintf("AT+CMGL=%d", stat))
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []model.SMS{}
+	for i := 0; i < len(resp.Lines); i++ {
+		line := resp.Lines[i]
+		if !strings.HasPrefix(line, "+CMGL:") || i+1 >= len(resp.Lines) {
+			continue
+		}
+		i++
+		fields := splitFields(strings.TrimSpace(strings.TrimPrefix(line, "+CMGL:")))
+		if len(fields) < 2 {
+			log.Printf("SMS Client: Ignoring malformed listing %q", line)
+			continue
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			log.Printf("SMS Client: Ignoring malformed listing %q", line)
+			continue
+		}
+		msg, err := decodeStored(portName, index, fields[1], resp.Lines[i])
+		if err != nil {
+			log.Printf("SMS Client: Skipping message %d on %s: %v", index, portName, err)
+			continue
+		}
+		messages = append(messages, *msg)
+	}
+	return messages, nil
+}
+
+// ReadMessage reads the message stored at index with AT+CMGR, which marks
+// it as read
+func (c *Client) ReadMessage(ctx context.Context, portName string, baudRate int, index int) (*model.SMS, error) {
+	device := c.sessions.Device(portName, baudRate)
+	sess, err := device.Session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := device.SetMessageFormat(ctx, session.FormatPDU); err != nil {
+		return nil, fmt.Errorf("failed to set PDU mode: %w", err)
+	}
+	return readStored(ctx, sess, portName, index)
+}
+
+// ReadStoredMessage reads the message that +CMTI announced at index of
+// memory mem and records the slot in its Stored. The previous memory
+// selection is restored. Callers hold the port lease.
+func (c *Client) ReadStoredMessage(ctx context.Context, device *session.Device, mem string, index int) (*model.SMS, error) {
+	sess, err := device.Session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := device.SetMessageFormat(ctx, session.FormatPDU); err != nil {
+		return nil, fmt.Errorf("failed to set PDU mode: %w", err)
+	}
+	restore, err := useMemory(ctx, sess, mem)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	msg, err := readStored(ctx, sess, device.Port(), index)
+	if err != nil {
+		return nil, err
+	}
+	msg.Stored = []model.StorageSlot{{Memory: mem, Index: index}}
+	return msg, nil
+}
+
+// DeleteStoredMessages deletes the slots of a received message, so that the
+// storage does not fill up until the modem rejects new messages. Callers
+// hold the port lease.
+func (c *Client) DeleteStoredMessages(ctx context.Context, device *session.Device, slots []model.StorageSlot) error {
+	sess, err := device.Session(ctx)
+	if err != nil {
+		return err
+	}
+	for _, slot := range slots {
+		restore, err := useMemory(ctx, sess, slot.Memory)
+		if err != nil {
+			return err
+		}
+		_, err = sess.Command(ctx, fmt.Sprintf("AT+CMGD=%d", slot.Index))
+		restore()
+		if err != nil {
+			return fmt.Errorf("failed to delete message %s/%d: %w", slot.Memory, slot.Index, err)
+		}
+	}
+	return nil
+}
+
+// readStored reads the message at index of the selected memory
+func readStored(ctx context.Context, sess *at.Session, portName string, index int) (*model.SMS, error) {
+	resp, err := sess.Command(ctx, fmt.Sprintf("AT+CMGR=%d", index))
+	if err != nil {
+		return nil, err
+	}
+	data := dataAfter(resp, "+CMGR:")
+	if data == "" {
+		return nil, ErrNoMessage
+	}
+	stat := ""
+	if values := resp.Prefixed("+CMGR:"); len(values) > 0 {
+		stat = splitFields(values[0])[0]
+	}
+	return decodeStored(portName, index, stat, data)
+}
+
+// DeleteMessage deletes the message stored at index with AT+CMGD
+func (c *Client) DeleteMessage(ctx context.Context, portName string, baudRate int, index int) error {
+	sess, err := c.sessions.Device(portName, baudRate).Session(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = sess.Command(ctx, fmt.Sprintf("AT+CMGD=%d", index))
+	return err
+}
+
+// DeleteAllMessages empties the message storage with AT+CMGD=1,4
+func (c *Client) DeleteAllMessages(ctx context.Context, portName string, baudRate int) error {
+	sess, err := c.sessions.Device(portName, baudRate).Session(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = sess.Command(ctx, "AT+CMGD=1,4")
+	return err
+}
+
+// decodeStored decodes a stored SMS-DELIVER into an inbound message
+func decodeStored(portName string, index int, stat, data string) (*model.SMS, error) {
+	deliver, err := pdu.DecodeDeliver(data)
+	if err != nil {
+		return nil, err
+	}
+	status := model.StatusRead
+	if stat == strconv.Itoa(ListUnread) {
+		status = model.StatusUnread
+	}
+	received := deliver.Timestamp
+	msg := &model.SMS{
+		From:       deliver.Sender,
+		Message:    deliver.Text,
+		Status:     status,
+		Port:       portName,
+		Index:      index,
+		Encoding:   deliver.Encoding,
+		ReceivedAt: &received,
+		CreatedAt:  received,
+	}
+	if ref, total, seq, ok := pdu.FindConcat(deliver.Header); ok && total > 1 {
+		msg.Parts = total
+		msg.Segments = []model.SMSSegment{{Part: seq, Reference: ref}}
+	}
+	return msg, nil
+}
@@ -0,0 +1,142 @@
+package sms
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sms-gateway/src/internal/model"
+)
+
+// Reassembler joins the parts of concatenated inbound messages. Parts are
+// grouped by port, sender and concatenation reference; a set that is still
+// incomplete after the timeout is emitted with the parts received so far.
+type Reassembler struct {
+	timeout time.Duration
+	emit    func(msg *model.SMS)
+
+	mu      sync.Mutex
+	pending map[partKey]*partSet
+}
+
+// partKey identifies the parts of one concatenated message
+type partKey struct {
+	port      string
+	sender    string
+	reference int
+	total     int
+}
+
+// partSet holds the parts received so far, by part number
+type partSet struct {
+	parts map[int]*model.SMS
+	timer *time.Timer
+}
+
+// NewReassembler creates a reassembler that calls emit with every complete
+// message, and with partial ones, flagged Partial, once timeout has passed
+// since their first part arrived
+func NewReassembler(timeout time.Duration, emit func(msg *model.SMS)) *Reassembler {
+	return &Reassembler{
+		timeout: timeout,
+		emit:    emit,
+		pending: make(map[partKey]*partSet),
+	}
+}
+
+// Add takes an inbound message. Single messages are emitted at once, as are
+// parts whose number lies outside 1..total, which could never complete a set.
+func (r *Reassembler) Add(msg *model.SMS) {
+	if msg.Parts <= 1 || len(msg.Segments) != 1 || msg.Segments[0].Part < 1 || msg.Segments[0].Part > msg.Parts {
+		r.emit(msg)
+		return
+	}
+
+	seg := msg.Segments[0]
+	key := partKey{port: msg.Port, sender: msg.From, reference: seg.Reference, total: msg.Parts}
+
+	r.mu.Lock()
+	set, ok := r.pending[key]
+	if !ok {
+		set = &partSet{parts: make(map[int]*model.SMS)}
+		set.timer = time.AfterFunc(r.timeout, func() { r.expire(key, set) })
+		r.pending[key] = set
+	}
+	// A repeated part replaces the earlier copy
+	set.parts[seg.Part] = msg
+	if len(set.parts) < key.total {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.pending, key)
+	set.timer.Stop()
+	r.mu.Unlock()
+
+	r.emit(join(set.parts, key.total))
+}
+
+// Pending returns the number of incomplete messages being held
+func (r *Reassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
+
+// expire emits an incomplete set when its timeout passes
+func (r *Reassembler) expire(key partKey, set *partSet) {
+	r.mu.Lock()
+	if r.pending[key] != set {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.pending, key)
+	r.mu.Unlock()
+
+	r.emit(join(set.parts, key.total))
+}
+
+// join concatenates parts in order into one message, flagging it partial
+// when some are missing
+func join(parts map[int]*model.SMS, total int) *model.SMS {
+	numbers := make([]int, 0, len(parts))
+	for n := range parts {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	first := parts[numbers[0]]
+	msg := *first
+	msg.Index = 0
+	msg.Segments = nil
+	msg.Stored = nil
+
+	var text strings.Builder
+	var missing []string
+	next := 1
+	for _, n := range numbers {
+		for ; next < n; next++ {
+			missing = append(missing, fmt.Sprint(next))
+		}
+		next = n + 1
+
+		part := parts[n]
+		text.WriteString(part.Message)
+		msg.Segments = append(msg.Segments, part.Segments...)
+		msg.Stored = append(msg.Stored, part.Stored...)
+		if part.ReceivedAt != nil && (msg.ReceivedAt == nil || part.ReceivedAt.Before(*msg.ReceivedAt)) {
+			msg.ReceivedAt = part.ReceivedAt
+		}
+	}
+	for ; next <= total; next++ {
+		missing = append(missing, fmt.Sprint(next))
+	}
+
+	msg.Message = text.String()
+	if len(missing) > 0 {
+		msg.Partial = true
+		msg.ErrorMsg = fmt.Sprintf("missing part %s of %d", strings.Join(missing, ", "), total)
+	}
+	return &msg
+}
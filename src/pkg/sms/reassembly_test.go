@@ -0,0 +1,94 @@
+package sms
+
+import (
+	"testing"
+	"time"
+
+	"sms-gateway/src/internal/model"
+)
+
+// part builds part seq of a message from sender with reference ref
+func part(sender string, ref, total, seq int, text string) *model.SMS {
+	received := time.Date(2026, 10, 16, 9, 0, seq, 0, time.UTC)
+	return &model.SMS{
+		From:       sender,
+		Message:    text,
+		Port:       "sim://1",
+		Index:      seq,
+		ReceivedAt: &received,
+		Parts:      total,
+		Segments:   []model.SMSSegment{{Part: seq, Reference: ref}},
+	}
+}
+
+func TestReassembleOutOfOrder(t *testing.T) {
+	emitted := make(chan *model.SMS, 4)
+	r := NewReassembler(time.Minute, func(msg *model.SMS) { emitted <- msg })
+
+	r.Add(part("+84987654321", 7, 3, 3, "three"))
+	r.Add(part("+84987654321", 7, 3, 1, "one "))
+	// Same reference from another sender is another message
+	r.Add(part("+84911111111", 7, 2, 1, "other"))
+	r.Add(part("+84987654321", 7, 3, 2, "two "))
+
+	msg := <-emitted
+	if msg.Message != "one two three" || msg.Partial || len(msg.Segments) != 3 || msg.ReceivedAt.Second() != 1 {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if r.Pending() != 1 {
+		t.Errorf("expected the other sender's part pending, got %d", r.Pending())
+	}
+
+	r.Add(&model.SMS{From: "+84987654321", Message: "single"})
+	if msg := <-emitted; msg.Message != "single" {
+		t.Errorf("single message = %+v", msg)
+	}
+}
+
+func TestReassemblyTimeout(t *testing.T) {
+	emitted := make(chan *model.SMS, 1)
+	r := NewReassembler(50*time.Millisecond, func(msg *model.SMS) { emitted <- msg })
+
+	r.Add(part("Vietcombank", 200, 4, 1, "So du "))
+	r.Add(part("Vietcombank", 200, 4, 3, "VND"))
+
+	select {
+	case msg := <-emitted:
+		if !msg.Partial || msg.Message != "So du VND" || msg.ErrorMsg != "missing part 2, 4 of 4" {
+			t.Errorf("unexpected partial message %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("incomplete message was not emitted")
+	}
+	if r.Pending() != 0 {
+		t.Errorf("%d sets still pending", r.Pending())
+	}
+}
+
+func TestReassembleInvalidPartNumber(t *testing.T) {
+	emitted := make(chan *model.SMS, 4)
+	r := NewReassembler(time.Minute, func(msg *model.SMS) { emitted <- msg })
+
+	// Parts numbered 0 or past the total are passed on as they are
+	r.Add(part("+84987654321", 9, 2, 0, "zero"))
+	r.Add(part("+84987654321", 9, 2, 3, "three"))
+	for _, want := range []string{"zero", "three"} {
+		if msg := <-emitted; msg.Message != want || msg.Partial {
+			t.Errorf("unexpected message %+v, want %q", msg, want)
+		}
+	}
+	if r.Pending() != 0 {
+		t.Errorf("expected nothing pending, got %d", r.Pending())
+	}
+
+	// and do not count towards the set of the valid parts
+	r.Add(part("+84987654321", 9, 2, 1, "one "))
+	r.Add(part("+84987654321", 9, 2, 5, "five"))
+	if msg := <-emitted; msg.Message != "five" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	r.Add(part("+84987654321", 9, 2, 2, "two"))
+	if msg := <-emitted; msg.Message != "one two" || msg.Partial {
+		t.Errorf("unexpected message %+v", msg)
+	}
+}
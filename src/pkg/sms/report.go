@@ -0,0 +1,168 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/pdu"
+	"sms-gateway/src/pkg/session"
+)
+
+// ParseStatusReport decodes a +CDS URC in either message format: the PDU
+// mode form carries the PDU on the following line, the text mode form
+// "+CDS: <fo>,<mr>,<ra>,<tora>,<scts>,<dt>,<st>" carries the fields inline
+func ParseStatusReport(urc at.URC) (*pdu.StatusReport, error) {
+	if urc.Data != "" {
+		return pdu.DecodeStatusReport(urc.Data)
+	}
+
+	fields := splitFields(urc.Params)
+	if len(fields) < 7 {
+		return nil, fmt.Errorf("malformed status report %q", urc.Line)
+	}
+	mr, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed reference in %q", urc.Line)
+	}
+	st, err := strconv.Atoi(fields[6])
+	if err != nil {
+		return nil, fmt.Errorf("malformed status in %q", urc.Line)
+	}
+
+	report := &pdu.StatusReport{Reference: mr, Recipient: fields[2], Status: byte(st)}
+	report.ServiceCentreTime, _ = parseTextTimestamp(fields[4])
+	report.DischargeTime, _ = parseTextTimestamp(fields[5])
+	return report, nil
+}
+
+// ParseStorageIndex parses the <mem>,<index> of +CMTI and +CDSI, which
+// announce a message or status report stored in modem memory
+func ParseStorageIndex(urc at.URC) (string, int, error) {
+	fields := splitFields(urc.Params)
+	if len(fields) < 2 {
+		return "", 0, fmt.Errorf("malformed %s", urc.Line)
+	}
+	index, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed index in %q", urc.Line)
+	}
+	return fields[0], index, nil
+}
+
+// ReadStatusReport reads and deletes a status report stored in memory mem,
+// as announced by +CDSI. It waits for the port like any other operation.
+func (c *Client) ReadStatusReport(ctx context.Context, device *session.Device, mem string, index int) (*pdu.StatusReport, error) {
+	release, err := device.Acquire(ctx, "status report")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	sess, err := device.Session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := device.SetMessageFormat(ctx, session.FormatPDU); err != nil {
+		return nil, fmt.Errorf("failed to set PDU mode: %w", err)
+	}
+
+	restore, err := useMemory(ctx, sess, mem)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	resp, err := sess.Command(ctx, fmt.Sprintf("AT+CMGR=%d", index))
+	if err != nil {
+		return nil, err
+	}
+	data := dataAfter(resp, "+CMGR:")
+	if data == "" {
+		return nil, fmt.Errorf("no status report at index %d", index)
+	}
+	report, err := pdu.DecodeStatusReport(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := sess.Command(ctx, fmt.Sprintf("AT+CMGD=%d", index)); err != nil {
+		log.Printf("SMS Client: Failed to delete status report %d: %v", index, err)
+	}
+	return report, nil
+}
+
+// useMemory selects memory mem for reading and deleting with AT+CPMS and
+// returns the function that restores the previous selection. An empty mem
+// keeps the current one.
+func useMemory(ctx context.Context, sess *at.Session, mem string) (func(), error) {
+	if mem == "" {
+		return func() {}, nil
+	}
+	previous := "SM"
+	if resp, err := sess.Command(ctx, "AT+CPMS?"); err == nil {
+		if values := resp.Prefixed("+CPMS:"); len(values) > 0 {
+			previous = strings.Trim(strings.Split(values[0], ",")[0], "\"")
+		}
+	}
+	if previous == mem {
+		return func() {}, nil
+	}
+	if _, err := sess.Command(ctx, fmt.Sprintf("AT+CPMS=\"%s\"", mem)); err != nil {
+		return nil, fmt.Errorf("failed to select %s memory: %w", mem, err)
+	}
+	return func() {
+		sess.Command(context.WithoutCancel(ctx), fmt.Sprintf("AT+CPMS=\"%s\"", previous))
+	}, nil
+}
+
+// dataAfter returns the line that follows the first line with prefix
+func dataAfter(resp *at.Response, prefix string) string {
+	for i, line := range resp.Lines {
+		if strings.HasPrefix(line, prefix) && i+1 < len(resp.Lines) {
+			return resp.Lines[i+1]
+		}
+	}
+	return ""
+}
+
+// splitFields splits comma separated AT parameters, keeping commas inside
+// quotes and removing the quotes
+func splitFields(params string) []string {
+	var fields []string
+	var b strings.Builder
+	quoted := false
+	for _, r := range params {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			fields = append(fields, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return append(fields, strings.TrimSpace(b.String()))
+}
+
+// parseTextTimestamp parses a text mode time stamp "yy/MM/dd,hh:mm:ss±zz"
+// where zz is the offset in quarter hours
+func parseTextTimestamp(value string) (time.Time, error) {
+	if len(value) < 20 {
+		return time.Time{}, fmt.Errorf("malformed time stamp %q", value)
+	}
+	quarters, err := strconv.Atoi(value[18:])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed time stamp %q", value)
+	}
+	if value[17] == '-' {
+		quarters = -quarters
+	}
+	loc := time.FixedZone("", quarters*15*60)
+	return time.ParseInLocation("06/01/02,15:04:05", value[:17], loc)
+}
@@ -0,0 +1,103 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/port"
+	"sms-gateway/src/pkg/session"
+)
+
+// permanentCMS are +CMS ERROR codes (3GPP TS 27.005 and the RP causes of
+// TS 24.011) that a new attempt would meet again
+var permanentCMS = map[int]bool{
+	1:   true, // unassigned number
+	8:   true, // operator determined barring
+	10:  true, // call barred
+	21:  true, // short message transfer rejected
+	29:  true, // facility rejected
+	30:  true, // unknown subscriber
+	50:  true, // requested facility not subscribed
+	69:  true, // requested facility not implemented
+	96:  true, // invalid mandatory information
+	303: true, // operation not supported
+	304: true, // invalid PDU mode parameter
+	305: true, // invalid text mode parameter
+	310: true, // SIM not inserted
+	311: true, // SIM PIN required
+	312: true, // PH-SIM PIN required
+	313: true, // SIM failure
+	316: true, // SIM PUK required
+	317: true, // SIM PIN2 required
+	318: true, // SIM PUK2 required
+	330: true, // SMSC address unknown
+}
+
+// permanentCME are +CME ERROR codes (3GPP TS 27.007) of a SIM that cannot
+// send until someone intervenes
+var permanentCME = map[int]bool{
+	4:   true, // operation not supported
+	10:  true, // SIM not inserted
+	11:  true, // SIM PIN required
+	12:  true, // SIM PUK required
+	13:  true, // SIM failure
+	15:  true, // SIM wrong
+	16:  true, // incorrect password
+	17:  true, // SIM PIN2 required
+	18:  true, // SIM PUK2 required
+	262: true, // SIM blocked
+}
+
+// permanentTexts identify the same errors on modems answering in words
+// (AT+CMEE=2)
+var permanentTexts = []string{
+	"unassigned", "barred", "not subscribed", "invalid", "not supported",
+	"sim not inserted", "sim pin", "sim puk", "sim failure", "sim wrong", "sim blocked",
+}
+
+// Retryable reports whether a failed send is worth another attempt.
+// Timeouts, a busy or unreachable modem and temporary network failures are;
+// errors that would recur, such as an invalid number or a blocked SIM, are
+// not. Modem errors not known to be permanent are retried. Any other error,
+// such as a message that cannot be encoded, is permanent.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var atErr *at.Error
+	if !errors.As(err, &atErr) {
+		return transportError(err)
+	}
+	if atErr.Code >= 0 {
+		switch atErr.Result {
+		case at.ResultCMSError:
+			return !permanentCMS[atErr.Code]
+		case at.ResultCMEError:
+			return !permanentCME[atErr.Code]
+		}
+	}
+	text := strings.ToLower(atErr.Text)
+	for _, word := range permanentTexts {
+		if strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// transportError reports whether err comes from reaching the modem rather
+// than from the message
+func transportError(err error) bool {
+	for _, target := range []error{
+		at.ErrTimeout, at.ErrClosed, at.ErrWrite, port.ErrOpen,
+		session.ErrBusy, context.DeadlineExceeded,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
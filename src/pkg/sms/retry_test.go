@@ -0,0 +1,52 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"sms-gateway/src/pkg/at"
+	"sms-gateway/src/pkg/pdu"
+	"sms-gateway/src/pkg/port"
+	"sms-gateway/src/pkg/session"
+)
+
+func TestRetryable(t *testing.T) {
+	submit := &pdu.Submit{Destination: "not a number", Text: "hi"}
+	_, _, invalidAddress := submit.Encode()
+	if invalidAddress == nil {
+		t.Fatal("expected an invalid address to fail encoding")
+	}
+	cms := func(code int) error {
+		return fmt.Errorf("failed to send SMS: %w", &at.Error{Command: "AT+CMGS", Result: at.ResultCMSError, Code: code})
+	}
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network timeout", cms(332), true},
+		{"temporary failure", cms(41), true},
+		{"unknown error", cms(500), true},
+		{"SIM busy", cms(314), true},
+		{"unassigned number", cms(1), false},
+		{"SIM PUK required", cms(316), false},
+		{"SIM blocked", &at.Error{Command: "AT+CMGS", Result: at.ResultCMEError, Code: 262}, false},
+		{"SIM blocked in words", &at.Error{Command: "AT+CMGS", Result: at.ResultCMEError, Code: -1, Text: "SIM blocked"}, false},
+		{"plain ERROR", &at.Error{Command: "AT+CMGS", Result: at.ResultError, Code: -1}, true},
+		{"modem timeout", fmt.Errorf("AT+CMGS: %w", at.ErrTimeout), true},
+		{"modem busy", &session.BusyError{Port: "sim://1"}, true},
+		{"lease timeout", context.DeadlineExceeded, true},
+		{"modem closed", fmt.Errorf("failed to set PDU mode: %w", at.ErrClosed), true},
+		{"write failed", fmt.Errorf("failed to send SMS: %w", fmt.Errorf("AT+CMGS=18: %w: %w", at.ErrWrite, errors.New("broken pipe"))), true},
+		{"dongle unplugged", fmt.Errorf("%w: %w", port.ErrOpen, errors.New("no such file or directory")), true},
+		{"invalid address", fmt.Errorf("failed to generate PDU: %w", invalidAddress), false},
+		{"unknown error", errors.New("something else"), false},
+		{"service stopping", context.Canceled, false},
+	} {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("%s: Retryable(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package ussd
+
+import (
+	"context"
+	"encoding/hex"
+	"log"
+	"strings"
+	"unicode"
+
+	"sms-gateway/src/pkg/pdu"
+	"sms-gateway/src/pkg/session"
+)
+
+// TE character sets selected with AT+CSCS that change how USSD strings are
+// presented
+const (
+	CharsetGSM  = "GSM"
+	CharsetIRA  = "IRA"
+	CharsetUCS2 = "UCS2" // strings are hex encoded UTF-16
+	CharsetHEX  = "HEX"  // strings are hex encoded GSM 7-bit septets
+)
+
+// PrepareCharset selects the configured TE character set, if any, and
+// returns the one the modem presents USSD strings in, "" when unknown. The
+// SMS path switches to UCS2 for Unicode text mode messages, so the current
+// set is not necessarily GSM.
+func PrepareCharset(ctx context.Context, device *session.Device, configured string) string {
+	if configured != "" {
+		if err := device.SetCharacterSet(ctx, configured); err != nil {
+			log.Printf("[%s] Failed to select character set %s for USSD: %v", device.Port(), configured, err)
+		}
+	}
+	return device.CharacterSet()
+}
+
+// Alphabet returns the encoding selected by a USSD data coding scheme,
+// which follows the cell broadcast coding groups of 3GPP TS 23.038. Unknown
+// and reserved values are GSM 7-bit.
+func Alphabet(dcs int) string {
+	switch {
+	case dcs < 0:
+		return pdu.EncodingGSM7
+	case dcs == 0x11: // UCS2 preceded by the language
+		return pdu.EncodingUCS2
+	case dcs&0xC0 == 0x40, dcs&0xF0 == 0x90: // general data coding, with UDH
+		switch (dcs >> 2) & 0x03 {
+		case 0x01:
+			return pdu.Encoding8Bit
+		case 0x02:
+			return pdu.EncodingUCS2
+		}
+	case dcs&0xF0 == 0xF0: // data coding/message class
+		if dcs&0x04 != 0 {
+			return pdu.Encoding8Bit
+		}
+	}
+	return pdu.EncodingGSM7
+}
+
+// EncodeString converts text to the representation the modem expects in
+// charset, e.g. "*101#" is sent as "002A0031003000310023" in UCS2
+func EncodeString(text, charset string) string {
+	switch strings.ToUpper(charset) {
+	case CharsetUCS2:
+		return strings.ToUpper(hex.EncodeToString(pdu.EncodeUCS2(text)))
+	case CharsetHEX:
+		if septets, err := pdu.EncodeGSM7(text); err == nil {
+			return strings.ToUpper(hex.EncodeToString(septets))
+		}
+	}
+	return text
+}
+
+// Decode converts a USSD string as presented by the modem to text. UCS2
+// answers are usually passed on as hex whatever the character set, and
+// some modems pass GSM 7-bit answers on packed as hex, so hex strings are
+// recognised even when charset does not ask for them.
+func Decode(raw string, dcs int, charset string) string {
+	data, isHex := hexBytes(raw)
+
+	switch Alphabet(dcs) {
+	case pdu.EncodingUCS2:
+		if isHex && len(data)%2 == 0 {
+			if dcs == 0x11 && len(data) >= 2 {
+				// Two packed GSM 7-bit characters name the language
+				data = data[2:]
+			}
+			return pdu.DecodeUCS2(data)
+		}
+		return raw
+	case pdu.Encoding8Bit:
+		return raw
+	}
+
+	switch strings.ToUpper(charset) {
+	case CharsetUCS2:
+		if isHex && len(data)%2 == 0 {
+			return pdu.DecodeUCS2(data)
+		}
+	case CharsetHEX:
+		if isHex {
+			return pdu.DecodeGSM7(data)
+		}
+	}
+	if text, ok := decodeHexUCS2(raw, data, isHex); ok {
+		return text
+	}
+	if text, ok := decodePacked(raw, data, isHex); ok {
+		return text
+	}
+	return raw
+}
+
+// decodeHexUCS2 recognises a hex UCS2 string: every character must be
+// printable and at least one must be ASCII, which rules out plain digits
+// such as "12345678" read as two CJK characters
+func decodeHexUCS2(raw string, data []byte, isHex bool) (string, bool) {
+	if !isHex || len(data)%2 != 0 {
+		return "", false
+	}
+	text := pdu.DecodeUCS2(data)
+	ascii := false
+	for _, r := range text {
+		if r < 0x80 && r != '\n' && r != '\r' && unicode.IsPrint(r) {
+			ascii = true
+		}
+		if !unicode.IsPrint(r) && r != '\n' && r != '\r' {
+			return "", false
+		}
+	}
+	return text, ascii
+}
+
+// decodePacked recognises packed GSM 7-bit as hex. Plain numbers such as
+// an MSISDN are valid hex too, so a hex letter is required and the result
+// must be mostly ASCII, like every real answer.
+func decodePacked(raw string, data []byte, isHex bool) (string, bool) {
+	if !isHex || strings.IndexAny(strings.ToUpper(raw), "ABCDEF") < 0 {
+		return "", false
+	}
+	count := len(data) * 8 / 7
+	septets := pdu.UnpackSeptets(data, count, 0)
+	// Seven spare bits at the end are padded with CR, or left zero by
+	// some networks, and do not form a character
+	if count%8 == 0 && count > 0 && (septets[count-1] == '\r' || septets[count-1] == 0) {
+		septets = septets[:count-1]
+	}
+	text := pdu.DecodeGSM7(septets)
+
+	ascii, total := 0, 0
+	for _, r := range text {
+		total++
+		if r < 0x80 && (unicode.IsPrint(r) || r == '\n' || r == '\r') {
+			ascii++
+		}
+	}
+	return text, total > 0 && ascii*10 >= total*8
+}
+
+// hexBytes decodes s when it is a non-empty hex string
+func hexBytes(s string) ([]byte, bool) {
+	if s == "" || len(s)%2 != 0 {
+		return nil, false
+	}
+	data, err := hex.DecodeString(s)
+	return data, err == nil
+}
@@ -0,0 +1,138 @@
+package ussd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sms-gateway/src/pkg/at"
+)
+
+// Result codes <m> of +CUSD: <m>[,<str>,<dcs>]
+const (
+	StatusDone         = 0 // no further user action required
+	StatusActionNeeded = 1 // the network waits for a reply, e.g. a menu choice
+	StatusTerminated   = 2 // the network ended the session
+	StatusOtherClient  = 3 // another local client answered
+	StatusNotSupported = 4 // the operation or code is not supported
+	StatusTimeout      = 5 // the network did not answer in time
+)
+
+// ErrNotSupported is returned when the network rejects a code with +CUSD: 4
+var ErrNotSupported = errors.New("USSD operation not supported")
+
+// Response is one answer of the network
+type Response struct {
+	Status int    // one of the Status* codes
+	Text   string // decoded text
+	Raw    string // text as presented by the modem, e.g. hex UCS2
+	DCS    int    // data coding scheme of the text, -1 when absent
+	Line   string // raw +CUSD line
+}
+
+// Encoding returns the alphabet selected by the DCS, "" without text
+func (r *Response) Encoding() string {
+	if r.Raw == "" {
+		return ""
+	}
+	return Alphabet(r.DCS)
+}
+
+// Open reports whether the network waits for a reply
+func (r *Response) Open() bool {
+	return r.Status == StatusActionNeeded
+}
+
+// StatusText describes the result code
+func (r *Response) StatusText() string {
+	switch r.Status {
+	case StatusDone:
+		return "done"
+	case StatusActionNeeded:
+		return "action required"
+	case StatusTerminated:
+		return "terminated by network"
+	case StatusOtherClient:
+		return "answered by another client"
+	case StatusNotSupported:
+		return "not supported"
+	case StatusTimeout:
+		return "network timeout"
+	default:
+		return "unknown"
+	}
+}
+
+// Parse decodes a +CUSD URC whose string is presented in charset, the TE
+// character set of the modem or "" when unknown
+func Parse(urc at.URC, charset string) (*Response, error) {
+	// Example: +CUSD: 1,"1. Data\n2. Thoai",15
+	params := strings.TrimSpace(urc.Params)
+	head, rest, _ := strings.Cut(params, ",")
+	status, err := strconv.Atoi(strings.TrimSpace(head))
+	if err != nil {
+		return nil, fmt.Errorf("malformed USSD answer %q", urc.Line)
+	}
+
+	resp := &Response{Status: status, DCS: -1, Line: urc.Line}
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return resp, nil
+	}
+	// The text may contain commas and quotes of its own, so it spans from
+	// the first to the last quote and the DCS follows the last one
+	start := strings.Index(rest, "\"")
+	end := strings.LastIndex(rest, "\"")
+	if start < 0 || end <= start {
+		resp.Raw = rest
+	} else {
+		resp.Raw = rest[start+1 : end]
+		if dcs := strings.TrimSpace(strings.TrimPrefix(rest[end+1:], ",")); dcs != "" {
+			if value, err := strconv.Atoi(dcs); err == nil {
+				resp.DCS = value
+			}
+		}
+	}
+	resp.Text = Decode(resp.Raw, resp.DCS, charset)
+	return resp, nil
+}
+
+// Send sends a code or a reply to an open session and waits up to timeout
+// for the answer. charset is the TE character set the modem uses (see
+// PrepareCharset). The answer is a URC that may arrive before or after the
+// final OK, so the subscription is taken before the request is sent.
+func Send(ctx context.Context, sess *at.Session, text, charset string, timeout time.Duration) (*Response, error) {
+	if strings.ContainsAny(text, "\"\r\n") {
+		return nil, fmt.Errorf("invalid USSD string %q", text)
+	}
+
+	urcs, unsubscribe := sess.Subscribe(4)
+	defer unsubscribe()
+
+	cmd := "AT+CUSD=1,\"" + EncodeString(text, charset) + "\",15"
+	if _, err := sess.CommandTimeout(ctx, cmd, 3*time.Second); err != nil {
+		return nil, err
+	}
+
+	urc, err := at.WaitURC(ctx, urcs, at.URCUSSD, timeout)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := Parse(urc, charset)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status == StatusNotSupported {
+		return resp, ErrNotSupported
+	}
+	return resp, nil
+}
+
+// Cancel ends the current session with AT+CUSD=2
+func Cancel(ctx context.Context, sess *at.Session) error {
+	_, err := sess.CommandTimeout(ctx, "AT+CUSD=2", 3*time.Second)
+	return err
+}
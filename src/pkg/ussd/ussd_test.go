@@ -0,0 +1,76 @@
+package ussd
+
+import (
+	"testing"
+
+	"sms-gateway/src/pkg/at"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		params string
+		status int
+		text   string
+		dcs    int
+	}{
+		{`0,"TKC: 12.345d, HSD: 31/12/2026",15`, StatusDone, "TKC: 12.345d, HSD: 31/12/2026", 15},
+		{"1,\"Chon goi:\n1. Data\n2. \"Thoai\"\",72", StatusActionNeeded, "Chon goi:\n1. Data\n2. \"Thoai\"", 72},
+		{"2", StatusTerminated, "", -1},
+		{`4`, StatusNotSupported, "", -1},
+	}
+	for _, tt := range tests {
+		resp, err := Parse(at.URC{Name: at.URCUSSD, Params: tt.params, Line: "+CUSD: " + tt.params}, "")
+		if err != nil {
+			t.Errorf("%q: %v", tt.params, err)
+			continue
+		}
+		if resp.Status != tt.status || resp.Text != tt.text || resp.DCS != tt.dcs {
+			t.Errorf("%q: got %d %q %d", tt.params, resp.Status, resp.Text, resp.DCS)
+		}
+	}
+	if resp, _ := Parse(at.URC{Params: "1"}, ""); !resp.Open() {
+		t.Error("status 1 should keep the session open")
+	}
+	if _, err := Parse(at.URC{Params: `"x"`}, ""); err == nil {
+		t.Error("expected error without a status")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		dcs     int
+		charset string
+		want    string
+	}{
+		{"plain", "TKC: 12.345d", 15, "GSM", "TKC: 12.345d"},
+		{"ucs2 hex", "0054006100300069", 72, "", "Ta0i"},
+		{"vietnamese", "00540069003A0020003100300030002E003000300030011100201EA5", 72, "IRA", "Ti: 100.000đ ấ"},
+		{"ucs2 language", "E5340054", 0x11, "", "T"},
+		{"packed gsm7", "AA180C3602", 15, "", "*100#"},
+		{"packed with CR padding", "C2303BEC1E971B", 15, "", "Balance"},
+		{"ucs2 charset", "0054004B0043", 15, "UCS2", "TKC"},
+		{"hex charset", "544B43", 15, "HEX", "TKC"},
+		{"digits stay digits", "0912345678", 15, "", "0912345678"},
+		{"digits in groups of four", "12345678", 15, "", "12345678"},
+		{"8-bit stays hex", "0102", 0x44, "", "0102"},
+	}
+	for _, tt := range tests {
+		if got := Decode(tt.raw, tt.dcs, tt.charset); got != tt.want {
+			t.Errorf("%s: Decode(%q, %d, %q) = %q, want %q", tt.name, tt.raw, tt.dcs, tt.charset, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeString(t *testing.T) {
+	if got := EncodeString("*101#", CharsetUCS2); got != "002A0031003000310023" {
+		t.Errorf("UCS2: %q", got)
+	}
+	if got := EncodeString("*101#", CharsetHEX); got != "2A31303123" {
+		t.Errorf("HEX: %q", got)
+	}
+	if got := EncodeString("*101#", CharsetGSM); got != "*101#" {
+		t.Errorf("GSM: %q", got)
+	}
+}
@@ -4,14 +4,28 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
 
 	"sms-gateway/src/internal/model"
+	"sms-gateway/src/pkg/pdu"
 )
 
+// phoneSeparators matches what people write between the digits of a
+// number, such as spaces, dashes, dots and parentheses
+var phoneSeparators = regexp.MustCompile(`[^\d+]`)
+
+// NormalizePhoneNumber strips the separators that ValidatePhoneNumber
+// allows, leaving digits and a leading '+'
+func NormalizePhoneNumber(phone string) string {
+	return phoneSeparators.ReplaceAllString(phone, "")
+}
+
 // ValidatePhoneNumber validates phone number format
 func ValidatePhoneNumber(phone string) error {
 	// Remove any non-digit characters except +
-	cleanPhone := regexp.MustCompile(`[^\d+]`).ReplaceAllString(phone, "")
+	cleanPhone := NormalizePhoneNumber(phone)
 
 	// Check if it's a valid international format
 	if strings.HasPrefix(cleanPhone, "+") {
@@ -33,28 +47,37 @@ func ValidatePhoneNumber(phone string) error {
 	return nil
 }
 
-// ValidateSMSMessage validates SMS message content
-func ValidateSMSMessage(message string) error {
+// ValidateSMSMessage validates SMS message content. Longer messages are
+// sent as concatenated parts, up to maxLength characters in total. wide
+// tells that parts carry 16-bit concatenation references, which leave one
+// character less per part.
+func ValidateSMSMessage(message string, maxLength int, wide bool) error {
 	if strings.TrimSpace(message) == "" {
 		return fmt.Errorf("message cannot be empty")
 	}
 
-	if len(message) > 160 {
-		return fmt.Errorf("message too long (max 160 characters)")
+	// Extension characters such as € and { take two septets, and any
+	// character outside the GSM alphabet switches the message to UCS2
+	length, encoding := pdu.MessageLength(message)
+	if length > maxLength {
+		return fmt.Errorf("message too long (%d characters in %s, max %d)", length, encoding, maxLength)
+	}
+	if parts, _ := pdu.Split(message, wide); len(parts) > 255 {
+		return fmt.Errorf("message too long (%d parts, max 255)", len(parts))
 	}
 
 	return nil
 }
 
 // ValidateSendSMSRequest validates the entire SMS request
-func ValidateSendSMSRequest(req *model.SendSMSRequest) error {
+func ValidateSendSMSRequest(req *model.SendSMSRequest, maxLength int, wide bool) error {
 	// Validate phone number
 	if err := ValidatePhoneNumber(req.To); err != nil {
 		return fmt.Errorf("invalid phone number: %w", err)
 	}
 
 	// Validate message
-	if err := ValidateSMSMessage(req.Message); err != nil {
+	if err := ValidateSMSMessage(req.Message, maxLength, wide); err != nil {
 		return fmt.Errorf("invalid message: %w", err)
 	}
 
@@ -78,5 +101,72 @@ func ValidateSendSMSRequest(req *model.SendSMSRequest) error {
 		return fmt.Errorf("timeout must be between 5 and 300 seconds")
 	}
 
+	switch req.Priority {
+	case "", model.PriorityNormal, model.PriorityHigh, model.PriorityUrgent:
+	default:
+		return fmt.Errorf("invalid priority %q, expected normal, high or urgent", req.Priority)
+	}
+
+	return ValidateSchedule(req.SendAt, req.Recurrence, req.TimeZone)
+}
+
+// ValidateSchedule validates the send time, the cron recurrence and the
+// time zone of a scheduled send. All are optional, but a send time must
+// not have passed.
+func ValidateSchedule(sendAt *time.Time, recurrence, timeZone string) error {
+	if sendAt != nil && sendAt.Before(time.Now()) {
+		return fmt.Errorf("send_at %s is in the past", sendAt.Format(time.RFC3339))
+	}
+	if timeZone != "" {
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			return fmt.Errorf("invalid time zone %q, expected e.g. Asia/Ho_Chi_Minh", timeZone)
+		}
+	}
+	if recurrence != "" {
+		if _, err := ParseRecurrence(recurrence); err != nil {
+			return fmt.Errorf("invalid recurrence %q: %v", recurrence, err)
+		}
+	}
+
+	return nil
+}
+
+// recurrenceParser reads the five standard cron fields only
+var recurrenceParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+// ParseRecurrence parses the recurrence of a scheduled send. Descriptors
+// such as "@every 1s" and zone prefixes are refused, so that a schedule
+// runs at most once a minute and its zone is the one in time_zone.
+func ParseRecurrence(recurrence string) (cron.Schedule, error) {
+	if strings.HasPrefix(recurrence, "@") || strings.Contains(recurrence, "TZ=") {
+		return nil, fmt.Errorf(`expected five cron fields such as "0 8 * * 1-5"`)
+	}
+	return recurrenceParser.Parse(recurrence)
+}
+
+// ussdCode matches service codes such as *101#, #100# or *098*1#
+var ussdCode = regexp.MustCompile(`^[*#][0-9*#+]*#$`)
+
+// ValidateUSSDCode validates the code that starts a USSD session
+func ValidateUSSDCode(code string) error {
+	if !ussdCode.MatchString(code) {
+		return fmt.Errorf("invalid USSD code %q, expected e.g. *101#", code)
+	}
+	return ValidateUSSDInput(code)
+}
+
+// ValidateUSSDInput validates a reply to a USSD menu. USSD strings are at
+// most 182 characters and are sent quoted, so quotes and line breaks are
+// not allowed.
+func ValidateUSSDInput(input string) error {
+	if strings.TrimSpace(input) == "" {
+		return fmt.Errorf("USSD input cannot be empty")
+	}
+	if len([]rune(input)) > 182 {
+		return fmt.Errorf("USSD input too long (max 182 characters)")
+	}
+	if strings.ContainsAny(input, "\"\r\n") {
+		return fmt.Errorf("USSD input cannot contain quotes or line breaks")
+	}
 	return nil
 }
@@ -0,0 +1,44 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateSMSMessageParts(t *testing.T) {
+	// 255 parts of 153 characters fit with 8-bit references but need more
+	// parts when 16-bit references leave 152 per part
+	message := strings.Repeat("a", 255*153)
+	if err := ValidateSMSMessage(message, len(message), false); err != nil {
+		t.Errorf("8-bit references: %v", err)
+	}
+	if err := ValidateSMSMessage(message, len(message), true); err == nil || !strings.Contains(err.Error(), "parts, max 255") {
+		t.Errorf("16-bit references: error = %v, want too many parts", err)
+	}
+
+	if err := ValidateSMSMessage(strings.Repeat("a", 161), 160, false); err == nil {
+		t.Error("expected a message over the maximum length to be rejected")
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Minute)
+	for _, tt := range []struct {
+		sendAt     *time.Time
+		recurrence string
+		ok         bool
+	}{
+		{nil, "0 8 * * 1-5", true},
+		{&future, "", true},
+		{&past, "", false},
+		{nil, "@every 1s", false},
+		{nil, "@daily", false},
+		{nil, "CRON_TZ=Asia/Ho_Chi_Minh 0 8 * * *", false},
+		{nil, "* * * * * *", false},
+	} {
+		if err := ValidateSchedule(tt.sendAt, tt.recurrence, ""); (err == nil) != tt.ok {
+			t.Errorf("%q: error = %v, want ok %v", tt.recurrence, err, tt.ok)
+		}
+	}
+}
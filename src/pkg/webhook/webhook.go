@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxBackoff caps the delay between two attempts
+const maxBackoff = time.Minute
+
+// Dispatcher posts events as JSON to a set of URLs, retrying failed
+// deliveries with exponential backoff
+type Dispatcher struct {
+	urls       []string
+	client     *http.Client
+	maxRetries int
+	retryDelay time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher. Each request times out after timeout;
+// a failed delivery is retried maxRetries times, waiting retryDelay before
+// the first retry and twice as long before each next one.
+func NewDispatcher(urls []string, timeout time.Duration, maxRetries int, retryDelay time.Duration) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		urls:       urls,
+		client:     &http.Client{Timeout: timeout},
+		maxRetries: maxRetries,
+		retryDelay: retryDelay,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Enabled reports whether any URL is configured
+func (d *Dispatcher) Enabled() bool {
+	return len(d.urls) > 0
+}
+
+// Dispatch posts event to every URL in the background
+func (d *Dispatcher) Dispatch(event interface{}) {
+	d.DispatchConfirmed(event, nil)
+}
+
+// DispatchConfirmed posts event like Dispatch and calls delivered once
+// every URL has accepted it. delivered is never called when no URL is
+// configured or some delivery fails for good.
+func (d *Dispatcher) DispatchConfirmed(event interface{}, delivered func()) {
+	if !d.Enabled() {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Webhook: Failed to encode event: %v", err)
+		return
+	}
+	var (
+		mu      sync.Mutex
+		pending = len(d.urls)
+		failed  bool
+	)
+	for _, url := range d.urls {
+		d.wg.Add(1)
+		go func(url string) {
+			defer d.wg.Done()
+			err := d.deliver(url, body)
+			if err != nil {
+				log.Printf("Webhook: Giving up on %s: %v", url, err)
+			}
+
+			mu.Lock()
+			failed = failed || err != nil
+			pending--
+			confirm := pending == 0 && !failed && delivered != nil
+			mu.Unlock()
+			if confirm {
+				delivered()
+			}
+		}(url)
+	}
+}
+
+// Close abandons pending retries and waits for requests in flight
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// deliver posts body to url until it is accepted, the error is permanent
+// or the retries are used up
+func (d *Dispatcher) deliver(url string, body []byte) error {
+	delay := d.retryDelay
+	for attempt := 0; ; attempt++ {
+		retryable, err := d.post(url, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= d.maxRetries {
+			return err
+		}
+		log.Printf("Webhook: Attempt %d to %s failed, retrying in %v: %v", attempt+1, url, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-d.ctx.Done():
+			return fmt.Errorf("dispatcher closed: %w", err)
+		}
+		if delay *= 2; delay > maxBackoff {
+			delay = maxBackoff
+		}
+	}
+}
+
+// post makes one attempt. Network errors, 429 and 5xx answers are worth
+// retrying; other 4xx answers are not.
+func (d *Dispatcher) post(url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatchRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher([]string{srv.URL}, time.Second, 5, 10*time.Millisecond)
+	if err := d.deliver(srv.URL, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestDispatchPermanentFailure(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher([]string{srv.URL}, time.Second, 5, 10*time.Millisecond)
+	if err := d.deliver(srv.URL, []byte(`{}`)); err == nil {
+		t.Error("expected error for 400")
+	}
+	if calls != 1 {
+		t.Errorf("a 400 answer was retried %d times", calls-1)
+	}
+
+	// Retries stop once they are used up
+	calls = 0
+	d.maxRetries = 2
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	if err := d.deliver(srv.URL, []byte(`{}`)); err == nil || calls != 3 {
+		t.Errorf("expected 3 attempts and an error, got %d, %v", calls, err)
+	}
+}
+
+func TestDispatchConfirmed(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+
+	for _, tt := range []struct {
+		name string
+		urls []string
+		want int32
+	}{
+		{"all accepted", []string{ok.URL, ok.URL}, 1},
+		{"one rejected", []string{ok.URL, rejecting.URL}, 0},
+		{"no URL", nil, 0},
+	} {
+		var confirmed int32
+		d := NewDispatcher(tt.urls, time.Second, 1, 10*time.Millisecond)
+		d.DispatchConfirmed(map[string]string{"event": "test"}, func() { atomic.AddInt32(&confirmed, 1) })
+		d.wg.Wait()
+		if got := atomic.LoadInt32(&confirmed); got != tt.want {
+			t.Errorf("%s: confirmed %d times", tt.name, got)
+		}
+	}
+}